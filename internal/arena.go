@@ -0,0 +1,393 @@
+package internal
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	// arenaInitialRating is the Elo rating of a model without matches
+	arenaInitialRating = 1000
+	// arenaKFactor is how far a single match moves a rating
+	arenaKFactor = 32
+)
+
+// Outcomes of an arena match
+const (
+	ArenaWinA = "a"
+	ArenaWinB = "b"
+	ArenaTie  = "tie"
+)
+
+// ArenaMatch is a prompt answered by two anonymous models and the user's verdict
+type ArenaMatch struct {
+	Time    time.Time `json:"time"`
+	Prompt  string    `json:"prompt"`
+	ModelA  string    `json:"modelA"`
+	ModelB  string    `json:"modelB"`
+	AnswerA string    `json:"answerA"`
+	AnswerB string    `json:"answerB"`
+	Outcome string    `json:"outcome"`
+}
+
+// ArenaRating is the standing of a model on the leaderboard
+type ArenaRating struct {
+	Model  string
+	Rating float64
+	Games  int
+	Wins   int
+	Losses int
+	Ties   int
+}
+
+// eloExpected is the chance of a player rated a beating one rated b
+func eloExpected(a, b float64) float64 {
+	return 1 / (1 + math.Pow(10, (b-a)/400))
+}
+
+// arenaLeaderboard replays the matches in order and returns the models
+// sorted by rating
+func arenaLeaderboard(matches []ArenaMatch) []ArenaRating {
+	ratings := map[string]*ArenaRating{}
+	rating := func(model string) *ArenaRating {
+		r, ok := ratings[model]
+		if !ok {
+			r = &ArenaRating{Model: model, Rating: arenaInitialRating}
+			ratings[model] = r
+		}
+		return r
+	}
+
+	for _, match := range matches {
+		var score float64
+		switch match.Outcome {
+		case ArenaWinA:
+			score = 1
+		case ArenaWinB:
+			score = 0
+		case ArenaTie:
+			score = 0.5
+		default:
+			continue
+		}
+		if match.ModelA == "" || match.ModelB == "" || match.ModelA == match.ModelB {
+			continue
+		}
+
+		a, b := rating(match.ModelA), rating(match.ModelB)
+		expected := eloExpected(a.Rating, b.Rating)
+		a.Rating += arenaKFactor * (score - expected)
+		b.Rating += arenaKFactor * ((1 - score) - (1 - expected))
+		a.Games++
+		b.Games++
+		switch match.Outcome {
+		case ArenaWinA:
+			a.Wins++
+			b.Losses++
+		case ArenaWinB:
+			b.Wins++
+			a.Losses++
+		default:
+			a.Ties++
+			b.Ties++
+		}
+	}
+
+	result := make([]ArenaRating, 0, len(ratings))
+	for _, r := range ratings {
+		result = append(result, *r)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Rating != result[j].Rating {
+			return result[i].Rating > result[j].Rating
+		}
+		return result[i].Model < result[j].Model
+	})
+	return result
+}
+
+// leaderboardColumns are the headers of the leaderboard and its CSV export
+var leaderboardColumns = []string{"Rank", "Model", "Rating", "Games", "Wins", "Losses", "Ties"}
+
+// leaderboardRow formats a leaderboard entry
+func leaderboardRow(rank int, r ArenaRating) []string {
+	return []string{
+		strconv.Itoa(rank),
+		r.Model,
+		strconv.FormatFloat(r.Rating, 'f', 0, 64),
+		strconv.Itoa(r.Games),
+		strconv.Itoa(r.Wins),
+		strconv.Itoa(r.Losses),
+		strconv.Itoa(r.Ties),
+	}
+}
+
+// writeLeaderboardCSV exports the leaderboard
+func writeLeaderboardCSV(w io.Writer, ratings []ArenaRating) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(leaderboardColumns); err != nil {
+		return err
+	}
+	for i, r := range ratings {
+		if err := writer.Write(leaderboardRow(i+1, r)); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// ArenaView lets the user judge the answers of two hidden models and keeps
+// a leaderboard of the votes
+type ArenaView struct {
+	ParentWindow fyne.Window
+	Settings     *Settings
+	InputEntry   *widget.Entry
+	SendButton   *widget.Button
+	AnswerA      *widget.Label
+	AnswerB      *widget.Label
+	Reveal       *widget.Label
+	VoteButtons  []*widget.Button
+	Leaderboard  *widget.Table
+	ExportButton *widget.Button
+
+	models  []string
+	ratings []ArenaRating
+	current *ArenaMatch
+	content *fyne.Container
+}
+
+// NewArenaView creates the arena tab for the installed models
+func NewArenaView(models []string, parent fyne.Window, settings *Settings) *ArenaView {
+	a := &ArenaView{
+		ParentWindow: parent,
+		Settings:     settings,
+		InputEntry:   widget.NewEntry(),
+		AnswerA:      widget.NewLabel(""),
+		AnswerB:      widget.NewLabel(""),
+		Reveal:       widget.NewLabel("Two random models answer each prompt. Their names are shown after you vote."),
+		models:       models,
+	}
+	a.AnswerA.Wrapping = fyne.TextWrapWord
+	a.AnswerB.Wrapping = fyne.TextWrapWord
+	a.Reveal.Wrapping = fyne.TextWrapWord
+
+	a.InputEntry.SetPlaceHolder("Type a prompt for two anonymous models...")
+	a.InputEntry.OnSubmitted = func(string) {
+		a.send()
+	}
+	a.SendButton = widget.NewButton("Send", a.send)
+
+	votes := []struct {
+		label   string
+		outcome string
+	}{
+		{"A is better", ArenaWinA},
+		{"Tie", ArenaTie},
+		{"B is better", ArenaWinB},
+	}
+	for _, vote := range votes {
+		outcome := vote.outcome
+		button := widget.NewButton(vote.label, func() {
+			a.vote(outcome)
+		})
+		button.Disable()
+		a.VoteButtons = append(a.VoteButtons, button)
+	}
+
+	a.Leaderboard = widget.NewTableWithHeaders(
+		func() (int, int) { return len(a.ratings), len(leaderboardColumns) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(id widget.TableCellID, cell fyne.CanvasObject) {
+			cell.(*widget.Label).SetText(leaderboardRow(id.Row+1, a.ratings[id.Row])[id.Col])
+		},
+	)
+	a.Leaderboard.ShowHeaderColumn = false
+	a.Leaderboard.CreateHeader = func() fyne.CanvasObject {
+		return widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	}
+	a.Leaderboard.UpdateHeader = func(id widget.TableCellID, header fyne.CanvasObject) {
+		if id.Col >= 0 {
+			header.(*widget.Label).SetText(leaderboardColumns[id.Col])
+		}
+	}
+	a.Leaderboard.SetColumnWidth(0, 60)
+	a.Leaderboard.SetColumnWidth(1, 200)
+
+	a.ExportButton = widget.NewButtonWithIcon("Export CSV", theme.DocumentSaveIcon(), a.exportCSV)
+
+	a.refreshLeaderboard()
+	return a
+}
+
+// setVoting enables or disables the vote buttons
+func (a *ArenaView) setVoting(enabled bool) {
+	for _, button := range a.VoteButtons {
+		if enabled {
+			button.Enable()
+		} else {
+			button.Disable()
+		}
+	}
+}
+
+// send lets two different random models answer the prompt
+func (a *ArenaView) send() {
+	prompt := strings.TrimSpace(a.InputEntry.Text)
+	if prompt == "" {
+		return
+	}
+	if len(a.models) < 2 {
+		ShowError(fmt.Errorf("The arena needs at least two installed models"), a.ParentWindow)
+		return
+	}
+
+	picked := rand.Perm(len(a.models))
+	match := &ArenaMatch{
+		Time:   time.Now(),
+		Prompt: prompt,
+		ModelA: a.models[picked[0]],
+		ModelB: a.models[picked[1]],
+	}
+
+	a.current = nil
+	a.setVoting(false)
+	a.InputEntry.Disable()
+	a.SendButton.Disable()
+	a.Reveal.SetText("Both models are answering...")
+
+	client := NewOllamaClient()
+
+	var wg sync.WaitGroup
+	var failed error
+	var mu sync.Mutex
+	answer := func(modelName string, label *widget.Label, result *string) {
+		defer wg.Done()
+		label.SetText("Thinking...")
+		request := a.Settings.NewChatRequest(modelName, nil, []ChatMessage{{Role: RoleUser, Content: prompt}})
+		reply, err := client.ChatStream(context.Background(), request, label.SetText)
+		if err != nil {
+			// Don't show the error text, it may give the model away
+			label.SetText("This model failed to answer.")
+			mu.Lock()
+			failed = err
+			mu.Unlock()
+			return
+		}
+		*result = reply.Message.Content
+		label.SetText(reply.Message.Content)
+	}
+
+	wg.Add(2)
+	go answer(match.ModelA, a.AnswerA, &match.AnswerA)
+	go answer(match.ModelB, a.AnswerB, &match.AnswerB)
+
+	go func() {
+		wg.Wait()
+		a.InputEntry.Enable()
+		a.SendButton.Enable()
+		if failed != nil {
+			a.Reveal.SetText(fmt.Sprintf("No vote possible: %s vs %s, %v", match.ModelA, match.ModelB, failed))
+			return
+		}
+		a.current = match
+		a.InputEntry.SetText("")
+		a.Reveal.SetText("Which answer is better?")
+		a.setVoting(true)
+	}()
+}
+
+// vote records the verdict on the current match and reveals the models
+func (a *ArenaView) vote(outcome string) {
+	if a.current == nil {
+		return
+	}
+	match := *a.current
+	match.Outcome = outcome
+
+	if err := appendArenaMatch(match); err != nil {
+		ShowError(fmt.Errorf("Failed to save vote: %v", err), a.ParentWindow)
+		return
+	}
+	a.current = nil
+	a.setVoting(false)
+	a.Reveal.SetText(fmt.Sprintf("A was %s, B was %s", match.ModelA, match.ModelB))
+	a.refreshLeaderboard()
+}
+
+// refreshLeaderboard recomputes the ratings from all recorded matches
+func (a *ArenaView) refreshLeaderboard() {
+	matches, err := loadArenaMatches()
+	if err != nil {
+		ShowError(fmt.Errorf("Failed to load arena matches: %v", err), a.ParentWindow)
+		return
+	}
+	a.ratings = arenaLeaderboard(matches)
+	a.Leaderboard.Refresh()
+}
+
+// exportCSV saves the leaderboard to a file picked by the user
+func (a *ArenaView) exportCSV() {
+	save := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			ShowError(fmt.Errorf("Failed to export leaderboard: %v", err), a.ParentWindow)
+			return
+		}
+		if writer == nil {
+			return
+		}
+		defer writer.Close()
+
+		if err := writeLeaderboardCSV(writer, a.ratings); err != nil {
+			ShowError(fmt.Errorf("Failed to export leaderboard: %v", err), a.ParentWindow)
+		}
+	}, a.ParentWindow)
+	save.SetFileName("leaderboard.csv")
+	save.Show()
+}
+
+// GetContainer returns the arena view. It is built once and reused.
+func (a *ArenaView) GetContainer() *fyne.Container {
+	if a.content != nil {
+		return a.content
+	}
+
+	answerColumn := func(title string, answer *widget.Label) fyne.CanvasObject {
+		heading := widget.NewLabelWithStyle(title, fyne.TextAlignCenter, fyne.TextStyle{Bold: true})
+		return container.NewBorder(heading, nil, nil, nil, container.NewVScroll(answer))
+	}
+	answers := container.NewGridWithColumns(2,
+		answerColumn("Model A", a.AnswerA),
+		answerColumn("Model B", a.AnswerB),
+	)
+
+	voteBar := container.NewGridWithColumns(len(a.VoteButtons))
+	for _, button := range a.VoteButtons {
+		voteBar.Add(button)
+	}
+	prompt := container.NewBorder(nil, nil, nil, a.SendButton, a.InputEntry)
+	arena := container.NewBorder(a.Reveal, container.NewVBox(voteBar, prompt), nil, nil, answers)
+
+	title := widget.NewLabelWithStyle("Leaderboard", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	leaderboard := container.NewBorder(container.NewBorder(nil, nil, title, a.ExportButton), nil, nil, nil, a.Leaderboard)
+
+	split := container.NewVSplit(arena, leaderboard)
+	split.SetOffset(0.65)
+	a.content = container.NewStack(split)
+	return a.content
+}
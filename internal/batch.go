@@ -0,0 +1,325 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultBatchConcurrency is how many requests a batch job runs at once
+// unless told otherwise
+const defaultBatchConcurrency = 2
+
+// maxBatchConcurrency keeps a batch job from flooding the backend
+const maxBatchConcurrency = 16
+
+// ChatBackend is what chats and batch jobs need from a model server
+type ChatBackend interface {
+	ChatOnce(ctx context.Context, req *ChatRequest) (*ChatResponse, error)
+}
+
+// BatchRow is a prompt read from a dataset file
+type BatchRow struct {
+	ID     string `json:"id"`
+	Prompt string `json:"prompt"`
+	System string `json:"system,omitempty"`
+}
+
+// BatchResult is the outcome of one row for one model, written as a line
+// of the output file
+type BatchResult struct {
+	ID         string           `json:"id"`
+	Model      string           `json:"model"`
+	Prompt     string           `json:"prompt"`
+	Response   string           `json:"response,omitempty"`
+	Error      string           `json:"error,omitempty"`
+	Started    time.Time        `json:"started"`
+	DurationMs int64            `json:"durationMs"`
+	Stats      *GenerationStats `json:"stats,omitempty"`
+}
+
+// BatchJob describes a dataset to run through one or more models
+type BatchJob struct {
+	Input       string
+	Output      string
+	Models      []string
+	Concurrency int
+	// NewRequest builds the request for a model, applying the settings
+	NewRequest func(model string, messages []ChatMessage) *ChatRequest
+	Backend    ChatBackend
+
+	// OnProgress is called after each finished request
+	OnProgress func(done, total int, result BatchResult)
+}
+
+// BatchSummary counts what a batch job did
+type BatchSummary struct {
+	Total     int
+	Skipped   int
+	Succeeded int
+	Failed    int
+}
+
+// DefaultBatchOutput names the results file written next to a dataset
+func DefaultBatchOutput(input string) string {
+	return strings.TrimSuffix(input, filepath.Ext(input)) + ".results.jsonl"
+}
+
+// readBatchRows reads the prompts of a JSONL or CSV dataset
+func readBatchRows(path string) ([]BatchRow, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dataset: %v", err)
+	}
+	defer file.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return parseBatchCSV(file)
+	}
+	return parseBatchJSONL(file)
+}
+
+// parseBatchJSONL reads one {"prompt": ...} object per line. Rows without
+// an id are numbered by their line.
+func parseBatchJSONL(r io.Reader) ([]BatchRow, error) {
+	var rows []BatchRow
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 8*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var row BatchRow
+		if err := json.Unmarshal([]byte(text), &row); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		if strings.TrimSpace(row.Prompt) == "" {
+			return nil, fmt.Errorf("line %d: prompt is missing", line)
+		}
+		if row.ID == "" {
+			row.ID = strconv.Itoa(line)
+		}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read dataset: %v", err)
+	}
+	return rows, nil
+}
+
+// parseBatchCSV reads a CSV file whose header names a prompt column and,
+// optionally, id and system columns
+func parseBatchCSV(r io.Reader) ([]BatchRow, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dataset: %v", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	promptColumn, ok := columns["prompt"]
+	if !ok {
+		return nil, fmt.Errorf("the header has no prompt column")
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+
+	var rows []BatchRow
+	for i, record := range records[1:] {
+		line := i + 2
+		if promptColumn >= len(record) || strings.TrimSpace(record[promptColumn]) == "" {
+			return nil, fmt.Errorf("line %d: prompt is missing", line)
+		}
+		row := BatchRow{
+			ID:     field(record, "id"),
+			Prompt: record[promptColumn],
+			System: field(record, "system"),
+		}
+		if row.ID == "" {
+			row.ID = strconv.Itoa(line)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// batchKey identifies the result of a row for a model
+func batchKey(id, model string) string {
+	return id + "\x00" + model
+}
+
+// finishedBatchResults returns the rows a previous run of the job already
+// answered, so they can be skipped. Failed rows are run again.
+func finishedBatchResults(output string) (map[string]bool, error) {
+	finished := map[string]bool{}
+	err := readJSONLines(output, func(line []byte) {
+		var result BatchResult
+		if json.Unmarshal(line, &result) == nil && result.Error == "" {
+			finished[batchKey(result.ID, result.Model)] = true
+		}
+	})
+	return finished, err
+}
+
+// RunBatch sends every row of the dataset to every model and appends the
+// results to the output file. Rows already answered in the output file are
+// skipped, so a cancelled job picks up where it stopped.
+func RunBatch(ctx context.Context, job BatchJob) (BatchSummary, error) {
+	var summary BatchSummary
+	if len(job.Models) == 0 {
+		return summary, fmt.Errorf("no models selected")
+	}
+	if job.Output == "" {
+		job.Output = DefaultBatchOutput(job.Input)
+	}
+	if job.Backend == nil {
+		job.Backend = NewOllamaClient()
+	}
+	concurrency := job.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+	concurrency = min(concurrency, maxBatchConcurrency)
+
+	rows, err := readBatchRows(job.Input)
+	if err != nil {
+		return summary, err
+	}
+	finished, err := finishedBatchResults(job.Output)
+	if err != nil {
+		return summary, err
+	}
+
+	if dir := filepath.Dir(job.Output); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return summary, fmt.Errorf("failed to create output directory: %v", err)
+		}
+	}
+	file, err := os.OpenFile(job.Output, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return summary, fmt.Errorf("failed to open output file: %v", err)
+	}
+	defer file.Close()
+
+	type task struct {
+		row   BatchRow
+		model string
+	}
+	var tasks []task
+	for _, row := range rows {
+		for _, model := range job.Models {
+			summary.Total++
+			if finished[batchKey(row.ID, model)] {
+				summary.Skipped++
+				continue
+			}
+			tasks = append(tasks, task{row, model})
+		}
+	}
+
+	var mu sync.Mutex
+	var writeErr error
+	done := summary.Skipped
+	record := func(result BatchResult) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		data, err := json.Marshal(result)
+		if err == nil {
+			_, err = file.Write(append(data, '\n'))
+		}
+		if err != nil && writeErr == nil {
+			writeErr = fmt.Errorf("failed to write result: %v", err)
+		}
+
+		done++
+		if result.Error == "" {
+			summary.Succeeded++
+		} else {
+			summary.Failed++
+		}
+		if job.OnProgress != nil {
+			job.OnProgress(done, summary.Total, result)
+		}
+	}
+
+	queue := make(chan task)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range queue {
+				record(runBatchRow(ctx, job, t.row, t.model))
+			}
+		}()
+	}
+
+feed:
+	for _, t := range tasks {
+		select {
+		case queue <- t:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	if writeErr != nil {
+		return summary, writeErr
+	}
+	return summary, ctx.Err()
+}
+
+// runBatchRow asks a model for the answer to a single row
+func runBatchRow(ctx context.Context, job BatchJob, row BatchRow, model string) BatchResult {
+	result := BatchResult{
+		ID:      row.ID,
+		Model:   model,
+		Prompt:  row.Prompt,
+		Started: time.Now(),
+	}
+
+	var messages []ChatMessage
+	if row.System != "" {
+		messages = append(messages, ChatMessage{Role: RoleSystem, Content: row.System})
+	}
+	messages = append(messages, ChatMessage{Role: RoleUser, Content: row.Prompt})
+
+	request := &ChatRequest{Model: model, Messages: messages, Stream: true}
+	if job.NewRequest != nil {
+		request = job.NewRequest(model, messages)
+	}
+
+	reply, err := job.Backend.ChatOnce(ctx, request)
+	result.DurationMs = time.Since(result.Started).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Response = reply.Message.Content
+	result.Stats = newGenerationStats(reply)
+	return result
+}
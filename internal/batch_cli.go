@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+)
+
+// RunBatchCommand runs the "batch" subcommand with its arguments and
+// returns the exit code
+func RunBatchCommand(args []string, stdout, stderr io.Writer) int {
+	settings, problems := LoadSettingsData()
+	for _, problem := range problems {
+		fmt.Fprintln(stderr, "settings:", problem)
+	}
+
+	flags := flag.NewFlagSet("batch", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	input := flags.String("input", "", "JSONL or CSV file with a prompt per row")
+	output := flags.String("output", "", "JSONL file the results are appended to (default: <input>.results.jsonl)")
+	models := flags.String("models", settings.Model, "comma separated models to run every prompt through")
+	concurrency := flags.Int("concurrency", defaultBatchConcurrency, "number of requests run at once")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: neuratalk batch -input prompts.jsonl [-models a,b] [-output results.jsonl] [-concurrency n]")
+		fmt.Fprintln(stderr, "Generation options are taken from the saved settings. Rows already in the output are skipped.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *input == "" {
+		flags.Usage()
+		return 2
+	}
+
+	var modelNames []string
+	for _, name := range strings.Split(*models, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			modelNames = append(modelNames, name)
+		}
+	}
+
+	// Stop handing out rows on Ctrl+C, the next run resumes from there
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	job := BatchJob{
+		Input:       *input,
+		Output:      *output,
+		Models:      modelNames,
+		Concurrency: *concurrency,
+		NewRequest: func(model string, messages []ChatMessage) *ChatRequest {
+			return settings.NewChatRequest(model, nil, messages)
+		},
+		OnProgress: func(done, total int, result BatchResult) {
+			status := "ok"
+			if result.Error != "" {
+				status = "error: " + result.Error
+			}
+			fmt.Fprintf(stderr, "[%d/%d] %s %s (%dms) %s\n", done, total, result.ID, result.Model, result.DurationMs, status)
+		},
+	}
+	if job.Output == "" {
+		job.Output = DefaultBatchOutput(job.Input)
+	}
+
+	summary, err := RunBatch(ctx, job)
+	fmt.Fprintf(stdout, "%d rows: %d succeeded, %d failed, %d already done. Results in %s\n",
+		summary.Total, summary.Succeeded, summary.Failed, summary.Skipped, job.Output)
+	if err != nil {
+		fmt.Fprintln(stderr, "Batch failed:", err)
+		return 1
+	}
+	if summary.Failed > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,194 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// BatchView runs a dataset of prompts through the selected models
+type BatchView struct {
+	ParentWindow fyne.Window
+	Settings     *Settings
+	InputEntry   *widget.Entry
+	OutputEntry  *widget.Entry
+	ModelChecks  *widget.CheckGroup
+	Concurrency  *widget.Select
+	StartButton  *widget.Button
+	CancelButton *widget.Button
+	Progress     *widget.ProgressBar
+	Status       *widget.Label
+	ErrorLog     *widget.Entry
+	cancel       context.CancelFunc
+	content      *fyne.Container
+}
+
+// NewBatchView creates the batch tab for the installed models
+func NewBatchView(models []string, parent fyne.Window, settings *Settings) *BatchView {
+	b := &BatchView{
+		ParentWindow: parent,
+		Settings:     settings,
+		InputEntry:   widget.NewEntry(),
+		OutputEntry:  widget.NewEntry(),
+		ModelChecks:  widget.NewCheckGroup(models, nil),
+		Progress:     widget.NewProgressBar(),
+		Status:       widget.NewLabel("Pick a JSONL or CSV file with a prompt per row."),
+		ErrorLog:     widget.NewMultiLineEntry(),
+	}
+
+	b.InputEntry.SetPlaceHolder("prompts.jsonl or prompts.csv")
+	b.InputEntry.OnChanged = func(text string) {
+		if text != "" {
+			b.OutputEntry.SetText(DefaultBatchOutput(text))
+		}
+	}
+	b.OutputEntry.SetPlaceHolder("results.jsonl")
+	b.ModelChecks.Horizontal = true
+	if model := settings.GetModel(); model != "" {
+		b.ModelChecks.SetSelected([]string{model})
+	}
+
+	var levels []string
+	for n := 1; n <= 8; n++ {
+		levels = append(levels, strconv.Itoa(n))
+	}
+	b.Concurrency = widget.NewSelect(levels, nil)
+	b.Concurrency.SetSelected(strconv.Itoa(defaultBatchConcurrency))
+
+	b.ErrorLog.SetPlaceHolder("Failed rows are listed here")
+	b.ErrorLog.Wrapping = fyne.TextWrapWord
+
+	b.StartButton = widget.NewButton("Start", b.start)
+	b.StartButton.Importance = widget.HighImportance
+	b.CancelButton = widget.NewButton("Cancel", func() {
+		if b.cancel != nil {
+			b.cancel()
+		}
+	})
+	b.CancelButton.Disable()
+	return b
+}
+
+// browseInput lets the user pick the dataset
+func (b *BatchView) browseInput() {
+	dialog.ShowFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			ShowError(fmt.Errorf("Failed to open dataset: %v", err), b.ParentWindow)
+			return
+		}
+		if reader == nil {
+			return
+		}
+		reader.Close()
+		b.InputEntry.SetText(reader.URI().Path())
+	}, b.ParentWindow)
+}
+
+// setRunning switches the controls between editing and running a job
+func (b *BatchView) setRunning(running bool) {
+	for _, w := range []fyne.Disableable{b.InputEntry, b.OutputEntry, b.ModelChecks, b.Concurrency, b.StartButton} {
+		if running {
+			w.Disable()
+		} else {
+			w.Enable()
+		}
+	}
+	if running {
+		b.CancelButton.Enable()
+	} else {
+		b.CancelButton.Disable()
+	}
+}
+
+// start runs the job in the background with the current settings
+func (b *BatchView) start() {
+	concurrency, _ := strconv.Atoi(b.Concurrency.Selected)
+	job := BatchJob{
+		Input:       b.InputEntry.Text,
+		Output:      b.OutputEntry.Text,
+		Models:      b.ModelChecks.Selected,
+		Concurrency: concurrency,
+		NewRequest: func(model string, messages []ChatMessage) *ChatRequest {
+			return b.Settings.NewChatRequest(model, nil, messages)
+		},
+	}
+	if job.Input == "" {
+		ShowError(fmt.Errorf("Please choose a dataset file"), b.ParentWindow)
+		return
+	}
+	if len(job.Models) == 0 {
+		ShowError(fmt.Errorf("Please select at least one model"), b.ParentWindow)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	b.cancel = cancel
+	b.Progress.SetValue(0)
+	b.ErrorLog.SetText("")
+	b.Status.SetText("Starting...")
+	b.setRunning(true)
+
+	job.OnProgress = func(done, total int, result BatchResult) {
+		b.Progress.Max = float64(total)
+		b.Progress.SetValue(float64(done))
+		b.Status.SetText(fmt.Sprintf("%d of %d done, last: %s with %s", done, total, result.ID, result.Model))
+		if result.Error != "" {
+			b.ErrorLog.Append(fmt.Sprintf("%s (%s): %s\n", result.ID, result.Model, result.Error))
+		}
+	}
+
+	go func() {
+		defer cancel()
+		summary, err := RunBatch(ctx, job)
+		b.setRunning(false)
+		b.cancel = nil
+
+		text := fmt.Sprintf("%d rows: %d succeeded, %d failed, %d already done", summary.Total, summary.Succeeded, summary.Failed, summary.Skipped)
+		if summary.Total > 0 {
+			b.Progress.Max = float64(summary.Total)
+			b.Progress.SetValue(float64(summary.Succeeded + summary.Failed + summary.Skipped))
+		}
+		switch {
+		case ctx.Err() != nil:
+			b.Status.SetText("Cancelled. Start again to resume. " + text)
+		case err != nil:
+			b.Status.SetText("Batch failed. " + text)
+			ShowError(fmt.Errorf("Failed to run batch: %v", err), b.ParentWindow)
+		default:
+			b.Status.SetText("Finished. " + text)
+		}
+	}()
+}
+
+// GetContainer returns the batch view. It is built once and reused.
+func (b *BatchView) GetContainer() *fyne.Container {
+	if b.content != nil {
+		return b.content
+	}
+
+	browse := widget.NewButton("Browse...", b.browseInput)
+	form := widget.NewForm(
+		widget.NewFormItem("Dataset", container.NewBorder(nil, nil, nil, browse, b.InputEntry)),
+		widget.NewFormItem("Results", b.OutputEntry),
+		widget.NewFormItem("Models", container.NewHScroll(b.ModelChecks)),
+		widget.NewFormItem("Parallel Requests", b.Concurrency),
+	)
+	note := widget.NewLabel("Prompts are sent with the generation settings from Options. Rows already in the results file are skipped, so a cancelled run can be resumed.")
+	note.Wrapping = fyne.TextWrapWord
+
+	top := container.NewVBox(
+		widget.NewLabelWithStyle("Batch Run", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		form,
+		note,
+		container.NewHBox(b.StartButton, b.CancelButton),
+		b.Progress,
+		b.Status,
+	)
+	b.content = container.NewBorder(top, nil, nil, nil, b.ErrorLog)
+	return b.content
+}
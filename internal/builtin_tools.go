@@ -0,0 +1,395 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// maxToolFileSize limits how much of a file read_file hands to the model
+const maxToolFileSize = 64 * 1024
+
+// RegisterBuiltinTools adds the tools that ship with NeuraTalk. read_file
+// only sees the directory chosen in the settings.
+func RegisterBuiltinTools(registry *ToolRegistry, settings *Settings) error {
+	tools := []Tool{
+		{
+			Name:        "calculator",
+			Description: "Evaluate an arithmetic expression. Supports + - * / % ^, parentheses, pi, e and the functions sqrt, abs, sin, cos, tan, log, ln, exp, floor, ceil and round.",
+			Parameters: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"expression": {"type": "string", "description": "The expression to evaluate, e.g. (2 + 3) * 4"}
+				},
+				"required": ["expression"]
+			}`),
+			Handler: calculatorTool,
+			Source:  "builtin",
+		},
+		{
+			Name:        "current_time",
+			Description: "Get the current date and time, optionally in a given IANA time zone.",
+			Parameters: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"timezone": {"type": "string", "description": "IANA time zone such as Europe/Berlin. Defaults to local time."}
+				}
+			}`),
+			Handler: currentTimeTool,
+			Source:  "builtin",
+		},
+		{
+			Name:        "read_file",
+			Description: "Read a text file, or list a directory, inside the folder the user shared with the assistant.",
+			Parameters: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"path": {"type": "string", "description": "Path relative to the shared folder"}
+				},
+				"required": ["path"]
+			}`),
+			Handler: func(ctx context.Context, args map[string]any) (string, error) {
+				return readFileTool(settings.GetToolsDirectory(), stringArg(args, "path"))
+			},
+			Source: "builtin",
+		},
+	}
+
+	for _, tool := range tools {
+		if err := registry.Register(tool); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func calculatorTool(ctx context.Context, args map[string]any) (string, error) {
+	expression := stringArg(args, "expression")
+	if strings.TrimSpace(expression) == "" {
+		return "", fmt.Errorf("expression is required")
+	}
+
+	result, err := evaluateExpression(expression)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatFloat(result, 'g', -1, 64), nil
+}
+
+func currentTimeTool(ctx context.Context, args map[string]any) (string, error) {
+	now := time.Now()
+	if name := stringArg(args, "timezone"); name != "" {
+		location, err := time.LoadLocation(name)
+		if err != nil {
+			return "", fmt.Errorf("unknown time zone %s", name)
+		}
+		now = now.In(location)
+	}
+	return now.Format("Monday, 02 January 2006 15:04:05 MST"), nil
+}
+
+// readFileTool reads a file below baseDir, refusing paths that escape it
+func readFileTool(baseDir, path string) (string, error) {
+	if baseDir == "" {
+		return "", fmt.Errorf("file access is disabled; the user has not shared a folder")
+	}
+	if path == "" {
+		path = "."
+	}
+
+	sharedDir, err := filepath.Abs(baseDir)
+	if err != nil {
+		return "", err
+	}
+	base, err := filepath.EvalSymlinks(sharedDir)
+	if err != nil {
+		return "", fmt.Errorf("shared folder is not available: %v", err)
+	}
+
+	target := filepath.Clean(path)
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(base, target)
+	}
+	// Check the path as given before touching the file system, so the
+	// model can't learn which files exist outside the shared folder
+	if !isWithin(base, target) && !isWithin(sharedDir, target) {
+		return "", fmt.Errorf("%s is outside the shared folder", path)
+	}
+	// Resolve symlinks so a link can't point outside the shared folder
+	target, err = filepath.EvalSymlinks(target)
+	if err != nil {
+		return "", fmt.Errorf("cannot open %s: %v", path, err)
+	}
+	if !isWithin(base, target) {
+		return "", fmt.Errorf("%s is outside the shared folder", path)
+	}
+
+	info, err := os.Stat(target)
+	if err != nil {
+		return "", fmt.Errorf("cannot open %s: %v", path, err)
+	}
+
+	if info.IsDir() {
+		entries, err := os.ReadDir(target)
+		if err != nil {
+			return "", fmt.Errorf("cannot list %s: %v", path, err)
+		}
+		var listing strings.Builder
+		for _, entry := range entries {
+			listing.WriteString(entry.Name())
+			if entry.IsDir() {
+				listing.WriteString("/")
+			}
+			listing.WriteString("\n")
+		}
+		return listing.String(), nil
+	}
+
+	file, err := os.Open(target)
+	if err != nil {
+		return "", fmt.Errorf("cannot open %s: %v", path, err)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxToolFileSize+1))
+	if err != nil {
+		return "", fmt.Errorf("cannot read %s: %v", path, err)
+	}
+	if len(data) > maxToolFileSize {
+		return string(data[:maxToolFileSize]) + "\n[truncated]", nil
+	}
+	return string(data), nil
+}
+
+// isWithin reports whether path is dir or below it
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// expressionParser is a small recursive descent parser for the calculator
+type expressionParser struct {
+	input []rune
+	pos   int
+}
+
+// evaluateExpression computes the value of an arithmetic expression
+func evaluateExpression(expression string) (float64, error) {
+	p := &expressionParser{input: []rune(expression)}
+	value, err := p.parseSum()
+	if err != nil {
+		return 0, err
+	}
+	p.skipSpaces()
+	if p.pos < len(p.input) {
+		return 0, fmt.Errorf("unexpected %q at position %d", p.input[p.pos], p.pos+1)
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, fmt.Errorf("result is not a finite number")
+	}
+	return value, nil
+}
+
+func (p *expressionParser) skipSpaces() {
+	for p.pos < len(p.input) && unicode.IsSpace(p.input[p.pos]) {
+		p.pos++
+	}
+}
+
+// consume skips the given operator if it comes next
+func (p *expressionParser) consume(op rune) bool {
+	p.skipSpaces()
+	if p.pos < len(p.input) && p.input[p.pos] == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *expressionParser) parseSum() (float64, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		switch {
+		case p.consume('+'):
+			right, err := p.parseProduct()
+			if err != nil {
+				return 0, err
+			}
+			left += right
+		case p.consume('-'):
+			right, err := p.parseProduct()
+			if err != nil {
+				return 0, err
+			}
+			left -= right
+		default:
+			return left, nil
+		}
+	}
+}
+
+func (p *expressionParser) parseProduct() (float64, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		switch {
+		case p.consume('*'):
+			right, err := p.parseUnary()
+			if err != nil {
+				return 0, err
+			}
+			left *= right
+		case p.consume('/'):
+			right, err := p.parseUnary()
+			if err != nil {
+				return 0, err
+			}
+			if right == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			left /= right
+		case p.consume('%'):
+			right, err := p.parseUnary()
+			if err != nil {
+				return 0, err
+			}
+			if right == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			left = math.Mod(left, right)
+		default:
+			return left, nil
+		}
+	}
+}
+
+func (p *expressionParser) parseUnary() (float64, error) {
+	if p.consume('-') {
+		value, err := p.parseUnary()
+		return -value, err
+	}
+	if p.consume('+') {
+		return p.parseUnary()
+	}
+	return p.parsePower()
+}
+
+func (p *expressionParser) parsePower() (float64, error) {
+	base, err := p.parseOperand()
+	if err != nil {
+		return 0, err
+	}
+	if p.consume('^') {
+		// Exponentiation is right associative
+		exponent, err := p.parseUnary()
+		if err != nil {
+			return 0, err
+		}
+		return math.Pow(base, exponent), nil
+	}
+	return base, nil
+}
+
+func (p *expressionParser) parseOperand() (float64, error) {
+	p.skipSpaces()
+	if p.pos >= len(p.input) {
+		return 0, fmt.Errorf("unexpected end of expression")
+	}
+
+	if p.consume('(') {
+		value, err := p.parseSum()
+		if err != nil {
+			return 0, err
+		}
+		if !p.consume(')') {
+			return 0, fmt.Errorf("missing closing parenthesis")
+		}
+		return value, nil
+	}
+
+	start := p.pos
+	r := p.input[p.pos]
+	switch {
+	case unicode.IsDigit(r) || r == '.':
+		for p.pos < len(p.input) && (unicode.IsDigit(p.input[p.pos]) || p.input[p.pos] == '.') {
+			p.pos++
+		}
+		// Allow exponents such as 1e-3
+		if p.pos < len(p.input) && (p.input[p.pos] == 'e' || p.input[p.pos] == 'E') {
+			next := p.pos + 1
+			if next < len(p.input) && (p.input[next] == '+' || p.input[next] == '-') {
+				next++
+			}
+			if next < len(p.input) && unicode.IsDigit(p.input[next]) {
+				p.pos = next
+				for p.pos < len(p.input) && unicode.IsDigit(p.input[p.pos]) {
+					p.pos++
+				}
+			}
+		}
+		value, err := strconv.ParseFloat(string(p.input[start:p.pos]), 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid number %q", string(p.input[start:p.pos]))
+		}
+		return value, nil
+	case unicode.IsLetter(r):
+		for p.pos < len(p.input) && unicode.IsLetter(p.input[p.pos]) {
+			p.pos++
+		}
+		return p.parseIdentifier(strings.ToLower(string(p.input[start:p.pos])))
+	}
+
+	return 0, fmt.Errorf("unexpected %q at position %d", r, p.pos+1)
+}
+
+// parseIdentifier evaluates a constant or a function call
+func (p *expressionParser) parseIdentifier(name string) (float64, error) {
+	switch name {
+	case "pi":
+		return math.Pi, nil
+	case "e":
+		return math.E, nil
+	}
+
+	functions := map[string]func(float64) float64{
+		"sqrt":  math.Sqrt,
+		"abs":   math.Abs,
+		"sin":   math.Sin,
+		"cos":   math.Cos,
+		"tan":   math.Tan,
+		"log":   math.Log10,
+		"ln":    math.Log,
+		"exp":   math.Exp,
+		"floor": math.Floor,
+		"ceil":  math.Ceil,
+		"round": math.Round,
+	}
+	fn, ok := functions[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown name %s", name)
+	}
+	if !p.consume('(') {
+		return 0, fmt.Errorf("expected ( after %s", name)
+	}
+	arg, err := p.parseSum()
+	if err != nil {
+		return 0, err
+	}
+	if !p.consume(')') {
+		return 0, fmt.Errorf("missing closing parenthesis")
+	}
+	return fn(arg), nil
+}
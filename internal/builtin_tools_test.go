@@ -0,0 +1,151 @@
+package internal
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEvaluateExpression(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       float64
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 - 4 - 3", 3},
+		{"24 / 4 / 2", 3},
+		{"7 % 4 * 2", 6},
+		{"2 * 3 ^ 2", 18},
+		{"2 ^ 3 ^ 2", 512},
+		{"(2 ^ 3) ^ 2", 64},
+		{"-2 ^ 2", -4},
+		{"(-2) ^ 2", 4},
+		{"2 ^ -1", 0.5},
+		{"2 ^ -2 ^ 2", 0.0625},
+		{"--3", 3},
+		{"-+3", -3},
+		{"1.5e3 + 1e-3", 1500.001},
+		{"sqrt(16) + abs(-2)", 6},
+		{"round(pi * 100)", 314},
+		{"ln(e)", 1},
+		{"  2*(3 + 4)  ", 14},
+	}
+	for _, tt := range tests {
+		got, err := evaluateExpression(tt.expression)
+		if err != nil {
+			t.Errorf("evaluateExpression(%q) failed: %v", tt.expression, err)
+			continue
+		}
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("evaluateExpression(%q) = %v, want %v", tt.expression, got, tt.want)
+		}
+	}
+}
+
+func TestEvaluateExpressionErrors(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       string
+	}{
+		{"1 / 0", "division by zero"},
+		{"1 / (2 - 2)", "division by zero"},
+		{"5 % 0", "division by zero"},
+		{"", "unexpected end of expression"},
+		{"1 +", "unexpected end of expression"},
+		{"2 ^", "unexpected end of expression"},
+		{"(1 + 2", "missing closing parenthesis"},
+		{"sqrt(4", "missing closing parenthesis"},
+		{"1 + 2)", "unexpected ')'"},
+		{"1 2", "unexpected '2'"},
+		{"1..2", "invalid number"},
+		{"2 * # 3", "unexpected '#'"},
+		{"foo(1)", "unknown name foo"},
+		{"sqrt 4", "expected ( after sqrt"},
+		{"sqrt(-1)", "not a finite number"},
+		{"10 ^ 400", "not a finite number"},
+	}
+	for _, tt := range tests {
+		_, err := evaluateExpression(tt.expression)
+		if err == nil {
+			t.Errorf("evaluateExpression(%q) succeeded, want an error", tt.expression)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("evaluateExpression(%q) error = %q, want %q", tt.expression, err, tt.want)
+		}
+	}
+}
+
+func TestReadFileTool(t *testing.T) {
+	root := t.TempDir()
+	shared := filepath.Join(root, "shared")
+	writeTestFile(t, filepath.Join(shared, "notes.txt"), "shared notes")
+	writeTestFile(t, filepath.Join(shared, "docs", "readme.md"), "readme")
+	writeTestFile(t, filepath.Join(shared, "..hidden"), "dots")
+	writeTestFile(t, filepath.Join(root, "secret.txt"), "secret")
+	writeTestFile(t, filepath.Join(root, "private", "key"), "key")
+
+	links := map[string]string{
+		filepath.Join(shared, "secret-link"):  filepath.Join(root, "secret.txt"),
+		filepath.Join(shared, "private-link"): filepath.Join(root, "private"),
+		filepath.Join(shared, "relative"):     filepath.Join("..", "secret.txt"),
+		filepath.Join(shared, "notes-link"):   "notes.txt",
+		filepath.Join(root, "shared-link"):    shared,
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, link); err != nil {
+			t.Skipf("symlinks are not supported: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		baseDir string
+		path    string
+		want    string
+		wantErr string
+	}{
+		{"file", shared, "notes.txt", "shared notes", ""},
+		{"nested file", shared, "docs/readme.md", "readme", ""},
+		{"listing", shared, "", "docs/\n", ""},
+		{"name starting with dots", shared, "..hidden", "dots", ""},
+		{"parent kept inside", shared, "docs/../notes.txt", "shared notes", ""},
+		{"link inside", shared, "notes-link", "shared notes", ""},
+		{"absolute path inside", shared, filepath.Join(shared, "notes.txt"), "shared notes", ""},
+		{"shared folder behind a link", filepath.Join(root, "shared-link"), "notes.txt", "shared notes", ""},
+		{"parent", shared, "..", "", "outside the shared folder"},
+		{"parent file", shared, "../secret.txt", "", "outside the shared folder"},
+		{"parent after a folder", shared, "docs/../../secret.txt", "", "outside the shared folder"},
+		{"absolute path outside", shared, filepath.Join(root, "secret.txt"), "", "outside the shared folder"},
+		{"link to a file outside", shared, "secret-link", "", "outside the shared folder"},
+		{"relative link outside", shared, "relative", "", "outside the shared folder"},
+		{"link to a folder outside", shared, "private-link", "", "outside the shared folder"},
+		{"through a link to a folder outside", shared, "private-link/key", "", "outside the shared folder"},
+		{"missing file", shared, "missing.txt", "", "cannot open"},
+		{"missing file outside", shared, "../missing.txt", "", "outside the shared folder"},
+		{"missing absolute path outside", shared, filepath.Join(root, "missing.txt"), "", "outside the shared folder"},
+		{"absolute path through the linked shared folder", filepath.Join(root, "shared-link"), filepath.Join(root, "shared-link", "notes.txt"), "shared notes", ""},
+		{"no shared folder", "", "notes.txt", "", "file access is disabled"},
+		{"missing shared folder", filepath.Join(root, "missing"), "notes.txt", "", "shared folder is not available"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readFileTool(tt.baseDir, tt.path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+var (
+	capabilitiesMu    sync.Mutex
+	capabilitiesCache = map[string][]string{}
+)
+
+// getModelCapabilities returns the capabilities a model advertises, such as
+// "completion" or "vision"
+func getModelCapabilities(modelName string) ([]string, error) {
+	capabilitiesMu.Lock()
+	cached, ok := capabilitiesCache[modelName]
+	capabilitiesMu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	ollamaPath, err := findOllamaBinary()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find ollama: %v", err)
+	}
+
+	cmd := exec.Command(ollamaPath, "show", modelName)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to show model %s: %v", modelName, err)
+	}
+
+	capabilities := parseModelCapabilities(out.String())
+
+	capabilitiesMu.Lock()
+	capabilitiesCache[modelName] = capabilities
+	capabilitiesMu.Unlock()
+
+	return capabilities, nil
+}
+
+// parseModelCapabilities reads the capabilities section of `ollama show`.
+// Older Ollama versions don't print one, but vision models always come with
+// a projector, so that is reported as vision support.
+func parseModelCapabilities(output string) []string {
+	var capabilities []string
+	section := ""
+	for _, line := range strings.Split(output, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+
+		// Section headers are indented less than their entries
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent <= 2 {
+			section = strings.ToLower(trimmed)
+			if section == "projector" {
+				capabilities = append(capabilities, "vision")
+			}
+			continue
+		}
+
+		if section == "capabilities" {
+			capabilities = append(capabilities, strings.ToLower(strings.Fields(trimmed)[0]))
+		}
+	}
+	return capabilities
+}
+
+// hasCapability reports whether the model advertises the given capability
+func hasCapability(modelName, capability string) bool {
+	if modelName == "" {
+		return false
+	}
+	capabilities, err := getModelCapabilities(modelName)
+	if err != nil {
+		return false
+	}
+	for _, c := range capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// SupportsVision reports whether the model accepts images
+func SupportsVision(modelName string) bool {
+	return hasCapability(modelName, "vision")
+}
+
+// SupportsTools reports whether the model can call tools
+func SupportsTools(modelName string) bool {
+	return hasCapability(modelName, "tools")
+}
@@ -0,0 +1,155 @@
+package internal
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// chartBar is one bar of a chart
+type chartBar struct {
+	Label string
+	Value float64
+	// Text is shown next to the bar, the value says how long it is
+	Text string
+}
+
+// barLabelWidth is the room the labels of a bar chart get
+const barLabelWidth = 200
+
+// chartHeight is the height of a column chart
+const chartHeight = 140
+
+// barLayout places a label, a bar as long as its share of the widest bar
+// and the value after it
+type barLayout struct {
+	share float32
+}
+
+func (l *barLayout) Layout(objects []fyne.CanvasObject, size fyne.Size) {
+	label, bar, value := objects[0], objects[1], objects[2]
+	padding := theme.Padding()
+	valueWidth := value.MinSize().Width
+
+	label.Resize(fyne.NewSize(barLabelWidth, size.Height))
+	label.Move(fyne.NewPos(0, 0))
+
+	room := max(size.Width-barLabelWidth-valueWidth-2*padding, 0)
+	width := max(room*l.share, 1)
+	barHeight := size.Height * 0.6
+	bar.Resize(fyne.NewSize(width, barHeight))
+	bar.Move(fyne.NewPos(barLabelWidth+padding, (size.Height-barHeight)/2))
+
+	value.Resize(fyne.NewSize(valueWidth, size.Height))
+	value.Move(fyne.NewPos(barLabelWidth+2*padding+width, 0))
+}
+
+func (l *barLayout) MinSize(objects []fyne.CanvasObject) fyne.Size {
+	height := max(objects[0].MinSize().Height, objects[2].MinSize().Height)
+	return fyne.NewSize(barLabelWidth+objects[2].MinSize().Width+4*theme.Padding(), height)
+}
+
+// newBarChart lists bars from top to bottom, each as long as its share of
+// the largest value
+func newBarChart(bars []chartBar) fyne.CanvasObject {
+	if len(bars) == 0 {
+		empty := widget.NewLabel("No data yet")
+		empty.Importance = widget.LowImportance
+		return empty
+	}
+
+	var largest float64
+	for _, bar := range bars {
+		largest = max(largest, bar.Value)
+	}
+	rows := container.NewVBox()
+	for _, bar := range bars {
+		share := float32(0)
+		if largest > 0 {
+			share = float32(bar.Value / largest)
+		}
+		label := widget.NewLabel(bar.Label)
+		label.Truncation = fyne.TextTruncateEllipsis
+		rect := canvas.NewRectangle(theme.Color(theme.ColorNamePrimary))
+		rect.CornerRadius = 2
+		rows.Add(container.New(&barLayout{share: share}, label, rect, widget.NewLabel(bar.Text)))
+	}
+	return rows
+}
+
+// columnsLayout stands columns next to each other, each as high as its
+// share of the largest value
+type columnsLayout struct {
+	shares []float32
+}
+
+func (l *columnsLayout) Layout(objects []fyne.CanvasObject, size fyne.Size) {
+	if len(objects) == 0 {
+		return
+	}
+	slot := size.Width / float32(len(objects))
+	gap := min(slot*0.2, theme.Padding())
+	for i, column := range objects {
+		height := max(size.Height*l.shares[i], 1)
+		column.Resize(fyne.NewSize(max(slot-gap, 1), height))
+		column.Move(fyne.NewPos(float32(i)*slot, size.Height-height))
+	}
+}
+
+func (l *columnsLayout) MinSize([]fyne.CanvasObject) fyne.Size {
+	return fyne.NewSize(float32(len(l.shares))*2, chartHeight)
+}
+
+// newColumnChart draws values as columns from left to right, labelling the
+// first, middle and last column below the chart
+func newColumnChart(bars []chartBar) fyne.CanvasObject {
+	if len(bars) == 0 {
+		empty := widget.NewLabel("No data yet")
+		empty.Importance = widget.LowImportance
+		return empty
+	}
+
+	var largest float64
+	for _, bar := range bars {
+		largest = max(largest, bar.Value)
+	}
+	shares := make([]float32, len(bars))
+	columns := make([]fyne.CanvasObject, len(bars))
+	for i, bar := range bars {
+		if largest > 0 {
+			shares[i] = float32(bar.Value / largest)
+		}
+		rect := canvas.NewRectangle(theme.Color(theme.ColorNamePrimary))
+		if bar.Value == 0 {
+			rect.FillColor = theme.Color(theme.ColorNameDisabled)
+		}
+		columns[i] = rect
+	}
+	chart := container.New(&columnsLayout{shares: shares}, columns...)
+
+	axis := container.NewHBox(
+		lowLabel(bars[0].Label),
+		layout.NewSpacer(),
+		lowLabel(bars[len(bars)/2].Label),
+		layout.NewSpacer(),
+		lowLabel(bars[len(bars)-1].Label),
+	)
+	peak := lowLabel("")
+	for _, bar := range bars {
+		if bar.Value == largest {
+			peak.SetText("Most: " + bar.Text + " on " + bar.Label)
+			break
+		}
+	}
+	return container.NewBorder(peak, axis, nil, nil, chart)
+}
+
+// lowLabel creates a quiet label for the parts of a chart
+func lowLabel(text string) *widget.Label {
+	label := widget.NewLabel(text)
+	label.Importance = widget.LowImportance
+	return label
+}
@@ -0,0 +1,363 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	minCompareModels = 2
+	maxCompareModels = 4
+)
+
+// ComparisonResult is the answer of one model in a comparison
+type ComparisonResult struct {
+	Model   string           `json:"model"`
+	Content string           `json:"content"`
+	Error   string           `json:"error,omitempty"`
+	Stats   *GenerationStats `json:"stats,omitempty"`
+}
+
+// Comparison is a prompt answered by several models and the user's verdict
+type Comparison struct {
+	Time    time.Time          `json:"time"`
+	Prompt  string             `json:"prompt"`
+	Results []ComparisonResult `json:"results"`
+	// Winner is the model voted best, empty for a tie
+	Winner string `json:"winner,omitempty"`
+	Tie    bool   `json:"tie,omitempty"`
+}
+
+// compareColumn shows the answer of one model
+type compareColumn struct {
+	ModelSelect *widget.Select
+	Answer      *widget.Label
+	Stats       *widget.Label
+	VoteButton  *widget.Button
+	scroll      *container.Scroll
+	content     fyne.CanvasObject
+}
+
+// CompareView sends one prompt to several models at once and shows their
+// answers side by side
+type CompareView struct {
+	ParentWindow fyne.Window
+	Settings     *Settings
+	InputEntry   *widget.Entry
+	SendButton   *widget.Button
+	StopButton   *widget.Button
+	CountSelect  *widget.Select
+	TieButton    *widget.Button
+	Tally        *widget.Label
+
+	models    []string
+	columns   []*compareColumn
+	columnBox *fyne.Container
+	current   *Comparison
+	content   *fyne.Container
+	// cancel stops the models that are still answering, nil when idle
+	cancel context.CancelFunc
+}
+
+// NewCompareView creates the comparison tab for the installed models
+func NewCompareView(models []string, parent fyne.Window, settings *Settings) *CompareView {
+	c := &CompareView{
+		ParentWindow: parent,
+		Settings:     settings,
+		InputEntry:   widget.NewEntry(),
+		Tally:        widget.NewLabel(""),
+		models:       models,
+		columnBox:    container.NewGridWithColumns(minCompareModels),
+	}
+
+	for i := 0; i < maxCompareModels; i++ {
+		c.columns = append(c.columns, c.newColumn(i))
+	}
+
+	c.InputEntry.SetPlaceHolder("Type a prompt for all models...")
+	c.InputEntry.OnSubmitted = func(string) {
+		c.send()
+	}
+	c.SendButton = widget.NewButton("Send", c.send)
+	c.StopButton = widget.NewButton("Stop", c.Stop)
+	c.StopButton.Disable()
+	c.TieButton = widget.NewButton("Tie", func() {
+		c.vote(-1)
+	})
+	c.TieButton.Disable()
+
+	var counts []string
+	for n := minCompareModels; n <= maxCompareModels; n++ {
+		counts = append(counts, strconv.Itoa(n))
+	}
+	c.CountSelect = widget.NewSelect(counts, func(value string) {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return
+		}
+		c.showColumns(n)
+	})
+	c.CountSelect.SetSelected(strconv.Itoa(minCompareModels))
+
+	c.refreshTally()
+	return c
+}
+
+// newColumn creates the widgets of a column, preselecting a different
+// model for each one
+func (c *CompareView) newColumn(index int) *compareColumn {
+	col := &compareColumn{
+		ModelSelect: widget.NewSelect(c.models, nil),
+		Answer:      widget.NewLabel(""),
+		Stats:       widget.NewLabel(""),
+	}
+	if len(c.models) > 0 {
+		col.ModelSelect.SetSelected(c.models[index%len(c.models)])
+	}
+	col.Answer.Wrapping = fyne.TextWrapWord
+	col.Stats.Wrapping = fyne.TextWrapWord
+	col.Stats.Importance = widget.LowImportance
+	col.VoteButton = widget.NewButton("Vote Best", func() {
+		c.vote(index)
+	})
+	col.VoteButton.Disable()
+
+	col.scroll = container.NewVScroll(col.Answer)
+	col.content = container.NewBorder(col.ModelSelect, container.NewVBox(col.Stats, col.VoteButton), nil, nil, col.scroll)
+	return col
+}
+
+// showColumns shows the first n columns
+func (c *CompareView) showColumns(n int) {
+	objects := make([]fyne.CanvasObject, 0, n)
+	for _, col := range c.columns[:n] {
+		objects = append(objects, col.content)
+	}
+	c.columnBox.Layout = container.NewGridWithColumns(n).Layout
+	c.columnBox.Objects = objects
+	c.columnBox.Refresh()
+}
+
+// activeColumns returns the columns currently shown
+func (c *CompareView) activeColumns() []*compareColumn {
+	n, err := strconv.Atoi(c.CountSelect.Selected)
+	if err != nil {
+		n = minCompareModels
+	}
+	return c.columns[:n]
+}
+
+// setBusy locks the controls while the models answer
+func (c *CompareView) setBusy(busy bool) {
+	for _, w := range []fyne.Disableable{c.InputEntry, c.SendButton, c.CountSelect} {
+		if busy {
+			w.Disable()
+		} else {
+			w.Enable()
+		}
+	}
+	for _, col := range c.columns {
+		if busy {
+			col.ModelSelect.Disable()
+		} else {
+			col.ModelSelect.Enable()
+		}
+	}
+	if busy {
+		c.StopButton.Enable()
+	} else {
+		c.StopButton.Disable()
+	}
+}
+
+// Stop cancels the answers still being generated. Stopped models can't be
+// voted for.
+func (c *CompareView) Stop() {
+	if c.cancel != nil {
+		c.cancel()
+	}
+}
+
+// setVoting enables the vote buttons of the models that answered the
+// current comparison, and the tie once two did, or disables them all
+func (c *CompareView) setVoting(enabled bool) {
+	answered := 0
+	for i, col := range c.columns {
+		if enabled && c.current != nil && i < len(c.current.Results) && c.current.Results[i].Error == "" {
+			col.VoteButton.Enable()
+			answered++
+		} else {
+			col.VoteButton.Disable()
+		}
+	}
+	if enabled && answered >= 2 {
+		c.TieButton.Enable()
+	} else {
+		c.TieButton.Disable()
+	}
+}
+
+// send asks every shown model for an answer to the prompt at the same time
+func (c *CompareView) send() {
+	prompt := strings.TrimSpace(c.InputEntry.Text)
+	if prompt == "" {
+		return
+	}
+
+	columns := c.activeColumns()
+	for _, col := range columns {
+		if col.ModelSelect.Selected == "" {
+			ShowError(fmt.Errorf("Please select a model for every column"), c.ParentWindow)
+			return
+		}
+	}
+
+	comparison := &Comparison{
+		Time:    time.Now(),
+		Prompt:  prompt,
+		Results: make([]ComparisonResult, len(columns)),
+	}
+	c.current = nil
+	c.setVoting(false)
+	c.setBusy(true)
+
+	client := NewOllamaClient()
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+
+	var wg sync.WaitGroup
+	for i, col := range columns {
+		modelName := col.ModelSelect.Selected
+		comparison.Results[i].Model = modelName
+		col.Answer.SetText("Thinking...")
+		col.Stats.SetText("")
+
+		wg.Add(1)
+		go func(i int, col *compareColumn) {
+			defer wg.Done()
+
+			request := c.Settings.NewChatRequest(modelName, nil, []ChatMessage{{Role: RoleUser, Content: prompt}})
+			reply, err := client.ChatStream(ctx, request, func(text string) {
+				col.Answer.SetText(text)
+				col.scroll.ScrollToBottom()
+			})
+			if err != nil && ctx.Err() != nil {
+				comparison.Results[i].Error = "stopped"
+				col.Answer.SetText(col.Answer.Text + "\n\n[stopped]")
+				return
+			}
+			if err != nil {
+				comparison.Results[i].Error = err.Error()
+				col.Answer.SetText("Error: " + err.Error())
+				return
+			}
+
+			comparison.Results[i].Content = reply.Message.Content
+			comparison.Results[i].Stats = newGenerationStats(reply)
+			col.Answer.SetText(reply.Message.Content)
+			col.Stats.SetText(comparison.Results[i].Stats.String())
+		}(i, col)
+	}
+
+	go func() {
+		wg.Wait()
+		cancel()
+		c.cancel = nil
+		c.current = comparison
+		c.setBusy(false)
+		c.setVoting(true)
+		c.InputEntry.SetText("")
+	}()
+}
+
+// vote records the column voted best, or a tie for -1
+func (c *CompareView) vote(index int) {
+	if c.current == nil {
+		return
+	}
+	comparison := *c.current
+	if index < 0 {
+		comparison.Tie = true
+	} else if index < len(comparison.Results) {
+		comparison.Winner = comparison.Results[index].Model
+	} else {
+		return
+	}
+
+	if err := appendComparison(comparison); err != nil {
+		ShowError(fmt.Errorf("Failed to save vote: %v", err), c.ParentWindow)
+		return
+	}
+	c.current = nil
+	c.setVoting(false)
+	c.refreshTally()
+}
+
+// refreshTally shows how often each model has won so far
+func (c *CompareView) refreshTally() {
+	comparisons, err := loadComparisons()
+	if err != nil {
+		c.Tally.SetText(err.Error())
+		return
+	}
+
+	wins := map[string]int{}
+	ties := 0
+	for _, comparison := range comparisons {
+		if comparison.Tie {
+			ties++
+		} else if comparison.Winner != "" {
+			wins[comparison.Winner]++
+		}
+	}
+	if len(wins) == 0 && ties == 0 {
+		c.Tally.SetText("No votes yet")
+		return
+	}
+
+	names := make([]string, 0, len(wins))
+	for name := range wins {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if wins[names[i]] != wins[names[j]] {
+			return wins[names[i]] > wins[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	parts := make([]string, 0, len(names)+1)
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s %d", name, wins[name]))
+	}
+	if ties > 0 {
+		parts = append(parts, fmt.Sprintf("%d ties", ties))
+	}
+	c.Tally.SetText("Wins: " + strings.Join(parts, " · "))
+}
+
+// GetContainer returns the comparison view. It is built once and reused.
+func (c *CompareView) GetContainer() *fyne.Container {
+	if c.content != nil {
+		return c.content
+	}
+
+	top := container.NewBorder(nil, nil,
+		container.NewHBox(widget.NewLabel("Models:"), c.CountSelect),
+		c.TieButton,
+		c.Tally,
+	)
+	bottom := container.NewBorder(nil, nil, nil, container.NewHBox(c.SendButton, c.StopButton), c.InputEntry)
+
+	c.content = container.NewBorder(top, bottom, nil, nil, c.columnBox)
+	return c.content
+}
@@ -0,0 +1,83 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestVotingSkipsFailedModels(t *testing.T) {
+	s := newTestSettings(t)
+	c := NewCompareView([]string{"llama3", "mistral", "gemma"}, s.Window, s)
+	c.CountSelect.SetSelected("3")
+
+	tests := []struct {
+		name    string
+		results []ComparisonResult
+		votes   []bool
+		tie     bool
+	}{
+		{"all answered", []ComparisonResult{{Model: "llama3"}, {Model: "mistral"}, {Model: "gemma"}}, []bool{true, true, true}, true},
+		{"one failed", []ComparisonResult{{Model: "llama3"}, {Model: "mistral", Error: "model not found"}, {Model: "gemma"}}, []bool{true, false, true}, true},
+		{"one answered", []ComparisonResult{{Model: "llama3", Error: "stopped"}, {Model: "mistral"}, {Model: "gemma", Error: "timeout"}}, []bool{false, true, false}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.current = &Comparison{Prompt: "Hi", Results: tt.results}
+			c.setVoting(true)
+			for i, want := range tt.votes {
+				if got := !c.columns[i].VoteButton.Disabled(); got != want {
+					t.Errorf("vote for %s enabled = %v, want %v", tt.results[i].Model, got, want)
+				}
+			}
+			if !c.columns[3].VoteButton.Disabled() {
+				t.Error("vote of a hidden column is enabled")
+			}
+			if got := !c.TieButton.Disabled(); got != tt.tie {
+				t.Errorf("tie enabled = %v, want %v", got, tt.tie)
+			}
+		})
+	}
+
+	c.setVoting(false)
+	for _, col := range c.columns {
+		if !col.VoteButton.Disabled() {
+			t.Error("vote stays enabled after voting")
+		}
+	}
+}
+
+func TestStopEndsHungComparison(t *testing.T) {
+	// A model that never answers
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+	t.Setenv("OLLAMA_HOST", server.URL)
+
+	s := newTestSettings(t)
+	c := NewCompareView([]string{"llama3", "mistral"}, s.Window, s)
+	c.InputEntry.SetText("Hi")
+	c.send()
+	if c.StopButton.Disabled() {
+		t.Fatal("stop is not offered while the models answer")
+	}
+
+	c.Stop()
+	deadline := time.Now().Add(2 * time.Second)
+	for c.SendButton.Disabled() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if c.SendButton.Disabled() {
+		t.Fatal("comparison still runs after stopping")
+	}
+	if !c.StopButton.Disabled() {
+		t.Error("stop is still offered after stopping")
+	}
+	for _, col := range c.columns[:2] {
+		if !col.VoteButton.Disabled() {
+			t.Errorf("stopped %s can be voted for", col.ModelSelect.Selected)
+		}
+	}
+}
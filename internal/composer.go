@@ -0,0 +1,231 @@
+package internal
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Key combinations that send a message from the composer
+const (
+	SendKeyEnter      = "Enter"
+	SendKeyShiftEnter = "Shift+Enter"
+	SendKeyCtrlEnter  = "Ctrl+Enter"
+)
+
+// sendKeyNames are the send keys offered in the settings
+var sendKeyNames = []string{SendKeyEnter, SendKeyShiftEnter, SendKeyCtrlEnter}
+
+// composerRows is how many lines the composer shows before it scrolls
+const composerRows = 3
+
+// Composer is the multiline editor messages are written in. The send key
+// is configurable, earlier prompts can be recalled with Up and Down and
+// the size of the message is counted below it.
+type Composer struct {
+	widget.Entry
+
+	// OnSend is called with the text when the send key is pressed
+	OnSend func(text string)
+	// OnPaste is offered the text pasted into the composer. It reports
+	// whether it used the text, which is then not inserted.
+	OnPaste func(text string) bool
+	// OnShortcut is offered the shortcuts the composer doesn't use itself.
+	// It reports whether it used the shortcut.
+	OnShortcut func(shortcut fyne.Shortcut) bool
+	// Counter shows the length of the message and how to send it
+	Counter *widget.Label
+
+	// sendKey returns the selected send key
+	sendKey func() string
+	// history returns the earlier prompts, oldest first
+	history func() []string
+	// historyIndex is the recalled prompt, -1 while writing a new one
+	historyIndex int
+	recalling    bool
+	shiftDown    bool
+	// refocus gives the focus back once the composer is enabled again
+	refocus bool
+}
+
+// NewComposer creates a composer. sendKey and history may be nil.
+func NewComposer(sendKey func() string, history func() []string) *Composer {
+	c := &Composer{
+		Counter:      widget.NewLabel(""),
+		sendKey:      sendKey,
+		history:      history,
+		historyIndex: -1,
+	}
+	c.MultiLine = true
+	c.Wrapping = fyne.TextWrapWord
+	c.SetMinRowsVisible(composerRows)
+	c.Counter.Importance = widget.LowImportance
+	c.ExtendBaseWidget(c)
+
+	c.Entry.OnChanged = c.changed
+	c.updateCounter()
+	return c
+}
+
+// currentSendKey returns the send key, Enter unless configured otherwise
+func (c *Composer) currentSendKey() string {
+	if c.sendKey == nil {
+		return SendKeyEnter
+	}
+	return c.sendKey()
+}
+
+// sendHint explains how to send and how to start a new line
+func (c *Composer) sendHint() string {
+	switch c.currentSendKey() {
+	case SendKeyShiftEnter:
+		return "Shift+Enter to send, Enter for a new line"
+	case SendKeyCtrlEnter:
+		return "Ctrl+Enter to send, Enter for a new line"
+	}
+	return "Enter to send, Shift+Enter for a new line"
+}
+
+// updateCounter shows the length of the message
+func (c *Composer) updateCounter() {
+	text := c.Text
+	c.Counter.SetText(fmt.Sprintf("%d characters · ~%d tokens · %s",
+		utf8.RuneCountInString(text), estimateTokens(text), c.sendHint()))
+}
+
+func (c *Composer) changed(text string) {
+	// Editing a recalled prompt makes it a new one
+	if !c.recalling {
+		c.historyIndex = -1
+	}
+	c.updateCounter()
+}
+
+// Disable stops editing while a reply is generated. The focus is given up
+// so window shortcuts, such as stopping the reply, keep working.
+func (c *Composer) Disable() {
+	c.refocus = false
+	if canvas := fyne.CurrentApp().Driver().CanvasForObject(c); canvas != nil && canvas.Focused() == c {
+		canvas.Unfocus()
+		c.refocus = true
+	}
+	c.Entry.Disable()
+}
+
+// Enable allows editing again, taking back the focus Disable gave up
+func (c *Composer) Enable() {
+	c.Entry.Enable()
+	if c.refocus {
+		c.refocus = false
+		if canvas := fyne.CurrentApp().Driver().CanvasForObject(c); canvas != nil {
+			canvas.Focus(c)
+		}
+	}
+}
+
+// send hands the text to OnSend
+func (c *Composer) send() {
+	if c.OnSend != nil && !c.Disabled() {
+		c.OnSend(c.Text)
+	}
+}
+
+// recall shows an earlier prompt, step -1 going back and 1 forward. It
+// reports whether the key was used for it.
+func (c *Composer) recall(step int) bool {
+	if c.history == nil || (c.Text != "" && c.historyIndex < 0) {
+		return false
+	}
+	prompts := c.history()
+	if len(prompts) == 0 {
+		return false
+	}
+
+	index := c.historyIndex
+	if index < 0 {
+		if step > 0 {
+			return false
+		}
+		index = len(prompts)
+	}
+	index = min(max(index+step, 0), len(prompts))
+
+	// Going past the latest prompt leaves an empty message again
+	text := ""
+	c.historyIndex = -1
+	if index < len(prompts) {
+		text = prompts[index]
+		c.historyIndex = index
+	}
+	c.recalling = true
+	c.SetText(text)
+	c.recalling = false
+
+	// Continue writing at the end of the recalled prompt
+	c.Entry.TypedKey(&fyne.KeyEvent{Name: fyne.KeyPageDown})
+	return true
+}
+
+// KeyDown notes whether Shift is held for the send key
+func (c *Composer) KeyDown(key *fyne.KeyEvent) {
+	if key.Name == desktop.KeyShiftLeft || key.Name == desktop.KeyShiftRight {
+		c.shiftDown = true
+	}
+	c.Entry.KeyDown(key)
+}
+
+// KeyUp notes that Shift was released
+func (c *Composer) KeyUp(key *fyne.KeyEvent) {
+	if key.Name == desktop.KeyShiftLeft || key.Name == desktop.KeyShiftRight {
+		c.shiftDown = false
+	}
+	c.Entry.KeyUp(key)
+}
+
+// TypedKey sends or adds a line on Enter and recalls prompts with Up and
+// Down while the composer is empty or shows a recalled prompt
+func (c *Composer) TypedKey(key *fyne.KeyEvent) {
+	switch key.Name {
+	case fyne.KeyReturn, fyne.KeyEnter:
+		sendKey := c.currentSendKey()
+		if (sendKey == SendKeyEnter && !c.shiftDown) || (sendKey == SendKeyShiftEnter && c.shiftDown) {
+			c.send()
+			return
+		}
+	case fyne.KeyUp:
+		if c.recall(-1) {
+			return
+		}
+	case fyne.KeyDown:
+		if c.recall(1) {
+			return
+		}
+	}
+	// Without OnSubmitted the entry adds a line on Enter
+	c.Entry.TypedKey(key)
+}
+
+// TypedShortcut sends on Ctrl+Enter whatever the send key is. Pasted text
+// is offered to OnPaste first. Window shortcuts are handed to OnShortcut,
+// the focused composer receiving them instead of the window.
+func (c *Composer) TypedShortcut(shortcut fyne.Shortcut) {
+	if paste, ok := shortcut.(*fyne.ShortcutPaste); ok && c.OnPaste != nil && paste.Clipboard != nil {
+		if c.OnPaste(paste.Clipboard.Content()) {
+			return
+		}
+	}
+	if s, ok := shortcut.(*desktop.CustomShortcut); ok {
+		isEnter := s.KeyName == fyne.KeyReturn || s.KeyName == fyne.KeyEnter
+		if isEnter && (s.Modifier == fyne.KeyModifierControl || s.Modifier == fyne.KeyModifierSuper) {
+			c.send()
+			return
+		}
+	}
+	if c.OnShortcut != nil && c.OnShortcut(shortcut) {
+		return
+	}
+	c.Entry.TypedShortcut(shortcut)
+}
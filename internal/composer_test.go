@@ -0,0 +1,51 @@
+package internal
+
+import (
+	"testing"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/test"
+)
+
+func TestComposerOffersOnlyPastedText(t *testing.T) {
+	a := test.NewApp()
+	t.Cleanup(a.Quit)
+	w := test.NewWindow(nil)
+	prompts := []string{"/home/me/shot.png"}
+	c := NewComposer(nil, func() []string { return prompts })
+	w.SetContent(c)
+
+	var offered []string
+	c.OnPaste = func(text string) bool {
+		offered = append(offered, text)
+		return text == "/home/me/shot.png"
+	}
+
+	// Typed and recalled text is never offered
+	test.Type(c, "/home/me/shot.png")
+	c.SetText("")
+	c.TypedKey(&fyne.KeyEvent{Name: fyne.KeyUp})
+	if c.Text != "/home/me/shot.png" {
+		t.Fatalf("recalled %q", c.Text)
+	}
+	if len(offered) != 0 {
+		t.Errorf("offered typed or recalled text %q", offered)
+	}
+
+	// Used pastes are not inserted, others are
+	c.SetText("Look at ")
+	c.CursorColumn = len(c.Text)
+	w.Clipboard().SetContent("/home/me/shot.png")
+	c.TypedShortcut(&fyne.ShortcutPaste{Clipboard: w.Clipboard()})
+	if c.Text != "Look at " {
+		t.Errorf("used paste was inserted: %q", c.Text)
+	}
+	w.Clipboard().SetContent("this")
+	c.TypedShortcut(&fyne.ShortcutPaste{Clipboard: w.Clipboard()})
+	if c.Text != "Look at this" {
+		t.Errorf("text after paste = %q, want %q", c.Text, "Look at this")
+	}
+	if len(offered) != 2 {
+		t.Errorf("offered %q, want both pastes", offered)
+	}
+}
@@ -0,0 +1,215 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// messageTokenOverhead accounts for the role markers around each message
+	messageTokenOverhead = 4
+	// imageTokenEstimate is roughly what vision models spend on one image
+	imageTokenEstimate = 768
+	// summaryWordLimit keeps the running summary small compared to the context
+	summaryWordLimit = 250
+)
+
+// estimateTokens guesses the token count of text. Without the model's
+// tokenizer this uses the usual rule of thumb of about four characters per
+// token, and never less than the number of words.
+func estimateTokens(text string) int {
+	if text == "" {
+		return 0
+	}
+	tokens := utf8.RuneCountInString(text) / 4
+	if words := len(strings.Fields(text)) * 4 / 3; words > tokens {
+		tokens = words
+	}
+	return tokens + 1
+}
+
+// messageTokens returns the tokens a message takes up in the context.
+// Replies carry the exact count reported by the backend.
+func messageTokens(m Message) int {
+	if m.Tokens > 0 {
+		return m.Tokens + messageTokenOverhead
+	}
+	tokens := estimateTokens(m.Content) + len(m.Images)*imageTokenEstimate
+	for _, call := range m.ToolCalls {
+		tokens += estimateTokens(formatToolCall(call))
+	}
+	return tokens + messageTokenOverhead
+}
+
+// summaryMessage carries the running summary to the model
+func summaryMessage(summary string) Message {
+	return Message{
+		Role:    RoleSystem,
+		Content: "Summary of the earlier conversation:\n" + summary,
+	}
+}
+
+// contextBudget is how many tokens the conversation may use, leaving room
+// for the reply
+func contextBudget(numCtx, numPredict int) int {
+	budget := numCtx - numPredict
+	if budget < numCtx/4 {
+		budget = numCtx / 4
+	}
+	return budget
+}
+
+// activeMessages returns the messages that haven't been folded into the summary
+func activeMessages(messages []Message) []Message {
+	active := make([]Message, 0, len(messages))
+	for _, m := range messages {
+		if !m.Summarized {
+			active = append(active, m)
+		}
+	}
+	return active
+}
+
+// contextTokens counts the tokens sent for the given messages and summary
+func contextTokens(messages []Message, summary string) int {
+	tokens := 0
+	if summary != "" {
+		tokens += messageTokens(summaryMessage(summary))
+	}
+	for _, m := range messages {
+		tokens += messageTokens(m)
+	}
+	return tokens
+}
+
+// selectOverflow picks the oldest messages to drop until the conversation
+// fits the budget. Pinned messages and everything from the latest prompt on
+// are kept, and a tool result never loses the call it answers.
+func selectOverflow(messages []Message, summary string, budget int) []int {
+	total := contextTokens(activeMessages(messages), summary)
+	if total <= budget {
+		return nil
+	}
+
+	lastPrompt := len(messages) - 1
+	for lastPrompt > 0 && messages[lastPrompt].Role != RoleUser {
+		lastPrompt--
+	}
+
+	var overflow []int
+	for i := 0; i < lastPrompt && total > budget; i++ {
+		m := messages[i]
+		if m.Summarized || m.Pinned {
+			continue
+		}
+		overflow = append(overflow, i)
+		total -= messageTokens(m)
+
+		// Take the results of a tool call along with it
+		for i+1 < lastPrompt && messages[i+1].Role == RoleTool && !messages[i+1].Summarized {
+			i++
+			overflow = append(overflow, i)
+			total -= messageTokens(messages[i])
+		}
+	}
+	return overflow
+}
+
+// summarizeMessages asks the model to fold messages into the running summary
+func summarizeMessages(ctx context.Context, client ChatBackend, modelName string, options map[string]any, summary string, messages []Message) (string, error) {
+	var prompt strings.Builder
+	fmt.Fprintf(&prompt, "Update the summary of a conversation between a user and an AI assistant. Keep names, facts, decisions and open questions. Answer with the summary only, in at most %d words.\n\n", summaryWordLimit)
+	if summary != "" {
+		fmt.Fprintf(&prompt, "Current summary:\n%s\n\n", summary)
+	}
+	prompt.WriteString("New messages:\n")
+	for _, m := range messages {
+		prompt.WriteString(m.String())
+		prompt.WriteString("\n\n")
+	}
+
+	reply, err := client.ChatOnce(ctx, &ChatRequest{
+		Model:    modelName,
+		Messages: []ChatMessage{{Role: RoleUser, Content: prompt.String()}},
+		Stream:   true,
+		Options:  options,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	newSummary := strings.TrimSpace(reply.Message.Content)
+	if newSummary == "" {
+		return "", fmt.Errorf("the model returned an empty summary")
+	}
+	return newSummary, nil
+}
+
+// fitContext makes the conversation fit the context window of the model.
+// Old messages are summarized when enabled, otherwise (or when summarizing
+// fails) they are left out of the request. The project messages are always
+// sent first. It returns the messages to send and the updated summary;
+// history is updated in place.
+func (io *InputOutput) fitContext(ctx context.Context, client ChatBackend, modelName string, project []Message, history []Message, summary string) ([]Message, string) {
+	budget := io.contextBudget() - contextTokens(project, "")
+	overflow := selectOverflow(history, summary, budget)
+
+	if len(overflow) > 0 && io.Settings.IsSummarizeContextEnabled() {
+		folded := make([]Message, 0, len(overflow))
+		for _, i := range overflow {
+			folded = append(folded, history[i])
+		}
+
+		newSummary, err := summarizeMessages(ctx, client, modelName, io.parameters().Options(), summary, folded)
+		if err == nil {
+			for _, i := range overflow {
+				history[i].Summarized = true
+			}
+			summary = newSummary
+			overflow = nil
+		}
+	}
+
+	dropped := map[int]bool{}
+	for _, i := range overflow {
+		dropped[i] = true
+	}
+
+	send := make([]Message, 0, len(project)+len(history)+1)
+	send = append(send, project...)
+	if summary != "" {
+		send = append(send, summaryMessage(summary))
+	}
+	for i, m := range history {
+		if !m.Summarized && !dropped[i] {
+			send = append(send, m)
+		}
+	}
+	return send, summary
+}
+
+// contextBudget returns the token budget of the conversation
+func (io *InputOutput) contextBudget() int {
+	params := io.parameters()
+	return contextBudget(int(params.ContextLength), int(params.MaxTokens))
+}
+
+// updateContextMeter shows how much of the context window the chat uses
+func (io *InputOutput) updateContextMeter() {
+	if io.ContextMeter == nil {
+		return
+	}
+	budget := io.contextBudget()
+	used := contextTokens(activeMessages(io.Messages), io.Summary)
+	if project, err := io.projectContext(); err == nil {
+		used += contextTokens(project, "")
+	}
+
+	io.ContextMeter.Max = float64(budget)
+	io.ContextMeter.TextFormatter = func() string {
+		return fmt.Sprintf("~%d / %d tokens", used, budget)
+	}
+	io.ContextMeter.SetValue(float64(min(used, budget)))
+}
@@ -0,0 +1,346 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"image/color"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// noColor is the choice for conversations without a color tag
+const noColor = "None"
+
+// maxTitleLength keeps generated titles short enough for a tab
+const maxTitleLength = 60
+
+// titleTimeout bounds how long a title may take to generate
+const titleTimeout = 60 * time.Second
+
+// conversationColors are the color tags offered for conversations
+var conversationColors = []struct {
+	Name string
+	Hex  string
+}{
+	{"Red", "#e5484d"},
+	{"Orange", "#f76b15"},
+	{"Yellow", "#ffc53d"},
+	{"Green", "#30a46c"},
+	{"Blue", "#0090ff"},
+	{"Purple", "#8e4ec6"},
+}
+
+// colorTagHex returns the hex value of a color tag
+func colorTagHex(name string) (string, bool) {
+	for _, c := range conversationColors {
+		if c.Name == name {
+			return c.Hex, true
+		}
+	}
+	return "", false
+}
+
+// colorTagColor returns a color tag for drawing, transparent for none
+func colorTagColor(name string) color.Color {
+	if hex, ok := colorTagHex(name); ok {
+		if c, err := parseHexColor(hex); err == nil {
+			return c
+		}
+	}
+	return color.Transparent
+}
+
+// ConversationIcon is the tab icon of a conversation: a dot in its color
+// tag, or the document icon without one
+func ConversationIcon(info ConversationInfo) fyne.Resource {
+	hex, ok := colorTagHex(info.Color)
+	if !ok {
+		return theme.DocumentIcon()
+	}
+	svg := fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 24 24"><circle cx="12" cy="12" r="7" fill="%s"/></svg>`, hex)
+	return fyne.NewStaticResource("tag-"+strings.ToLower(info.Color)+".svg", []byte(svg))
+}
+
+// cleanTitle turns the answer of a model into a tab title
+func cleanTitle(text string) string {
+	text = strings.TrimSpace(text)
+	if line, _, found := strings.Cut(text, "\n"); found {
+		text = strings.TrimSpace(line)
+	}
+	text = strings.TrimPrefix(text, "Title:")
+	text = strings.Trim(strings.TrimSpace(text), "\"'*#`. ")
+	if runes := []rune(text); len(runes) > maxTitleLength {
+		text = strings.TrimSpace(string(runes[:maxTitleLength])) + "…"
+	}
+	return text
+}
+
+// generateTitle asks the model to name a conversation
+func generateTitle(ctx context.Context, backend ChatBackend, settings SettingsData, modelName string, messages []Message) (string, error) {
+	var transcript strings.Builder
+	for _, m := range messages {
+		if m.Role == RoleUser || m.Role == RoleAssistant {
+			transcript.WriteString(m.String())
+			transcript.WriteString("\n\n")
+		}
+	}
+
+	request := settings.NewChatRequest(modelName, nil, []ChatMessage{
+		{Role: RoleSystem, Content: "You name conversations. Answer with a title of at most six words and nothing else."},
+		{Role: RoleUser, Content: "Write a title for this conversation:\n\n" + transcript.String()},
+	})
+	request.Format = ""
+
+	reply, err := backend.ChatOnce(ctx, request)
+	if err != nil {
+		return "", err
+	}
+	title := cleanTitle(reply.Message.Content)
+	if title == "" {
+		return "", fmt.Errorf("the model gave an empty answer")
+	}
+	return title, nil
+}
+
+// chatSummary describes a running chat for the history list
+type chatSummary struct {
+	Model    string
+	Info     ConversationInfo
+	Messages int
+	Updated  time.Time
+}
+
+// Label returns the text the history shows for a chat
+func (c chatSummary) Label() string {
+	if c.Info.Title != "" {
+		return c.Info.Title
+	}
+	return c.Model
+}
+
+// listChats returns the running chat of every model, pinned chats first
+// and the others by their last change
+func listChats(withArchived bool) []chatSummary {
+	var chats []chatSummary
+	seen := map[string]bool{}
+	for _, stored := range readStoredConversations(chatsDirectory()) {
+		// Chats in the old text format may sit next to their converted file
+		if seen[stored.Model] {
+			continue
+		}
+		seen[stored.Model] = true
+		if stored.Conversation.Archived && !withArchived {
+			continue
+		}
+		if len(stored.Conversation.Messages) == 0 && stored.Conversation.Title == "" && stored.Conversation.Project == "" {
+			continue
+		}
+		summary := chatSummary{
+			Model:    stored.Model,
+			Info:     stored.Conversation.ConversationInfo,
+			Messages: len(stored.Conversation.Messages),
+		}
+		if info, err := os.Stat(stored.Path); err == nil {
+			summary.Updated = info.ModTime()
+		}
+		chats = append(chats, summary)
+	}
+
+	sort.SliceStable(chats, func(i, j int) bool {
+		if chats[i].Info.Pinned != chats[j].Info.Pinned {
+			return chats[i].Info.Pinned
+		}
+		return chats[i].Updated.After(chats[j].Updated)
+	})
+	return chats
+}
+
+// setInfo stores new labels of the chat and reports the change
+func (io *InputOutput) setInfo(info ConversationInfo) {
+	io.Info = info
+	if io.SelectedModel != "" {
+		if err := saveConversation(io.SelectedModel, io.conversation()); err != nil {
+			ShowError(fmt.Errorf("Failed to save conversation: %v", err), io.ParentWindow)
+		}
+	}
+	io.changed()
+}
+
+// SetArchived archives the conversation of the chat or brings it back
+func (io *InputOutput) SetArchived(archived bool) {
+	if io.Info.Archived == archived {
+		return
+	}
+	info := io.Info
+	info.Archived = archived
+	io.setInfo(info)
+}
+
+// Close stops a reply being generated and saves the chat when its tab is
+// closed. Conversations with messages are archived, chats that were never
+// used are left as they are.
+func (io *InputOutput) Close() {
+	if io.stopGeneration != nil {
+		io.stopGeneration()
+	}
+	io.stopAnimation()
+	if io.SelectedModel == "" {
+		return
+	}
+	if len(io.Messages) == 0 {
+		if err := saveConversation(io.SelectedModel, io.conversation()); err != nil {
+			ShowError(fmt.Errorf("Failed to save conversation: %v", err), io.ParentWindow)
+		}
+		return
+	}
+	info := io.Info
+	info.Archived = true
+	io.setInfo(info)
+}
+
+// changed tells the owner of the chat that it was saved or relabelled
+func (io *InputOutput) changed() {
+	if io.OnConversationChanged != nil {
+		io.OnConversationChanged()
+	}
+}
+
+// nameAfterFirstExchange generates a title once the first question was
+// answered, unless the chat already has one
+func (io *InputOutput) nameAfterFirstExchange(modelName string) {
+	if io.Info.Title != "" || io.Settings == nil || !io.Settings.IsAutoTitleEnabled() {
+		return
+	}
+	exchanges := 0
+	for _, m := range io.Messages {
+		if m.Role == RoleAssistant && len(m.ToolCalls) == 0 {
+			exchanges++
+		}
+	}
+	if exchanges != 1 {
+		return
+	}
+
+	messages := io.Messages
+	settings := io.Settings.data()
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), titleTimeout)
+		defer cancel()
+
+		title, err := generateTitle(ctx, NewOllamaClient(), settings, modelName, messages)
+		if err != nil {
+			// Chats work fine without a title, so only note the failure
+			slog.Warn("Failed to generate title", "err", err)
+			return
+		}
+		if io.SelectedModel != modelName || io.Info.Title != "" {
+			return
+		}
+		info := io.Info
+		info.Title = title
+		io.setInfo(info)
+	}()
+}
+
+// showDetails lets the user rename, pin, archive, color-tag the chat and
+// move it to a project
+func (io *InputOutput) showDetails() {
+	modelName := io.SelectedModel
+	if modelName == "" {
+		dialog.ShowInformation("Conversation", "Please select a model first.", io.ParentWindow)
+		return
+	}
+
+	titleEntry := widget.NewEntry()
+	titleEntry.SetText(io.Info.Title)
+	titleEntry.SetPlaceHolder(modelName)
+
+	var generateButton *widget.Button
+	generateButton = widget.NewButtonWithIcon("", theme.ViewRefreshIcon(), func() {
+		if len(io.Messages) == 0 {
+			dialog.ShowInformation("Conversation", "Send a message before generating a title.", io.ParentWindow)
+			return
+		}
+		generateButton.Disable()
+		messages := io.Messages
+		settings := io.Settings.data()
+		go func() {
+			defer generateButton.Enable()
+			ctx, cancel := context.WithTimeout(context.Background(), titleTimeout)
+			defer cancel()
+
+			title, err := generateTitle(ctx, NewOllamaClient(), settings, modelName, messages)
+			if err != nil {
+				ShowError(fmt.Errorf("Failed to generate title: %v", err), io.ParentWindow)
+				return
+			}
+			titleEntry.SetText(title)
+		}()
+	})
+
+	pinned := widget.NewCheck("Pinned", nil)
+	pinned.SetChecked(io.Info.Pinned)
+	archived := widget.NewCheck("Archived", nil)
+	archived.SetChecked(io.Info.Archived)
+
+	colors := []string{noColor}
+	for _, c := range conversationColors {
+		colors = append(colors, c.Name)
+	}
+	colorSelect := widget.NewSelect(colors, nil)
+	colorSelect.SetSelected(noColor)
+	if _, ok := colorTagHex(io.Info.Color); ok {
+		colorSelect.SetSelected(io.Info.Color)
+	}
+
+	// Projects that were deleted are not offered again
+	projectNames := []string{noProject}
+	projects, err := loadProjects()
+	if err != nil {
+		ShowError(fmt.Errorf("Failed to load projects: %v", err), io.ParentWindow)
+	}
+	for _, p := range projects {
+		projectNames = append(projectNames, p.Name)
+	}
+	projectSelect := widget.NewSelect(projectNames, nil)
+	projectSelect.SetSelected(noProject)
+	if _, ok := findProject(projects, io.Info.Project); ok {
+		projectSelect.SetSelected(io.Info.Project)
+	}
+
+	form := widget.NewForm(
+		widget.NewFormItem("Title", container.NewBorder(nil, nil, nil, generateButton, titleEntry)),
+		widget.NewFormItem("Project", projectSelect),
+		widget.NewFormItem("Color", colorSelect),
+		widget.NewFormItem("", pinned),
+		widget.NewFormItem("", archived),
+	)
+	d := dialog.NewCustomConfirm("Conversation with "+modelName, "Save", "Cancel", form, func(save bool) {
+		if !save {
+			return
+		}
+		info := ConversationInfo{
+			Title:    strings.TrimSpace(titleEntry.Text),
+			Pinned:   pinned.Checked,
+			Archived: archived.Checked,
+		}
+		if colorSelect.Selected != noColor {
+			info.Color = colorSelect.Selected
+		}
+		if projectSelect.Selected != noProject {
+			info.Project = projectSelect.Selected
+		}
+		io.setInfo(info)
+	}, io.ParentWindow)
+	d.Resize(fyne.NewSize(460, 0))
+	d.Show()
+}
@@ -0,0 +1,188 @@
+package internal
+
+import (
+	"sort"
+	"time"
+)
+
+// noProjectPersona names the conversations outside any project
+const noProjectPersona = "No project"
+
+// maxLongestConversations is how many conversations the dashboard ranks
+const maxLongestConversations = 10
+
+// DayUsage counts the messages of one day
+type DayUsage struct {
+	Day      time.Time
+	Messages int
+}
+
+// ModelUsage counts the prompts sent to a model and its replies
+type ModelUsage struct {
+	Model   string
+	Prompts int
+	Replies int
+}
+
+// ConversationLength describes one of the longest conversations
+type ConversationLength struct {
+	Model    string
+	Label    string
+	Messages int
+}
+
+// PersonaUsage counts how much a project, with its system prompt and
+// knowledge, was talked to
+type PersonaUsage struct {
+	Name          string
+	Conversations int
+	Messages      int
+}
+
+// UsageReport sums up how the local models are used
+type UsageReport struct {
+	// Days are the days of the period, oldest first, including empty ones
+	Days          []DayUsage
+	Models        []ModelUsage
+	Speed         []ModelStats
+	Longest       []ConversationLength
+	Personas      []PersonaUsage
+	Messages      int
+	Conversations int
+}
+
+// usageMessageKey identifies a message across the copies of a chat the
+// store keeps. Old messages without a time are told apart by their text.
+func usageMessageKey(model string, m Message) string {
+	key := model + "\x00" + m.Role + "\x00" + m.Time.UTC().Format(time.RFC3339Nano)
+	if m.Time.IsZero() {
+		key += "\x00" + m.Content
+	}
+	return key
+}
+
+// distinctConversations keeps one copy of every conversation: the running
+// chat and its history snapshots start with the same message, and the
+// longest copy is the most complete one
+func distinctConversations(conversations []storedConversation) []storedConversation {
+	longest := map[string]storedConversation{}
+	var order []string
+	for _, stored := range conversations {
+		messages := stored.Conversation.Messages
+		if len(messages) == 0 {
+			continue
+		}
+		key := usageMessageKey(stored.Model, messages[0])
+		kept, ok := longest[key]
+		if !ok {
+			order = append(order, key)
+		}
+		if !ok || len(messages) > len(kept.Conversation.Messages) {
+			longest[key] = stored
+		}
+	}
+	distinct := make([]storedConversation, 0, len(order))
+	for _, key := range order {
+		distinct = append(distinct, longest[key])
+	}
+	return distinct
+}
+
+// collectUsage computes the usage of the last days days from the store
+func collectUsage(conversations []storedConversation, days int, now time.Time) UsageReport {
+	report := UsageReport{Speed: collectModelStats(conversations)}
+
+	// Every day of the period, so quiet days show as gaps
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	first := today.AddDate(0, 0, -(days - 1))
+	dayIndex := map[string]int{}
+	for i := 0; i < days; i++ {
+		day := first.AddDate(0, 0, i)
+		dayIndex[day.Format("2006-01-02")] = i
+		report.Days = append(report.Days, DayUsage{Day: day})
+	}
+
+	byModel := map[string]*ModelUsage{}
+	seen := map[string]bool{}
+	for _, stored := range conversations {
+		for _, m := range stored.Conversation.Messages {
+			if m.Role != RoleUser && m.Role != RoleAssistant {
+				continue
+			}
+			key := usageMessageKey(stored.Model, m)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			report.Messages++
+
+			usage, ok := byModel[stored.Model]
+			if !ok {
+				usage = &ModelUsage{Model: stored.Model}
+				byModel[stored.Model] = usage
+			}
+			if m.Role == RoleUser {
+				usage.Prompts++
+			} else {
+				usage.Replies++
+			}
+
+			if i, ok := dayIndex[m.Time.In(now.Location()).Format("2006-01-02")]; ok && !m.Time.IsZero() {
+				report.Days[i].Messages++
+			}
+		}
+	}
+	for _, usage := range byModel {
+		report.Models = append(report.Models, *usage)
+	}
+	sort.Slice(report.Models, func(i, j int) bool {
+		a, b := report.Models[i], report.Models[j]
+		if a.Prompts+a.Replies != b.Prompts+b.Replies {
+			return a.Prompts+a.Replies > b.Prompts+b.Replies
+		}
+		return a.Model < b.Model
+	})
+
+	personas := map[string]*PersonaUsage{}
+	for _, stored := range distinctConversations(conversations) {
+		report.Conversations++
+		label := stored.Conversation.Title
+		if label == "" {
+			label = stored.Model
+		}
+		report.Longest = append(report.Longest, ConversationLength{
+			Model:    stored.Model,
+			Label:    label,
+			Messages: len(stored.Conversation.Messages),
+		})
+
+		name := stored.Conversation.Project
+		if name == "" {
+			name = noProjectPersona
+		}
+		persona, ok := personas[name]
+		if !ok {
+			persona = &PersonaUsage{Name: name}
+			personas[name] = persona
+		}
+		persona.Conversations++
+		persona.Messages += len(stored.Conversation.Messages)
+	}
+	sort.SliceStable(report.Longest, func(i, j int) bool {
+		return report.Longest[i].Messages > report.Longest[j].Messages
+	})
+	if len(report.Longest) > maxLongestConversations {
+		report.Longest = report.Longest[:maxLongestConversations]
+	}
+	for _, persona := range personas {
+		report.Personas = append(report.Personas, *persona)
+	}
+	sort.Slice(report.Personas, func(i, j int) bool {
+		a, b := report.Personas[i], report.Personas[j]
+		if a.Messages != b.Messages {
+			return a.Messages > b.Messages
+		}
+		return a.Name < b.Name
+	})
+	return report
+}
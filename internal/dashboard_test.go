@@ -0,0 +1,76 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCollectUsageCountsCopiesOnce(t *testing.T) {
+	now := time.Date(2026, 6, 10, 15, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time {
+		return now.Add(time.Duration(minutes-60) * time.Minute)
+	}
+	chat := []Message{
+		{Role: RoleSystem, Content: "Be brief", Time: at(0)},
+		{Role: RoleUser, Content: "Hello", Time: at(1)},
+		{Role: RoleAssistant, Content: "Hi", Time: at(2)},
+		{Role: RoleUser, Content: "How are you?", Time: at(3)},
+		{Role: RoleAssistant, Content: "Fine", Time: at(4)},
+	}
+	untimed := []Message{
+		{Role: RoleUser, Content: "Old question"},
+		{Role: RoleAssistant, Content: "Old answer"},
+	}
+	otherUntimed := []Message{
+		{Role: RoleUser, Content: "Another question"},
+		{Role: RoleAssistant, Content: "Another answer"},
+	}
+	// The same messages read back in another time zone
+	local := make([]Message, len(chat))
+	copy(local, chat)
+	for i := range local {
+		local[i].Time = local[i].Time.In(time.FixedZone("CEST", 2*60*60))
+	}
+	stored := func(model string, messages []Message) storedConversation {
+		return storedConversation{Model: model, Conversation: Conversation{Messages: messages}}
+	}
+
+	tests := []struct {
+		name          string
+		stored        []storedConversation
+		messages      int
+		conversations int
+		prompts       int
+		today         int
+	}{
+		{"chat only", []storedConversation{stored("llama3", chat)}, 4, 1, 2, 4},
+		{"chat and identical snapshot", []storedConversation{stored("llama3", chat), stored("llama3", chat)}, 4, 1, 2, 4},
+		{"chat and shorter snapshot", []storedConversation{stored("llama3", chat[:3]), stored("llama3", chat)}, 4, 1, 2, 4},
+		{"snapshot read in another zone", []storedConversation{stored("llama3", chat), stored("llama3", local)}, 4, 1, 2, 4},
+		{"untimed chat and snapshot", []storedConversation{stored("llama3", untimed), stored("llama3", untimed[:1])}, 2, 1, 1, 0},
+		{"different untimed chats", []storedConversation{stored("llama3", untimed), stored("llama3", otherUntimed)}, 4, 2, 2, 0},
+		{"same chat of two models", []storedConversation{stored("llama3", untimed), stored("mistral", untimed)}, 4, 2, 2, 0},
+		{"timed and untimed", []storedConversation{stored("llama3", chat), stored("llama3", untimed), stored("llama3", chat), stored("llama3", untimed)}, 6, 2, 3, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := collectUsage(tt.stored, 7, now)
+			if report.Messages != tt.messages {
+				t.Errorf("messages = %d, want %d", report.Messages, tt.messages)
+			}
+			if report.Conversations != tt.conversations {
+				t.Errorf("conversations = %d, want %d", report.Conversations, tt.conversations)
+			}
+			prompts := 0
+			for _, usage := range report.Models {
+				prompts += usage.Prompts
+			}
+			if prompts != tt.prompts {
+				t.Errorf("prompts = %d, want %d", prompts, tt.prompts)
+			}
+			if got := report.Days[len(report.Days)-1].Messages; got != tt.today {
+				t.Errorf("messages today = %d, want %d", got, tt.today)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package internal
+
+import (
+	"fmt"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// dashboardPeriods are the periods the dashboard charts messages over
+var dashboardPeriods = map[string]int{
+	"Last 7 days":  7,
+	"Last 30 days": 30,
+	"Last 90 days": 90,
+}
+
+// dashboardPeriodNames lists the periods in the order they are offered
+var dashboardPeriodNames = []string{"Last 7 days", "Last 30 days", "Last 90 days"}
+
+// DashboardView charts how the local models are used, computed from the
+// conversation store
+type DashboardView struct {
+	PeriodSelect *widget.Select
+	Summary      *widget.Label
+
+	charts  *fyne.Container
+	content *fyne.Container
+}
+
+// NewDashboardView creates the dashboard
+func NewDashboardView() *DashboardView {
+	v := &DashboardView{
+		Summary: widget.NewLabel(""),
+		charts:  container.NewVBox(),
+	}
+	v.PeriodSelect = widget.NewSelect(dashboardPeriodNames, func(string) {
+		v.Refresh()
+	})
+	v.PeriodSelect.SetSelected("Last 30 days")
+	return v
+}
+
+// Refresh reads the store again and redraws the charts
+func (v *DashboardView) Refresh() {
+	days := dashboardPeriods[v.PeriodSelect.Selected]
+	if days == 0 {
+		days = 30
+	}
+	report := collectUsage(loadStoredConversations(), days, time.Now())
+
+	if report.Messages == 0 {
+		v.Summary.SetText("No conversations yet. Usage is counted from your saved chats.")
+	} else {
+		v.Summary.SetText(fmt.Sprintf("%d messages in %d conversations with %d models", report.Messages, report.Conversations, len(report.Models)))
+	}
+
+	var perDay []chartBar
+	for _, day := range report.Days {
+		perDay = append(perDay, chartBar{
+			Label: day.Day.Format("Jan 2"),
+			Value: float64(day.Messages),
+			Text:  fmt.Sprintf("%d messages", day.Messages),
+		})
+	}
+
+	var perModel []chartBar
+	for _, usage := range report.Models {
+		perModel = append(perModel, chartBar{
+			Label: usage.Model,
+			Value: float64(usage.Prompts + usage.Replies),
+			Text:  fmt.Sprintf("%d prompts · %d replies", usage.Prompts, usage.Replies),
+		})
+	}
+
+	var latency, speed []chartBar
+	for _, stats := range report.Speed {
+		latency = append(latency, chartBar{
+			Label: stats.Model,
+			Value: stats.AverageDuration().Seconds(),
+			Text:  fmt.Sprintf("%s · first token %s", formatStatDuration(stats.AverageDuration()), formatStatDuration(stats.AverageTimeToFirstToken())),
+		})
+		speed = append(speed, chartBar{
+			Label: stats.Model,
+			Value: stats.TokensPerSecond(),
+			Text:  fmt.Sprintf("%.1f tok/s", stats.TokensPerSecond()),
+		})
+	}
+
+	var longest []chartBar
+	for _, conversation := range report.Longest {
+		label := conversation.Label
+		if label != conversation.Model {
+			label = fmt.Sprintf("%s (%s)", label, conversation.Model)
+		}
+		longest = append(longest, chartBar{
+			Label: label,
+			Value: float64(conversation.Messages),
+			Text:  fmt.Sprintf("%d messages", conversation.Messages),
+		})
+	}
+
+	var personas []chartBar
+	for _, persona := range report.Personas {
+		personas = append(personas, chartBar{
+			Label: persona.Name,
+			Value: float64(persona.Messages),
+			Text:  fmt.Sprintf("%d messages in %d conversations", persona.Messages, persona.Conversations),
+		})
+	}
+
+	v.charts.Objects = []fyne.CanvasObject{
+		newDashboardCard("Messages per Day", newColumnChart(perDay)),
+		newDashboardCard("Usage per Model", newBarChart(perModel)),
+		newDashboardCard("Average Reply Time", newBarChart(latency)),
+		newDashboardCard("Generation Speed", newBarChart(speed)),
+		newDashboardCard("Longest Conversations", newBarChart(longest)),
+		newDashboardCard("Most Used Projects", newBarChart(personas)),
+	}
+	v.charts.Refresh()
+}
+
+// newDashboardCard puts a chart under its title
+func newDashboardCard(title string, chart fyne.CanvasObject) fyne.CanvasObject {
+	return widget.NewCard("", "", container.NewVBox(
+		widget.NewLabelWithStyle(title, fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		chart,
+	))
+}
+
+// GetContainer returns the dashboard. It is built once and reused.
+func (v *DashboardView) GetContainer() *fyne.Container {
+	if v.content != nil {
+		return v.content
+	}
+
+	refreshButton := widget.NewButtonWithIcon("Refresh", theme.ViewRefreshIcon(), v.Refresh)
+	title := widget.NewLabelWithStyle("Dashboard", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	header := container.NewBorder(nil, nil, title, container.NewHBox(v.PeriodSelect, refreshButton), container.NewHBox(v.Summary))
+
+	v.content = container.NewBorder(header, nil, nil, nil, container.NewVScroll(v.charts))
+	return v.content
+}
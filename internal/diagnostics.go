@@ -0,0 +1,192 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// diagnosticsTimeout bounds how long the connectivity checks may take
+const diagnosticsTimeout = 5 * time.Second
+
+// DiagnosticItem is a named fact about the running app
+type DiagnosticItem struct {
+	Name  string
+	Value string
+}
+
+// DiagnosticCheck is the outcome of one check
+type DiagnosticCheck struct {
+	Name   string
+	OK     bool
+	Detail string
+}
+
+// buildVersion describes the build of NeuraTalk and the version of a
+// dependency, e.g. fyne.io/fyne/v2, read from the executable
+func buildVersion(dependency string) (string, string) {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown", "unknown"
+	}
+
+	version := info.Main.Version
+	if version == "" || version == "(devel)" {
+		version = "development build"
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" && len(setting.Value) >= 7 {
+			version += " (" + setting.Value[:7] + ")"
+		}
+	}
+
+	dependencyVersion := "unknown"
+	for _, dep := range info.Deps {
+		if dep.Path == dependency {
+			dependencyVersion = dep.Version
+		}
+	}
+	return version, dependencyVersion
+}
+
+// diagnosticInfo lists the versions and directories of the running app
+func diagnosticInfo() []DiagnosticItem {
+	version, fyneVersion := buildVersion("fyne.io/fyne/v2")
+	encryption := "off"
+	if HistoryEncrypted() {
+		encryption = "on"
+	}
+	return []DiagnosticItem{
+		{"NeuraTalk", version},
+		{"Go", runtime.Version()},
+		{"Fyne", fyneVersion},
+		{"System", runtime.GOOS + "/" + runtime.GOARCH},
+		{"Ollama address", NewOllamaClient().BaseURL},
+		{"Config directory", ConfigDir()},
+		{"Data directory", DataDir()},
+		{"Cache directory", CacheDir()},
+		{"Log file", logFilePath()},
+		{"Log level", logLevel.Level().String()},
+		{"History encryption", encryption},
+	}
+}
+
+// runDiagnosticChecks checks that Ollama can be reached, models are
+// installed, the data directory is writable and the MCP servers run.
+// mcpStatus may be nil when no MCP servers are managed.
+func runDiagnosticChecks(servers []MCPServerConfig, mcpStatus func(string) string) []DiagnosticCheck {
+	ctx, cancel := context.WithTimeout(context.Background(), diagnosticsTimeout)
+	defer cancel()
+
+	var checks []DiagnosticCheck
+	add := func(name string, err error, detail string) {
+		check := DiagnosticCheck{Name: name, OK: err == nil, Detail: detail}
+		if err != nil {
+			check.Detail = err.Error()
+		}
+		checks = append(checks, check)
+	}
+
+	client := NewOllamaClient()
+	start := time.Now()
+	version, err := client.Version(ctx)
+	add("Ollama server", err, fmt.Sprintf("version %s, answered in %s", version, time.Since(start).Round(time.Millisecond)))
+
+	path, err := findOllamaBinary()
+	add("Ollama command", err, path)
+
+	models, err := GetAvailableModels()
+	add("Installed models", err, fmt.Sprintf("%d models", len(models)))
+
+	add("Data directory", checkWritable(DataDir()), "writable")
+
+	for _, server := range servers {
+		if !server.Enabled {
+			continue
+		}
+		status := "not started"
+		if mcpStatus != nil {
+			status = mcpStatus(server.Name)
+		}
+		checks = append(checks, DiagnosticCheck{
+			Name:   "MCP server " + server.Name,
+			OK:     strings.HasPrefix(status, "running"),
+			Detail: status,
+		})
+	}
+	return checks
+}
+
+// checkWritable creates and removes a file in a directory, creating the
+// directory first when it is missing
+func checkWritable(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create %s: %v", dir, err)
+	}
+	file, err := os.CreateTemp(dir, ".write-check-*")
+	if err != nil {
+		return fmt.Errorf("failed to write to %s: %v", dir, err)
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
+
+// redactedSettings hides the arguments and environment of MCP servers,
+// which often carry tokens
+func redactedSettings(settings SettingsData) SettingsData {
+	servers := make([]MCPServerConfig, len(settings.MCPServers))
+	for i, server := range settings.MCPServers {
+		args := make([]string, len(server.Args))
+		for j := range args {
+			args[j] = "REDACTED"
+		}
+		env := map[string]string{}
+		for key := range server.Env {
+			env[key] = "REDACTED"
+		}
+		server.Args = args
+		server.Env = env
+		servers[i] = server
+	}
+	settings.MCPServers = servers
+	return settings
+}
+
+// debugBundle describes the app for a bug report: versions, check results,
+// settings without secrets and the recent log. Conversations are left out.
+func debugBundle(info []DiagnosticItem, checks []DiagnosticCheck, settings SettingsData, logLines []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "NeuraTalk debug bundle, %s\n\n", time.Now().Format(time.RFC3339))
+
+	b.WriteString("## Versions\n")
+	for _, item := range info {
+		fmt.Fprintf(&b, "%s: %s\n", item.Name, item.Value)
+	}
+
+	b.WriteString("\n## Checks\n")
+	if len(checks) == 0 {
+		b.WriteString("not run\n")
+	}
+	for _, check := range checks {
+		result := "OK"
+		if !check.OK {
+			result = "FAILED"
+		}
+		fmt.Fprintf(&b, "%s: %s, %s\n", check.Name, result, check.Detail)
+	}
+
+	b.WriteString("\n## Settings\n")
+	if err := writeSettings(&b, redactedSettings(settings)); err != nil {
+		fmt.Fprintf(&b, "%v\n", err)
+	}
+
+	b.WriteString("\n## Log\n")
+	for _, line := range logLines {
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,153 @@
+package internal
+
+import (
+	"log/slog"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// DiagnosticsView shows versions, connectivity checks and the recent log,
+// and copies them as a debug bundle for bug reports
+type DiagnosticsView struct {
+	Settings    *Settings
+	Window      fyne.Window
+	ChecksBox   *fyne.Container
+	LevelSelect *widget.Select
+	Log         *widget.TextGrid
+
+	checks  []DiagnosticCheck
+	content *fyne.Container
+}
+
+// NewDiagnosticsView creates the diagnostics view and starts its checks
+func NewDiagnosticsView(settings *Settings, w fyne.Window) *DiagnosticsView {
+	v := &DiagnosticsView{
+		Settings:  settings,
+		Window:    w,
+		ChecksBox: container.NewVBox(),
+		Log:       widget.NewTextGrid(),
+	}
+	v.LevelSelect = widget.NewSelect(logLevelNames, func(name string) {
+		level, err := parseLogLevel(name)
+		if err != nil {
+			return
+		}
+		if level != logLevel.Level() {
+			logLevel.Set(level)
+			slog.Info("Changed log level", "level", level.String())
+		}
+		v.RefreshLog()
+	})
+	v.LevelSelect.SetSelected(logLevel.Level().String())
+	v.RunChecks()
+	return v
+}
+
+// RunChecks runs the connectivity checks in the background and shows
+// their results
+func (v *DiagnosticsView) RunChecks() {
+	v.ChecksBox.Objects = []fyne.CanvasObject{lowLabel("Checking...")}
+	v.ChecksBox.Refresh()
+
+	var servers []MCPServerConfig
+	var status func(string) string
+	if v.Settings != nil {
+		servers = v.Settings.GetMCPServers()
+		status = v.Settings.MCPStatus
+	}
+	go func() {
+		checks := runDiagnosticChecks(servers, status)
+		for _, check := range checks {
+			if !check.OK {
+				slog.Warn("Diagnostic check failed", "check", check.Name, "detail", check.Detail)
+			}
+		}
+		v.checks = checks
+		v.showChecks()
+	}()
+}
+
+// showChecks lists the results of the last checks
+func (v *DiagnosticsView) showChecks() {
+	var rows []fyne.CanvasObject
+	for _, check := range v.checks {
+		icon := widget.NewIcon(theme.ConfirmIcon())
+		if !check.OK {
+			icon.SetResource(theme.NewErrorThemedResource(theme.ErrorIcon()))
+		}
+		name := widget.NewLabelWithStyle(check.Name, fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+		detail := widget.NewLabel(check.Detail)
+		detail.Wrapping = fyne.TextWrapWord
+		rows = append(rows, container.NewBorder(nil, nil, container.NewHBox(icon, name), nil, detail))
+	}
+	v.ChecksBox.Objects = rows
+	v.ChecksBox.Refresh()
+	v.RefreshLog()
+}
+
+// RefreshLog shows the recent log lines, newest last
+func (v *DiagnosticsView) RefreshLog() {
+	lines := recentLogs.Lines()
+	if len(lines) == 0 {
+		v.Log.SetText("Nothing logged yet")
+		return
+	}
+	v.Log.SetText(strings.Join(lines, "\n"))
+}
+
+// copyDebugBundle puts the debug bundle on the clipboard
+func (v *DiagnosticsView) copyDebugBundle() {
+	var settings SettingsData
+	if v.Settings != nil {
+		settings = v.Settings.data()
+	}
+	bundle := debugBundle(diagnosticInfo(), v.checks, settings, recentLogs.Lines())
+	v.Window.Clipboard().SetContent(bundle)
+	dialog.ShowInformation("Debug Bundle", "The debug bundle was copied to the clipboard. It holds versions, check results, your settings without MCP arguments and environment, and the recent log, but no conversations.", v.Window)
+}
+
+// GetContainer returns the diagnostics view. It is built once and reused.
+func (v *DiagnosticsView) GetContainer() *fyne.Container {
+	if v.content != nil {
+		return v.content
+	}
+
+	info := widget.NewForm()
+	for _, item := range diagnosticInfo() {
+		value := widget.NewLabel(item.Value)
+		value.Wrapping = fyne.TextWrapBreak
+		info.Append(item.Name, value)
+	}
+
+	checkButton := widget.NewButtonWithIcon("Run Checks", theme.ViewRefreshIcon(), v.RunChecks)
+	copyButton := widget.NewButtonWithIcon("Copy Debug Bundle", theme.ContentCopyIcon(), v.copyDebugBundle)
+	title := widget.NewLabelWithStyle("Diagnostics", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	header := container.NewBorder(nil, nil, title, container.NewHBox(checkButton, copyButton))
+
+	overview := container.NewVScroll(container.NewVBox(
+		widget.NewCard("", "", container.NewVBox(
+			widget.NewLabelWithStyle("Checks", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+			v.ChecksBox,
+		)),
+		widget.NewCard("", "", container.NewVBox(
+			widget.NewLabelWithStyle("Versions", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+			info,
+		)),
+	))
+
+	refreshLog := widget.NewButtonWithIcon("", theme.ViewRefreshIcon(), v.RefreshLog)
+	logHeader := container.NewBorder(nil, nil,
+		widget.NewLabelWithStyle("Recent Log", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		container.NewHBox(widget.NewLabel("Level"), v.LevelSelect, refreshLog))
+	logPanel := container.NewBorder(logHeader, nil, nil, nil, container.NewScroll(v.Log))
+
+	split := container.NewVSplit(overview, logPanel)
+	split.SetOffset(0.55)
+	v.content = container.NewBorder(header, nil, nil, nil, split)
+	return v.content
+}
@@ -0,0 +1,393 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// encryptedMagic starts every encrypted file of the store. Plain files are
+// JSON or the old text format and never start with it.
+var encryptedMagic = []byte("NTENC\x01")
+
+// Argon2id parameters for new passphrases
+const (
+	argonTime    = 3
+	argonMemory  = 64 * 1024
+	argonThreads = 4
+	keyLength    = 32
+	saltLength   = 16
+)
+
+// ErrHistoryLocked is returned while the history is encrypted and the
+// passphrase wasn't entered yet
+var ErrHistoryLocked = errors.New("the conversation history is locked")
+
+// errWrongPassphrase is returned when a passphrase doesn't open the history
+var errWrongPassphrase = errors.New("wrong passphrase")
+
+// encryptionConfig describes how the history is encrypted. Files are
+// encrypted with a random key, which is stored encrypted with a key derived
+// from the passphrase. Changing the passphrase only encrypts that key again.
+type encryptionConfig struct {
+	Version int    `json:"version"`
+	KDF     string `json:"kdf"`
+	Salt    []byte `json:"salt"`
+	Time    uint32 `json:"time"`
+	Memory  uint32 `json:"memory"`
+	Threads uint8  `json:"threads"`
+	// Key is the file key, encrypted with the passphrase
+	Key []byte `json:"key"`
+}
+
+// historyVault holds the file key while the history is unlocked
+var historyVault struct {
+	sync.Mutex
+	// key decrypts the files of the store, nil while locked or not encrypted
+	key []byte
+	// seal encrypts files as they are written
+	seal bool
+}
+
+// encryptionConfigPath is where the encryption of the history is described
+func encryptionConfigPath() string {
+	return filepath.Join(DataDir(), "encryption.json")
+}
+
+// loadEncryptionConfig reads how the history is encrypted, nil when it isn't
+func loadEncryptionConfig() (*encryptionConfig, error) {
+	data, err := os.ReadFile(encryptionConfigPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encryption settings: %v", err)
+	}
+	var config encryptionConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse encryption settings: %v", err)
+	}
+	if config.Version != 1 || config.KDF != "argon2id" {
+		return nil, fmt.Errorf("unsupported encryption settings version %d (%s)", config.Version, config.KDF)
+	}
+	return &config, nil
+}
+
+// saveEncryptionConfig replaces the encryption settings
+func saveEncryptionConfig(config *encryptionConfig) error {
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal encryption settings: %v", err)
+	}
+	if err := os.MkdirAll(DataDir(), 0700); err != nil {
+		return fmt.Errorf("failed to create data directory: %v", err)
+	}
+	return writeFileAtomic(encryptionConfigPath(), data)
+}
+
+// HistoryEncrypted reports whether the history is encrypted
+func HistoryEncrypted() bool {
+	_, err := os.Stat(encryptionConfigPath())
+	return err == nil
+}
+
+// HistoryLocked reports whether the history is encrypted and still waits
+// for its passphrase
+func HistoryLocked() bool {
+	historyVault.Lock()
+	defer historyVault.Unlock()
+	return historyVault.key == nil && HistoryEncrypted()
+}
+
+// passphraseKey derives the key that encrypts the file key
+func (c *encryptionConfig) passphraseKey(passphrase string) []byte {
+	return argon2.IDKey([]byte(passphrase), c.Salt, c.Time, c.Memory, c.Threads, keyLength)
+}
+
+// newEncryptionConfig stores a file key under a passphrase
+func newEncryptionConfig(passphrase string, fileKey []byte) (*encryptionConfig, error) {
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to create salt: %v", err)
+	}
+	config := &encryptionConfig{
+		Version: 1,
+		KDF:     "argon2id",
+		Salt:    salt,
+		Time:    argonTime,
+		Memory:  argonMemory,
+		Threads: argonThreads,
+	}
+	sealed, err := sealData(config.passphraseKey(passphrase), fileKey)
+	if err != nil {
+		return nil, err
+	}
+	config.Key = sealed
+	return config, nil
+}
+
+// openFileKey returns the file key if the passphrase is right
+func (c *encryptionConfig) openFileKey(passphrase string) ([]byte, error) {
+	key, err := openData(c.passphraseKey(passphrase), c.Key)
+	if err != nil {
+		return nil, errWrongPassphrase
+	}
+	return key, nil
+}
+
+// sealData encrypts data with AES-256-GCM, prefixing the magic and nonce
+func sealData(key []byte, data []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to create nonce: %v", err)
+	}
+	out := append(append([]byte{}, encryptedMagic...), nonce...)
+	return aead.Seal(out, nonce, data, encryptedMagic), nil
+}
+
+// openData decrypts what sealData encrypted
+func openData(key []byte, data []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if !isEncrypted(data) || len(data) < len(encryptedMagic)+aead.NonceSize() {
+		return nil, fmt.Errorf("not an encrypted file")
+	}
+	data = data[len(encryptedMagic):]
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, encryptedMagic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: the file is damaged or was encrypted with another key")
+	}
+	return plain, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %v", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// isEncrypted reports whether data was written encrypted
+func isEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, encryptedMagic)
+}
+
+// readStoreFile reads a file of the conversation store, decrypting it when
+// it is encrypted
+func readStoreFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil || !isEncrypted(data) {
+		return data, err
+	}
+	historyVault.Lock()
+	key := historyVault.key
+	historyVault.Unlock()
+	if key == nil {
+		return nil, ErrHistoryLocked
+	}
+	return openData(key, data)
+}
+
+// writeStoreFile replaces a file of the conversation store, encrypting it
+// while the history is encrypted. Only the owner can read it.
+func writeStoreFile(path string, data []byte) error {
+	historyVault.Lock()
+	key, seal := historyVault.key, historyVault.seal
+	historyVault.Unlock()
+	if seal {
+		sealed, err := sealData(key, data)
+		if err != nil {
+			return err
+		}
+		data = sealed
+	} else if key == nil && HistoryEncrypted() {
+		// Never write plain text next to encrypted files
+		return ErrHistoryLocked
+	}
+	return writeFileAtomic(path, data)
+}
+
+// writeFileAtomic writes to a temporary file first and renames it into place
+func writeFileAtomic(path string, data []byte) error {
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %v", filepath.Base(path), err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to write %s: %v", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// storePaths are the files and directories encrypted with the history
+func storePaths() []string {
+	return []string{
+		chatsDirectory(),
+		conversationsDirectory(),
+		imagesDirectory(),
+		sessionFilePath(),
+		projectsFilePath(),
+		comparisonsFilePath(),
+		arenaFilePath(),
+	}
+}
+
+// migrateStore rewrites every file of the store that isn't in the wanted
+// form, encrypting or decrypting it, and restricts the store to its owner
+func migrateStore(encrypt bool) error {
+	var failed []string
+	for _, root := range storePaths() {
+		filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() {
+				os.Chmod(path, 0700)
+				return nil
+			}
+			if filepath.Ext(path) == ".tmp" {
+				return nil
+			}
+			raw, err := os.ReadFile(path)
+			if err != nil {
+				failed = append(failed, path)
+				return nil
+			}
+			if isEncrypted(raw) == encrypt {
+				os.Chmod(path, 0600)
+				return nil
+			}
+			data, err := readStoreFile(path)
+			if err == nil {
+				err = writeStoreFile(path, data)
+			}
+			if err != nil {
+				failed = append(failed, path)
+			}
+			return nil
+		})
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to convert %d files, first %s", len(failed), failed[0])
+	}
+	return nil
+}
+
+// UnlockHistory opens the encrypted history with its passphrase. Files
+// still written in plain text, for example by an interrupted conversion,
+// are encrypted right away.
+func UnlockHistory(passphrase string) error {
+	config, err := loadEncryptionConfig()
+	if err != nil {
+		return err
+	}
+	if config == nil {
+		return nil
+	}
+	key, err := config.openFileKey(passphrase)
+	if err != nil {
+		return err
+	}
+	historyVault.Lock()
+	historyVault.key, historyVault.seal = key, true
+	historyVault.Unlock()
+	if err := migrateStore(true); err != nil {
+		slog.Error("Failed to encrypt conversation history", "err", err)
+	}
+	return nil
+}
+
+// EnableHistoryEncryption encrypts the history with a passphrase,
+// converting every stored conversation and image
+func EnableHistoryEncryption(passphrase string) error {
+	if HistoryEncrypted() {
+		return fmt.Errorf("the history is already encrypted")
+	}
+	fileKey := make([]byte, keyLength)
+	if _, err := rand.Read(fileKey); err != nil {
+		return fmt.Errorf("failed to create key: %v", err)
+	}
+	config, err := newEncryptionConfig(passphrase, fileKey)
+	if err != nil {
+		return err
+	}
+	if err := saveEncryptionConfig(config); err != nil {
+		return err
+	}
+
+	// Files saved from now on are encrypted, the rest is converted
+	historyVault.Lock()
+	historyVault.key, historyVault.seal = fileKey, true
+	historyVault.Unlock()
+	return migrateStore(true)
+}
+
+// ChangeHistoryPassphrase replaces the passphrase of the encrypted history
+func ChangeHistoryPassphrase(oldPassphrase string, newPassphrase string) error {
+	config, err := loadEncryptionConfig()
+	if err != nil {
+		return err
+	}
+	if config == nil {
+		return fmt.Errorf("the history isn't encrypted")
+	}
+	fileKey, err := config.openFileKey(oldPassphrase)
+	if err != nil {
+		return err
+	}
+	changed, err := newEncryptionConfig(newPassphrase, fileKey)
+	if err != nil {
+		return err
+	}
+	return saveEncryptionConfig(changed)
+}
+
+// DisableHistoryEncryption decrypts the history and stops encrypting it
+func DisableHistoryEncryption(passphrase string) error {
+	config, err := loadEncryptionConfig()
+	if err != nil {
+		return err
+	}
+	if config == nil {
+		return nil
+	}
+	fileKey, err := config.openFileKey(passphrase)
+	if err != nil {
+		return err
+	}
+
+	// Keep the key to read encrypted files while they are written plain.
+	// The settings are only removed once nothing needs the key any more.
+	historyVault.Lock()
+	historyVault.key, historyVault.seal = fileKey, false
+	historyVault.Unlock()
+	if err := migrateStore(false); err != nil {
+		historyVault.Lock()
+		historyVault.seal = true
+		historyVault.Unlock()
+		return err
+	}
+	if err := os.Remove(encryptionConfigPath()); err != nil {
+		return fmt.Errorf("failed to remove encryption settings: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+// resetVault forgets the key of the history after a test
+func resetVault(t *testing.T) {
+	t.Cleanup(func() {
+		historyVault.Lock()
+		historyVault.key = nil
+		historyVault.seal = false
+		historyVault.Unlock()
+	})
+}
+
+func TestEncryptionCoversVotesAndProjects(t *testing.T) {
+	usePaths(t)
+	resetVault(t)
+
+	if err := appendComparison(Comparison{Prompt: "plain prompt"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := appendArenaMatch(ArenaMatch{Prompt: "arena prompt"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveProjects([]Project{{Name: "Coder", SystemPrompt: "secret persona"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := EnableHistoryEncryption("correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	if err := appendComparison(Comparison{Prompt: "sealed prompt"}); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string][]string{
+		comparisonsFilePath(): {"plain prompt", "sealed prompt"},
+		arenaFilePath():       {"arena prompt"},
+		projectsFilePath():    {"secret persona"},
+	}
+	for path, secrets := range files {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !isEncrypted(raw) {
+			t.Errorf("%s is not encrypted", path)
+		}
+		for _, secret := range secrets {
+			if bytes.Contains(raw, []byte(secret)) {
+				t.Errorf("%s shows %q in plain text", path, secret)
+			}
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mode := info.Mode().Perm(); mode != 0600 {
+			t.Errorf("%s has mode %o, want 600", path, mode)
+		}
+	}
+
+	comparisons, err := loadComparisons()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comparisons) != 2 || comparisons[0].Prompt != "plain prompt" || comparisons[1].Prompt != "sealed prompt" {
+		t.Errorf("comparisons = %+v, want both prompts in order", comparisons)
+	}
+	projects, err := loadProjects()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(projects) != 1 || projects[0].SystemPrompt != "secret persona" {
+		t.Errorf("projects = %+v", projects)
+	}
+
+	if err := DisableHistoryEncryption("correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	for path := range files {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if isEncrypted(raw) {
+			t.Errorf("%s is still encrypted", path)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package internal
+
+import (
+	"strings"
+	"unicode"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// codeFence opens and closes a code block in a message
+const codeFence = "```"
+
+// codeKeywords are highlighted in code blocks. One list serves the common
+// languages, as replies don't always name theirs.
+var codeKeywords = map[string]bool{
+	"break": true, "case": true, "catch": true, "class": true, "const": true,
+	"continue": true, "def": true, "default": true, "defer": true, "do": true,
+	"elif": true, "else": true, "enum": true, "except": true, "export": true,
+	"extends": true, "false": true, "finally": true, "fn": true, "for": true,
+	"from": true, "func": true, "function": true, "go": true, "if": true,
+	"impl": true, "import": true, "in": true, "interface": true, "let": true,
+	"match": true, "mut": true, "new": true, "nil": true, "None": true,
+	"null": true, "package": true, "pub": true, "return": true, "select": true,
+	"self": true, "static": true, "struct": true, "switch": true, "this": true,
+	"throw": true, "true": true, "True": true, "False": true, "try": true,
+	"type": true, "use": true, "var": true, "while": true, "with": true,
+	"yield": true, "async": true, "await": true, "range": true, "chan": true,
+}
+
+// codeTypes are the builtin types highlighted in code blocks. Names
+// starting with an upper case letter are highlighted as types too.
+var codeTypes = map[string]bool{
+	"any": true, "bool": true, "boolean": true, "byte": true, "char": true,
+	"dict": true, "double": true, "error": true, "float": true,
+	"float32": true, "float64": true, "int": true, "int32": true,
+	"int64": true, "list": true, "long": true, "map": true, "number": true,
+	"rune": true, "str": true, "string": true, "uint": true, "uint64": true,
+	"usize": true, "void": true, "i32": true, "i64": true, "u8": true,
+	"u32": true, "u64": true, "f32": true, "f64": true,
+}
+
+// textSegment creates an inline monospace segment of a message
+func textSegment(text string, colorName fyne.ThemeColorName) *widget.TextSegment {
+	return &widget.TextSegment{
+		Text: text,
+		Style: widget.RichTextStyle{
+			ColorName: colorName,
+			Inline:    true,
+			SizeName:  theme.SizeNameText,
+			TextStyle: fyne.TextStyle{Monospace: true},
+		},
+	}
+}
+
+// messageSegments splits a message into plain text and code blocks, whose
+// code is colored with the code palette of the theme
+func messageSegments(text string, colorName fyne.ThemeColorName) []widget.RichTextSegment {
+	var segments []widget.RichTextSegment
+	for i, part := range strings.Split(text, codeFence) {
+		if i%2 == 0 {
+			if part != "" {
+				segments = append(segments, textSegment(part, colorName))
+			}
+			continue
+		}
+		// The fences and the language after the opening one stay as written
+		language, code, found := strings.Cut(part, "\n")
+		if !found {
+			language, code = part, ""
+		}
+		segments = append(segments, textSegment(codeFence+language+"\n", colorName))
+		segments = append(segments, highlightCode(code, colorName)...)
+		if i < strings.Count(text, codeFence) {
+			segments = append(segments, textSegment(codeFence, colorName))
+		}
+	}
+	return segments
+}
+
+// highlightCode colors keywords, strings, comments, numbers, functions and
+// types. Everything else gets colorName.
+func highlightCode(code string, colorName fyne.ThemeColorName) []widget.RichTextSegment {
+	var segments []widget.RichTextSegment
+	var plain strings.Builder
+	add := func(text string, name fyne.ThemeColorName) {
+		if name == colorName {
+			plain.WriteString(text)
+			return
+		}
+		if plain.Len() > 0 {
+			segments = append(segments, textSegment(plain.String(), colorName))
+			plain.Reset()
+		}
+		segments = append(segments, textSegment(text, name))
+	}
+
+	runes := []rune(code)
+	for i := 0; i < len(runes); {
+		start := i
+		r := runes[i]
+		next := rune(0)
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+
+		switch {
+		case r == '/' && next == '*':
+			i += 2
+			for i < len(runes) && !(runes[i] == '*' && i+1 < len(runes) && runes[i+1] == '/') {
+				i++
+			}
+			i = min(i+2, len(runes))
+			add(string(runes[start:i]), ColorNameCodeComment)
+		case r == '/' && next == '/', r == '#':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			add(string(runes[start:i]), ColorNameCodeComment)
+		case r == '"' || r == '\'' || r == '`':
+			i++
+			for i < len(runes) && runes[i] != r && (r == '`' || runes[i] != '\n') {
+				if runes[i] == '\\' && r != '`' {
+					i++
+				}
+				i++
+			}
+			i = min(i+1, len(runes))
+			add(string(runes[start:i]), ColorNameCodeString)
+		case unicode.IsDigit(r):
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '.' || runes[i] == '_') {
+				i++
+			}
+			add(string(runes[start:i]), ColorNameCodeNumber)
+		case unicode.IsLetter(r) || r == '_':
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			word := string(runes[start:i])
+			after := i
+			for after < len(runes) && runes[after] == ' ' {
+				after++
+			}
+			switch {
+			case codeKeywords[word]:
+				add(word, ColorNameCodeKeyword)
+			case after < len(runes) && runes[after] == '(':
+				add(word, ColorNameCodeFunction)
+			case codeTypes[word] || unicode.IsUpper(r):
+				add(word, ColorNameCodeType)
+			default:
+				add(word, colorName)
+			}
+		default:
+			i++
+			add(string(r), colorName)
+		}
+	}
+	if plain.Len() > 0 {
+		segments = append(segments, textSegment(plain.String(), colorName))
+	}
+	return segments
+}
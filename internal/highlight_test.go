@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// coloredWords lists the text of the segments that don't use the plain color
+func coloredWords(segments []widget.RichTextSegment) map[fyne.ThemeColorName][]string {
+	words := map[fyne.ThemeColorName][]string{}
+	for _, segment := range segments {
+		text := segment.(*widget.TextSegment)
+		if text.Style.ColorName != theme.ColorNameForeground {
+			words[text.Style.ColorName] = append(words[text.Style.ColorName], text.Text)
+		}
+	}
+	return words
+}
+
+func TestHighlightCode(t *testing.T) {
+	code := "func greet(n int) string {\n\t// say hi\n\treturn \"hi \\\"there\\\"\" + fmt.Sprint(42, Name) /* done */\n}"
+	got := coloredWords(highlightCode(code, theme.ColorNameForeground))
+	want := map[fyne.ThemeColorName][]string{
+		ColorNameCodeKeyword:  {"func", "return"},
+		ColorNameCodeFunction: {"greet", "Sprint"},
+		ColorNameCodeType:     {"int", "string", "Name"},
+		ColorNameCodeComment:  {"// say hi", "/* done */"},
+		ColorNameCodeString:   {`"hi \"there\""`},
+		ColorNameCodeNumber:   {"42"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("colored words = %v, want %v", got, want)
+	}
+
+	// Highlighting keeps every character
+	var text strings.Builder
+	for _, segment := range highlightCode(code, theme.ColorNameForeground) {
+		text.WriteString(segment.(*widget.TextSegment).Text)
+	}
+	if text.String() != code {
+		t.Errorf("highlighted text = %q, want %q", text.String(), code)
+	}
+}
+
+func TestMessageSegments(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want map[fyne.ThemeColorName][]string
+	}{
+		{"code block", "AI: Try this:\n```go\nreturn nil\n```\nDone.", map[fyne.ThemeColorName][]string{ColorNameCodeKeyword: {"return", "nil"}}},
+		{"unclosed block", "AI: ```python\ndef f():", map[fyne.ThemeColorName][]string{ColorNameCodeKeyword: {"def"}, ColorNameCodeFunction: {"f"}}},
+		{"keywords outside code", "You: if you return, func is plain", map[fyne.ThemeColorName][]string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segments := messageSegments(tt.text, theme.ColorNameForeground)
+			if got := coloredWords(segments); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("colored words = %v, want %v", got, tt.want)
+			}
+			var text strings.Builder
+			for _, segment := range segments {
+				text.WriteString(segment.(*widget.TextSegment).Text)
+			}
+			if text.String() != tt.text {
+				t.Errorf("message text = %q, want %q", text.String(), tt.text)
+			}
+		})
+	}
+}
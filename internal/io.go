@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -15,198 +14,236 @@ import (
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
-	"github.com/tmc/langchaingo/llms"
-	"github.com/tmc/langchaingo/llms/ollama"
 )
 
 type InputOutput struct {
-	InputEntry      *widget.Entry
-	OutputLabel     *widget.Label
+	InputEntry      *Composer
+	MessageList     *fyne.Container
 	ModelSelect     *widget.Select
 	SelectedModel   string
 	ParentWindow    fyne.Window
 	ScrollContainer *container.Scroll
-	Conversation    []string
+	Messages        []Message
+	Summary         string
+	Parameters      ParameterOverrides
+	Info            ConversationInfo
+	ContextMeter    *widget.ProgressBar
 	ClearButton     *widget.Button
+	// ClearChatButton empties the chat from the top bar
+	ClearChatButton *widget.Button
+	AttachButton    *widget.Button
+	AttachmentBar   *fyne.Container
 	Settings        *Settings
-
+	Tools           *ToolRegistry
+	MCP             *MCPManager
+	// Shortcuts runs the window shortcuts pressed while the input has focus
+	Shortcuts *ShortcutManager
+	// OnConversationChanged is called after the chat was saved or relabelled
+	OnConversationChanged func()
+	// OnCleared is called after the conversation was cleared
+	OnCleared func(*InputOutput)
+
+	pendingImages   []string
+	supportsVision  bool
+	content         *fyne.Container
 	animating       bool
 	animationTicker *time.Ticker
+	// pendingScroll is the restored scroll offset, applied once shown
+	pendingScroll float32
+	// stopGeneration cancels the reply being generated, nil when idle
+	stopGeneration context.CancelFunc
 }
 
-func isFileEmpty(filePath string) (bool, error) {
-	fileInfo, err := os.Stat(filePath)
-	if err != nil {
-		return false, err
-	}
-
-	return fileInfo.Size() == 0, nil
+// welcomeMessage is shown in place of an empty conversation
+func welcomeMessage(modelName string) string {
+	return fmt.Sprintf("Welcome to NeuraTalk! You are now chatting with %s.\n\nType your message below to begin.", modelName)
 }
 
-func ensureTmpDirectoryExists() {
-	// Create tmp directory if it doesn't exist
-	if _, err := os.Stat("./tmp"); os.IsNotExist(err) {
-		err := os.Mkdir("./tmp", 0755)
-		if err != nil {
-			log.Println("Error creating tmp directory:", err)
-		}
-	}
+// newMessageLabel creates the label used to display chat text
+func newMessageLabel(text string) *widget.Label {
+	label := widget.NewLabel(text)
+	label.TextStyle = fyne.TextStyle{Monospace: true}
+	label.Wrapping = fyne.TextWrapWord
+	return label
 }
 
-// Add this function to create the conversations directory structure
-func ensureConversationsDirectoryExists() {
-	// Create conversations directory if it doesn't exist
-	if _, err := os.Stat("./conversations"); os.IsNotExist(err) {
-		err := os.Mkdir("./conversations", 0755)
-		if err != nil {
-			log.Println("Error creating conversations directory:", err)
+// newMessageText displays a message, coloring its code blocks with the code
+// palette of the theme. Dimmed messages use the disabled text color.
+func newMessageText(text string, dimmed bool) fyne.CanvasObject {
+	if !strings.Contains(text, codeFence) {
+		label := newMessageLabel(text)
+		if dimmed {
+			label.Importance = widget.LowImportance
 		}
+		return label
 	}
+	colorName := theme.ColorNameForeground
+	if dimmed {
+		colorName = theme.ColorNameDisabled
+	}
+	rich := widget.NewRichText(messageSegments(text, colorName)...)
+	rich.Wrapping = fyne.TextWrapWord
+	return rich
 }
 
-// Add this function to save a conversation to the conversations folder
-func saveConversationToHistory(modelName string, conversation []string) error {
-	// Ensure conversations directory exists
-	ensureConversationsDirectoryExists()
-
-	// Create model directory if it doesn't exist
-	modelDir := filepath.Join("./conversations", modelName)
-	if _, err := os.Stat(modelDir); os.IsNotExist(err) {
-		err := os.Mkdir(modelDir, 0755)
-		if err != nil {
-			return fmt.Errorf("failed to create model directory: %v", err)
+// renderMessage creates the widgets showing a single message. Messages
+// already stored in the chat get a button pinning them to the context.
+func (io *InputOutput) renderMessage(index int, m Message) fyne.CanvasObject {
+	// Only the summary of summarized messages still reaches the model
+	label := newMessageText(m.String(), m.Summarized)
+
+	var content fyne.CanvasObject = label
+	if len(m.Images) > 0 || m.Stats != nil {
+		box := container.NewVBox(label)
+		if len(m.Images) > 0 {
+			box.Add(newThumbnailRow(m.Images))
+		}
+		if m.Stats != nil {
+			box.Add(newStatsFooter(*m.Stats))
 		}
+		content = box
 	}
-
-	// Create a timestamped file for this conversation
-	timestamp := time.Now().Format("20060102_150405")
-	fileName := fmt.Sprintf("%s_%s.txt", modelName, timestamp)
-	filePath := filepath.Join(modelDir, fileName)
-
-	// Join conversation with double newlines
-	content := strings.Join(conversation, "\n\n")
-
-	// Write to file
-	err := os.WriteFile(filePath, []byte(content), 0644)
-	if err != nil {
-		return fmt.Errorf("failed to write conversation file: %v", err)
+	if index >= len(io.Messages) {
+		return content
 	}
 
-	return nil
+	pinText := "Pin"
+	if m.Pinned {
+		pinText = "Unpin"
+	}
+	pinButton := widget.NewButton(pinText, func() {
+		io.togglePin(index)
+	})
+	pinButton.Importance = widget.LowImportance
+	return container.NewBorder(nil, nil, nil, container.NewVBox(pinButton), content)
 }
 
-func NewInputOutput(names []string, parent fyne.Window, settings *Settings) *InputOutput {
-	// Ensure tmp directory exists
-	ensureTmpDirectoryExists()
+func NewInputOutput(names []string, parent fyne.Window, settings *Settings, tools *ToolRegistry) *InputOutput {
+	// Ensure chats directory exists
+	ensureChatsDirectoryExists()
 
 	// Ensure conversations directory exists
 	ensureConversationsDirectoryExists()
 
 	io := &InputOutput{
-		OutputLabel:  widget.NewLabel(""),
-		InputEntry:   widget.NewEntry(),
-		ParentWindow: parent,
-		Conversation: []string{},
-		animating:    false,
-		Settings:     settings,
+		MessageList:   container.NewVBox(),
+		AttachmentBar: container.NewHBox(),
+		ContextMeter:  widget.NewProgressBar(),
+		ParentWindow:  parent,
+		Messages:      []Message{},
+		animating:     false,
+		Settings:      settings,
+		Tools:         tools,
 	}
 
-	// Make the output label text selectable
-	io.OutputLabel.TextStyle = fyne.TextStyle{Monospace: true}
-	io.OutputLabel.Wrapping = fyne.TextWrapWord
-
-	// Set placeholder text for input
-	io.InputEntry.SetPlaceHolder("Type your message here... (Press Enter to send)")
+	// Create the composer, recalling the prompts of this conversation
+	io.InputEntry = NewComposer(settings.GetSendKey, io.prompts)
+	io.InputEntry.SetPlaceHolder("Type your message here...")
 
 	// Create clear button
 	io.ClearButton = widget.NewButton("Clear Conversation", func() {
 		// Save the current conversation to history before clearing
-		if len(io.Conversation) > 0 && io.ModelSelect.Selected != "" {
-			err := saveConversationToHistory(io.ModelSelect.Selected, io.Conversation)
+		if len(io.Messages) > 0 && io.ModelSelect.Selected != "" {
+			err := saveConversationToHistory(io.ModelSelect.Selected, io.conversation())
 			if err != nil {
-				dialog.ShowError(fmt.Errorf("Failed to save conversation history: %v", err), parent)
+				ShowError(fmt.Errorf("Failed to save conversation history: %v", err), parent)
 			}
 		}
 
-		// Get the file path for the current model
-		filePath := fmt.Sprintf("./tmp/%s.txt", io.ModelSelect.Selected)
-
-		// Delete the file content by creating an empty file
-		err := os.WriteFile(filePath, []byte(""), 0644)
-		if err != nil {
-			dialog.ShowError(fmt.Errorf("Failed to clear chat history: %v", err), io.ParentWindow)
-			return
-		}
-
-		// Clear the current conversation
-		io.Conversation = []string{}
-		io.OutputLabel.SetText(fmt.Sprintf("Welcome to NeuraTalk! You are now chatting with %s.\n\nType your message below to begin.", io.ModelSelect.Selected))
-		io.OutputLabel.Refresh()
+		io.clearConversation()
+	})
 
-		// Create a new chat instance with the same model
-		newIO := NewInputOutput([]string{io.ModelSelect.Selected}, io.ParentWindow, io.Settings)
-		newIO.ModelSelect.SetSelected(io.ModelSelect.Selected)
+	// Create the clear button of the top bar
+	io.ClearChatButton = widget.NewButton("Clear Chat", io.clearConversation)
 
-		// Update the last chat in the chat manager
-		if manager, ok := io.ParentWindow.(interface{ SetLastChat(*InputOutput) }); ok {
-			manager.SetLastChat(newIO)
-		}
+	// Create attach button, enabled once a vision model is selected
+	io.AttachButton = widget.NewButtonWithIcon("", theme.FileImageIcon(), func() {
+		fileDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+			if err != nil {
+				ShowError(err, io.ParentWindow)
+				return
+			}
+			if reader == nil {
+				return
+			}
+			defer reader.Close()
+			io.AttachImage(reader.URI().Path())
+		}, io.ParentWindow)
+		fileDialog.SetFilter(storage.NewExtensionFileFilter(imageExtensions))
+		fileDialog.Show()
 	})
+	io.AttachButton.Disable()
 
 	modelSelect := widget.NewSelect(names, func(selected string) {
 		io.SelectedModel = selected
 
-		filePath := fmt.Sprintf("./tmp/%s.txt", selected)
-
-		_, err := os.Stat(filePath)
-		fileExists := !os.IsNotExist(err)
+		conversation, err := loadConversation(selected)
+		if err != nil {
+			ShowError(fmt.Errorf("Failed to load conversation for model %s: %v", selected, err), parent)
+		}
+		io.Messages = conversation.Messages
+		io.Summary = conversation.Summary
+		io.Info = conversation.ConversationInfo
+		if io.Info.Archived && err == nil {
+			// A chat that is shown again is no longer archived
+			io.SetArchived(false)
+		}
+		io.Parameters = ParameterOverrides{}
+		if conversation.Parameters != nil {
+			io.Parameters = *conversation.Parameters
+		}
+		if io.Messages == nil {
+			io.Messages = []Message{}
+		}
 
-		if !fileExists {
-			file, err := os.Create(filePath)
-			if err != nil {
-				msg := fmt.Sprintf("Failed to create file for model %s: %v", selected, err)
-				dialog.ShowError(fmt.Errorf(msg), parent)
-				log.Println(msg)
+		// Show welcome message for new conversations
+		io.refreshMessages(io.Messages)
+		io.changed()
+
+		// Only vision models accept image attachments
+		io.supportsVision = false
+		io.AttachButton.Disable()
+		go func() {
+			vision := SupportsVision(selected)
+			if io.ModelSelect.Selected != selected {
 				return
 			}
-			file.Close()
-			log.Printf("Created new file for model: %s", selected)
-		}
-
-		if fileExists {
-			content, err := os.ReadFile(filePath)
-			if err == nil && len(content) > 0 {
-				io.Conversation = []string{}
-				conversations := strings.SplitSeq(string(content), "\n\n")
-				for conv := range conversations {
-					if strings.TrimSpace(conv) != "" {
-						io.Conversation = append(io.Conversation, conv)
-					}
-				}
-				fullResponse := strings.Join(io.Conversation, "\n\n")
-				io.OutputLabel.SetText(fullResponse)
-				io.OutputLabel.Refresh()
+			io.supportsVision = vision
+			if vision {
+				io.AttachButton.Enable()
 			}
-		}
-
-		// Show welcome message for new conversations
-		if len(io.Conversation) == 0 {
-			io.OutputLabel.SetText(fmt.Sprintf("Welcome to NeuraTalk! You are now chatting with %s.\n\nType your message below to begin.", selected))
-			io.OutputLabel.Refresh()
-		}
+		}()
 	})
 
 	io.ModelSelect = modelSelect
 
-	// Add keyboard shortcuts
-	io.InputEntry.OnSubmitted = func(text string) {
+	// Pasting the path of an image file attaches it to a vision model
+	// instead of inserting text. Typed or recalled paths stay text.
+	io.InputEntry.OnPaste = func(text string) bool {
+		path, ok := pastedImagePath(text)
+		if !ok || !io.supportsVision {
+			return false
+		}
+		io.AttachImage(path)
+		return true
+	}
+
+	// Send with the key chosen in the settings
+	io.InputEntry.OnSend = func(text string) {
 		if strings.TrimSpace(text) != "" {
 			io.GenerateResponse()
 			io.InputEntry.SetText("")
 		}
 	}
 
+	// The focused input receives the window shortcuts in place of the window
+	io.InputEntry.OnShortcut = func(shortcut fyne.Shortcut) bool {
+		return io.Shortcuts != nil && io.Shortcuts.Trigger(shortcut)
+	}
+
 	return io
 }
 
@@ -214,8 +251,275 @@ func (io *InputOutput) GetInput() string {
 	return io.InputEntry.Text
 }
 
+// prompts returns what the user sent in this conversation, oldest first
+func (io *InputOutput) prompts() []string {
+	var prompts []string
+	for _, m := range io.Messages {
+		if m.Role == RoleUser && m.Content != "" {
+			prompts = append(prompts, m.Content)
+		}
+	}
+	return prompts
+}
+
+// AttachImage adds an image to the message being composed
+func (io *InputOutput) AttachImage(path string) {
+	if !io.supportsVision {
+		dialog.ShowInformation("Images Not Supported",
+			fmt.Sprintf("The selected model %q does not accept images. Choose a vision model such as llava or llama3.2-vision.", io.ModelSelect.Selected),
+			io.ParentWindow)
+		return
+	}
+
+	if !isImageFile(path) {
+		ShowError(fmt.Errorf("%s is not a supported image (%s)", filepath.Base(path), strings.Join(imageExtensions, ", ")), io.ParentWindow)
+		return
+	}
+
+	name, err := storeImage(path)
+	if err != nil {
+		ShowError(fmt.Errorf("Failed to attach image: %v", err), io.ParentWindow)
+		return
+	}
+
+	io.pendingImages = append(io.pendingImages, name)
+	io.refreshAttachments()
+}
+
+// showPromptPicker lets the user insert a prompt offered by an MCP server
+func (io *InputOutput) showPromptPicker() {
+	prompts := io.MCP.Prompts()
+	if len(prompts) == 0 {
+		dialog.ShowInformation("No Prompts", "None of the running MCP servers offer prompts.", io.ParentWindow)
+		return
+	}
+
+	options := make([]string, len(prompts))
+	for i, ref := range prompts {
+		options[i] = ref.Server + ": " + ref.Prompt.Name
+	}
+
+	var picker dialog.Dialog
+	list := widget.NewList(
+		func() int { return len(prompts) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			item.(*widget.Label).SetText(options[id])
+		},
+	)
+	list.OnSelected = func(id widget.ListItemID) {
+		picker.Hide()
+		io.fillPrompt(prompts[id])
+	}
+
+	picker = dialog.NewCustom("Insert Prompt", "Cancel", list, io.ParentWindow)
+	picker.Resize(fyne.NewSize(400, 300))
+	picker.Show()
+}
+
+// fillPrompt asks for the arguments of a prompt and puts the result into
+// the input field
+func (io *InputOutput) fillPrompt(ref MCPPromptRef) {
+	insert := func(args map[string]string) {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), mcpStartTimeout)
+			defer cancel()
+			text, err := io.MCP.GetPrompt(ctx, ref, args)
+			if err != nil {
+				ShowError(fmt.Errorf("Failed to get prompt %s: %v", ref.Prompt.Name, err), io.ParentWindow)
+				return
+			}
+			io.InputEntry.SetText(text)
+		}()
+	}
+
+	if len(ref.Prompt.Arguments) == 0 {
+		insert(nil)
+		return
+	}
+
+	entries := map[string]*widget.Entry{}
+	items := make([]*widget.FormItem, 0, len(ref.Prompt.Arguments))
+	for _, arg := range ref.Prompt.Arguments {
+		entry := widget.NewEntry()
+		entry.SetPlaceHolder(arg.Description)
+		if arg.Required {
+			entry.Validator = func(value string) error {
+				if strings.TrimSpace(value) == "" {
+					return fmt.Errorf("required")
+				}
+				return nil
+			}
+		}
+		entries[arg.Name] = entry
+		items = append(items, widget.NewFormItem(arg.Name, entry))
+	}
+
+	form := dialog.NewForm(ref.Prompt.Name, "Insert", "Cancel", items, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		args := map[string]string{}
+		for name, entry := range entries {
+			if entry.Text != "" {
+				args[name] = entry.Text
+			}
+		}
+		insert(args)
+	}, io.ParentWindow)
+	form.Resize(fyne.NewSize(400, 250))
+	form.Show()
+}
+
+// refreshAttachments redraws the previews of images waiting to be sent
+func (io *InputOutput) refreshAttachments() {
+	io.AttachmentBar.RemoveAll()
+	for i, name := range io.pendingImages {
+		index := i
+		io.AttachmentBar.Add(newAttachmentPreview(name, func() {
+			io.pendingImages = append(io.pendingImages[:index], io.pendingImages[index+1:]...)
+			io.refreshAttachments()
+		}))
+	}
+	io.AttachmentBar.Refresh()
+}
+
+// refreshMessages redraws the conversation view with the given messages
+func (io *InputOutput) refreshMessages(messages []Message) {
+	io.MessageList.RemoveAll()
+	if len(messages) == 0 {
+		io.MessageList.Add(newMessageLabel(welcomeMessage(io.ModelSelect.Selected)))
+	}
+	if io.Summary != "" {
+		summary := widget.NewLabel("Summary of earlier messages: " + io.Summary)
+		summary.Wrapping = fyne.TextWrapWord
+		summary.TextStyle = fyne.TextStyle{Italic: true}
+		io.MessageList.Add(summary)
+	}
+	for i, m := range messages {
+		io.MessageList.Add(io.renderMessage(i, m))
+	}
+	io.MessageList.Refresh()
+	io.updateContextMeter()
+}
+
+// conversation returns the chat in the form it is stored
+func (io *InputOutput) conversation() Conversation {
+	conversation := Conversation{
+		ConversationInfo: io.Info,
+		Summary:          io.Summary,
+		Messages:         io.Messages,
+	}
+	if !io.Parameters.IsEmpty() {
+		parameters := io.Parameters
+		conversation.Parameters = &parameters
+	}
+	return conversation
+}
+
+// parameters returns the generation parameters of the chat, layered over
+// the profile of its model and the global settings
+func (io *InputOutput) parameters() GenerationParameters {
+	params, _ := io.Settings.ResolveParameters(io.SelectedModel, &io.Parameters)
+	return params
+}
+
+// showParameters lets the user override generation parameters for this chat
+func (io *InputOutput) showParameters() {
+	modelName := io.SelectedModel
+	if modelName == "" {
+		dialog.ShowInformation("Parameters", "Please select a model first.", io.ParentWindow)
+		return
+	}
+
+	editor := newParameterEditor(
+		func(o ParameterOverrides) (GenerationParameters, map[string]string) {
+			return io.Settings.ResolveParameters(modelName, &o)
+		},
+		func(o ParameterOverrides) {
+			io.Parameters = o
+			if err := saveConversation(modelName, io.conversation()); err != nil {
+				ShowError(fmt.Errorf("Failed to save conversation: %v", err), io.ParentWindow)
+			}
+			io.updateContextMeter()
+		},
+	)
+	editor.SetOverrides(io.Parameters)
+
+	help := widget.NewLabel("Check a parameter to override it for this chat only.")
+	content := container.NewVBox(help, editor.content)
+	d := dialog.NewCustom(fmt.Sprintf("Parameters for this %s chat", modelName), "Close", content, io.ParentWindow)
+	d.Resize(fyne.NewSize(560, 0))
+	d.Show()
+}
+
+// togglePin pins or unpins a message so it is never dropped from the context
+func (io *InputOutput) togglePin(index int) {
+	if index >= len(io.Messages) {
+		return
+	}
+	io.Messages[index].Pinned = !io.Messages[index].Pinned
+
+	if io.ModelSelect.Selected != "" {
+		if err := saveConversation(io.ModelSelect.Selected, io.conversation()); err != nil {
+			ShowError(fmt.Errorf("Failed to save conversation: %v", err), io.ParentWindow)
+		}
+	}
+	if !io.animating {
+		io.refreshMessages(io.Messages)
+	}
+}
+
+// resetConversation replaces the chat of the selected model with an empty
+// one labelled info, keeping its parameters
+func (io *InputOutput) resetConversation(info ConversationInfo) bool {
+	err := saveConversation(io.ModelSelect.Selected, Conversation{ConversationInfo: info, Parameters: io.conversation().Parameters})
+	if err != nil {
+		ShowError(fmt.Errorf("Failed to clear chat history: %v", err), io.ParentWindow)
+		return false
+	}
+
+	// Clear the current conversation
+	io.Messages = []Message{}
+	io.Summary = ""
+	io.Info = info
+	io.pendingImages = nil
+	io.refreshAttachments()
+	io.refreshMessages(io.Messages)
+	io.changed()
+	return true
+}
+
+// forgetConversation empties the chat without saving it, after its files
+// were deleted
+func (io *InputOutput) forgetConversation() {
+	io.StopGeneration()
+	io.Messages = []Message{}
+	io.Summary = ""
+	io.Info = ConversationInfo{}
+	io.pendingImages = nil
+	io.refreshAttachments()
+	io.refreshMessages(io.Messages)
+	io.changed()
+}
+
+// clearConversation empties the chat of the selected model
+func (io *InputOutput) clearConversation() {
+	// Keep the pin, color and project of the chat. The title and archive
+	// belong to the old conversation.
+	info := ConversationInfo{Pinned: io.Info.Pinned, Color: io.Info.Color, Project: io.Info.Project}
+	if !io.resetConversation(info) {
+		return
+	}
+
+	// Let the owner of the chat know it starts over
+	if io.OnCleared != nil {
+		io.OnCleared(io)
+	}
+}
+
 // Modified SetOutput to animate only the new response
-func (io *InputOutput) SetOutput(response string) {
+func (io *InputOutput) SetOutput(response Message) {
 	// Store current scroll position
 	var scrollPos fyne.Position
 	if io.ScrollContainer != nil {
@@ -223,14 +527,14 @@ func (io *InputOutput) SetOutput(response string) {
 	}
 
 	// Add the new response to conversation array
-	io.Conversation = append(io.Conversation, response)
+	io.Messages = append(io.Messages, response)
 
 	// Start animation for the new response
 	io.animateNewResponseOnly(response, scrollPos)
 }
 
 // New method to animate only the most recently added response
-func (io *InputOutput) animateNewResponseOnly(newResponse string, origScrollPos fyne.Position) {
+func (io *InputOutput) animateNewResponseOnly(newResponse Message, origScrollPos fyne.Position) {
 	// If already animating, stop current animation
 	if io.animating && io.animationTicker != nil {
 		io.animationTicker.Stop()
@@ -239,48 +543,21 @@ func (io *InputOutput) animateNewResponseOnly(newResponse string, origScrollPos
 	// Setup animation
 	io.animating = true
 
-	// Calculate where the "AI:" part starts
-	aiIndex := strings.Index(newResponse, "AI: ")
-	if aiIndex == -1 {
-		// Fallback if format is different
-		aiIndex = 0
-	} else {
-		aiIndex += 4 // Move past "AI: "
-	}
-
-	// Get the AI part of the response
-	aiResponse := ""
-	if aiIndex < len(newResponse) {
-		aiResponse = newResponse[aiIndex:]
-	}
-
 	// Split into prefix (the part before AI's text) and the AI text to animate
-	prefix := newResponse[:aiIndex]
+	prefix := newResponse.Prefix()
+	aiResponse := newResponse.Content
 
 	// Show everything except the AI response immediately
-	fullPreviousContent := ""
-	if len(io.Conversation) > 1 {
-		// Join all previous conversations
-		previousConvs := io.Conversation[:len(io.Conversation)-1]
-		fullPreviousContent = strings.Join(previousConvs, "\n\n")
-
-		// Add a separator
-		if fullPreviousContent != "" {
-			fullPreviousContent += "\n\n"
-		}
-	}
-
-	// Initial display (everything except AI response)
-	initialContent := fullPreviousContent + prefix
-	io.OutputLabel.SetText(initialContent)
-	io.OutputLabel.Refresh()
+	io.refreshMessages(io.Messages[:len(io.Messages)-1])
+	responseLabel := newMessageLabel(prefix)
+	io.MessageList.Add(responseLabel)
 
 	// Respect user's scroll position
 	if io.ScrollContainer != nil {
 		// Only auto-scroll if user was already at bottom
 		wasAtBottom := (io.ScrollContainer.Offset.Y >= io.ScrollContainer.Content.Size().Height-io.ScrollContainer.Size().Height-10)
 
-		if !wasAtBottom && origScrollPos.Y > 0 {
+		if !io.autoScroll() || (!wasAtBottom && origScrollPos.Y > 0) {
 			// Restore previous position
 			io.ScrollContainer.Offset = origScrollPos
 		} else {
@@ -291,10 +568,10 @@ func (io *InputOutput) animateNewResponseOnly(newResponse string, origScrollPos
 	}
 
 	// Create animation ticker
-	io.animationTicker = time.NewTicker(20 * time.Millisecond)
+	io.animationTicker = time.NewTicker(animationInterval)
 
 	// Animation variables
-	batchSize := 3
+	batchSize := animationBatchSize(io.animationSpeed())
 	aiCharIndex := 0
 
 	// Start animation in goroutine
@@ -318,16 +595,11 @@ func (io *InputOutput) animateNewResponseOnly(newResponse string, origScrollPos
 				endIdx = len(aiResponse)
 			}
 
-			// Build current display text
-			currentAIText := aiResponse[:endIdx]
-			displayText := initialContent + currentAIText
-
 			// Update display
-			io.OutputLabel.SetText(displayText)
-			io.OutputLabel.Refresh()
+			responseLabel.SetText(prefix + aiResponse[:endIdx])
 
 			// Smart scrolling - only auto-scroll if user is already at bottom
-			if io.ScrollContainer != nil {
+			if io.ScrollContainer != nil && io.autoScroll() {
 				position := io.ScrollContainer.Offset
 				contentHeight := io.ScrollContainer.Content.Size().Height
 				visibleHeight := io.ScrollContainer.Size().Height
@@ -347,13 +619,34 @@ func (io *InputOutput) animateNewResponseOnly(newResponse string, origScrollPos
 			}
 		}
 
-		// Ensure final state is displayed
-		finalContent := initialContent + aiResponse
-		io.OutputLabel.SetText(finalContent)
-		io.OutputLabel.Refresh()
+		// Ensure final state is displayed, with the controls of the message
+		responseLabel.SetText(prefix + aiResponse)
+		io.refreshMessages(io.Messages)
 	}()
 }
 
+// animationInterval is how often the animation reveals more of a reply
+const animationInterval = 20 * time.Millisecond
+
+// animationBatchSize converts the animation speed setting into the number
+// of characters revealed per tick. The default speed of 20 shows 3.
+func animationBatchSize(speed float64) int {
+	return max(1, int(speed*3/20+0.5))
+}
+
+// animationSpeed returns the animation speed of the settings
+func (io *InputOutput) animationSpeed() float64 {
+	if io.Settings == nil {
+		return defaultSettingsData().AnimationSpeed
+	}
+	return io.Settings.GetAnimationSpeed()
+}
+
+// autoScroll reports whether the chat follows new messages
+func (io *InputOutput) autoScroll() bool {
+	return io.Settings == nil || io.Settings.IsAutoScrollEnabled()
+}
+
 // Improved method to stop animation
 func (io *InputOutput) stopAnimation() {
 	io.animating = false
@@ -363,6 +656,97 @@ func (io *InputOutput) stopAnimation() {
 	}
 }
 
+// toChatMessages converts the conversation into the chat format sent to the
+// model. Images are only included when the model can see them.
+func toChatMessages(messages []Message, withImages bool) ([]ChatMessage, error) {
+	chatMessages := make([]ChatMessage, 0, len(messages))
+	for _, m := range messages {
+		chatMessage := ChatMessage{
+			Role:      m.Role,
+			Content:   m.Content,
+			ToolCalls: m.ToolCalls,
+			ToolName:  m.ToolName,
+		}
+		if withImages {
+			for _, name := range m.Images {
+				data, err := loadImage(name)
+				if err != nil {
+					return nil, fmt.Errorf("failed to load image %s: %v", name, err)
+				}
+				chatMessage.Images = append(chatMessage.Images, data)
+			}
+		}
+		chatMessages = append(chatMessages, chatMessage)
+	}
+	return chatMessages, nil
+}
+
+// runToolCalls executes the tools requested by the model and returns their
+// results as tool messages. Failures are reported back to the model so it
+// can recover.
+func (io *InputOutput) runToolCalls(ctx context.Context, calls []ToolCall) []Message {
+	results := make([]Message, 0, len(calls))
+	for _, call := range calls {
+		var result string
+		var err error
+		if io.Tools.NeedsApproval(call.Function.Name) && !io.confirmToolCall(ctx, call) {
+			err = fmt.Errorf("the user declined to run %s", call.Function.Name)
+		} else {
+			result, err = io.Tools.Call(ctx, call)
+		}
+		if err != nil {
+			result = "error: " + err.Error()
+		}
+		results = append(results, Message{
+			Role:     RoleTool,
+			Content:  result,
+			ToolName: call.Function.Name,
+			Time:     time.Now(),
+		})
+	}
+	return results
+}
+
+// confirmToolCall asks the user whether the model may run a tool and waits
+// for the answer. Stopping the generation closes the question and denies.
+func (io *InputOutput) confirmToolCall(ctx context.Context, call ToolCall) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	answer := make(chan bool, 1)
+
+	details := widget.NewLabel(fmt.Sprintf("%s wants to run:\n\n%s", io.ModelSelect.Selected, formatToolCall(call)))
+	details.Wrapping = fyne.TextWrapWord
+
+	var approval dialog.Dialog
+	respond := func(allowed, always bool) {
+		if always {
+			io.Tools.Approve(call.Function.Name)
+		}
+		select {
+		case answer <- allowed:
+		default:
+		}
+		approval.Hide()
+	}
+	buttons := container.NewHBox(
+		widget.NewButton("Deny", func() { respond(false, false) }),
+		widget.NewButton("Allow Once", func() { respond(true, false) }),
+		widget.NewButton("Always Allow", func() { respond(true, true) }),
+	)
+
+	approval = dialog.NewCustomWithoutButtons("Allow Tool Call?", container.NewVBox(details, buttons), io.ParentWindow)
+	approval.Resize(fyne.NewSize(450, 200))
+	approval.Show()
+	select {
+	case allowed := <-answer:
+		return allowed
+	case <-ctx.Done():
+		approval.Hide()
+		return false
+	}
+}
+
 func (io *InputOutput) GenerateResponse() {
 	modelName := io.ModelSelect.Selected
 	if modelName == "" {
@@ -375,129 +759,209 @@ func (io *InputOutput) GenerateResponse() {
 		return
 	}
 
-	filePath := fmt.Sprintf("./tmp/%s.txt", modelName)
+	if len(io.pendingImages) > 0 && !io.supportsVision {
+		dialog.ShowInformation("Images Not Supported",
+			fmt.Sprintf("The selected model %q does not accept images. Remove the attachments or choose a vision model.", modelName),
+			io.ParentWindow)
+		return
+	}
 
-	// Disable input during generation
+	// Disable input during generation. The model and the chat stay as they
+	// are until the reply is in.
 	io.InputEntry.Disable()
 	io.ClearButton.Disable()
+	io.ClearChatButton.Disable()
+	io.ModelSelect.Disable()
+	io.AttachButton.Disable()
+
+	// Take the pending attachments along with the prompt
+	userMessage := Message{
+		Role:    RoleUser,
+		Content: userPrompt,
+		Images:  io.pendingImages,
+		Time:    time.Now(),
+	}
+	io.pendingImages = nil
+	io.refreshAttachments()
+
+	history := make([]Message, len(io.Messages), len(io.Messages)+1)
+	copy(history, io.Messages)
+	history = append(history, userMessage)
 
 	// Show "thinking" indicator with better formatting
-	originalConversation := make([]string, len(io.Conversation))
-	copy(originalConversation, io.Conversation)
+	showThinking := func() {
+		io.refreshMessages(history)
+		io.MessageList.Add(newMessageLabel("AI: Thinking..."))
+		if io.ScrollContainer != nil && io.autoScroll() {
+			io.ScrollContainer.ScrollToBottom()
+		}
+	}
+	showThinking()
 
-	thinkingMessage := strings.Join(io.Conversation, "\n\n") + "\n\nYou: " + userPrompt + "\n\nAI: Thinking..."
-	io.OutputLabel.SetText(thinkingMessage)
-	io.OutputLabel.Refresh()
+	enableInput := func() {
+		io.InputEntry.Enable()
+		io.ClearButton.Enable()
+		io.ClearChatButton.Enable()
+		io.ModelSelect.Enable()
+		if io.supportsVision {
+			io.AttachButton.Enable()
+		}
+	}
 
 	// Process in background
 	go func() {
-		ctx := context.Background()
+		ctx, cancel := context.WithCancel(context.Background())
+		io.stopGeneration = cancel
+		defer func() {
+			cancel()
+			io.stopGeneration = nil
+		}()
+		client := NewOllamaClient()
 
-		// Create Ollama instance with settings
-		llm, err := ollama.New(
-			ollama.WithModel(modelName),
-		)
+		// Only offer tools to models that know how to call them
+		var tools []ToolDefinition
+		if io.Tools != nil && io.Settings.AreToolsEnabled() && SupportsTools(modelName) {
+			tools = io.Tools.Definitions()
+		}
+
+		// The project of the chat adds its system prompt and files
+		project, err := io.projectContext()
 		if err != nil {
-			io.OutputLabel.SetText(strings.Join(io.Conversation, "\n\n"))
-			dialog.ShowError(fmt.Errorf("Failed to connect to model: %v", err), io.ParentWindow)
-			io.InputEntry.Enable()
-			io.ClearButton.Enable()
+			io.refreshMessages(io.Messages)
+			ShowError(fmt.Errorf("Failed to load project: %v", err), io.ParentWindow)
+			enableInput()
 			return
 		}
 
-		// Apply settings to the context
-		ctx = context.WithValue(ctx, "temperature", io.Settings.GetTemperature())
-		ctx = context.WithValue(ctx, "top_p", io.Settings.GetTopP())
-		ctx = context.WithValue(ctx, "top_k", io.Settings.GetTopK())
-		ctx = context.WithValue(ctx, "num_ctx", io.Settings.GetContextLength())
-		ctx = context.WithValue(ctx, "num_predict", io.Settings.GetMaxTokens())
+		var reply *ChatResponse
+		summary := io.Summary
+		for round := 0; ; round++ {
+			// Keep the conversation within the context window
+			var send []Message
+			send, summary = io.fitContext(ctx, client, modelName, project, history, summary)
 
-		fullPrompt := strings.Join(io.Conversation, "\n\n")
-		if fullPrompt != "" {
-			fullPrompt += "\n\n"
-		}
-		fullPrompt += userPrompt
+			messages, err := toChatMessages(send, io.supportsVision)
+			if err != nil {
+				io.refreshMessages(io.Messages)
+				ShowError(fmt.Errorf("Failed to prepare message: %v", err), io.ParentWindow)
+				enableInput()
+				return
+			}
 
-		response, err := llms.GenerateFromSinglePrompt(ctx, llm, fullPrompt)
-		if err != nil {
-			io.Conversation = originalConversation
-			io.OutputLabel.SetText(strings.Join(io.Conversation, "\n\n"))
-			dialog.ShowError(fmt.Errorf("Failed to generate response: %v", err), io.ParentWindow)
-			io.InputEntry.Enable()
-			io.ClearButton.Enable()
-			return
-		}
+			// The last round has no tools so the model has to answer
+			request := io.Settings.NewChatRequest(modelName, &io.Parameters, messages)
+			if round < maxToolRounds {
+				request.Tools = tools
+			}
 
-		formattedEntry := "You: " + userPrompt + "\n\nAI: " + response
-		io.SetOutput(formattedEntry)
-		io.InputEntry.Enable()
-		io.ClearButton.Enable()
+			reply, err = client.ChatOnce(ctx, request)
+			if err != nil {
+				io.refreshMessages(io.Messages)
+				if ctx.Err() != nil {
+					// Stopped by the user, the prompt is given back to edit
+					io.InputEntry.SetText(userPrompt)
+					enableInput()
+					return
+				}
+				ShowError(fmt.Errorf("Failed to generate response: %v", err), io.ParentWindow)
+				enableInput()
+				return
+			}
 
-		// Save the conversation to the file
-		file, err := os.OpenFile(filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-		if err != nil {
-			dialog.ShowError(fmt.Errorf("Failed to save conversation: %v", err), io.ParentWindow)
-			return
+			if len(reply.Message.ToolCalls) == 0 {
+				break
+			}
+
+			// Show the tool calls, run them and hand the results back
+			history = append(history, Message{
+				Role:      RoleAssistant,
+				Content:   reply.Message.Content,
+				ToolCalls: reply.Message.ToolCalls,
+				Time:      time.Now(),
+				Tokens:    reply.EvalCount,
+				Stats:     newGenerationStats(reply),
+			})
+			showThinking()
+			history = append(history, io.runToolCalls(ctx, reply.Message.ToolCalls)...)
+			showThinking()
 		}
-		defer file.Close()
 
-		_, err = file.WriteString(formattedEntry + "\n\n")
+		// Keep pins changed while the reply was generated
+		for i := range io.Messages {
+			if i < len(history) {
+				history[i].Pinned = io.Messages[i].Pinned
+			}
+		}
+		io.Messages = history
+		io.Summary = summary
+		io.SetOutput(Message{
+			Role:    RoleAssistant,
+			Content: reply.Message.Content,
+			Time:    time.Now(),
+			Tokens:  reply.EvalCount,
+			Stats:   newGenerationStats(reply),
+		})
+		enableInput()
+
+		// Save the conversation to the file
+		err = saveConversation(modelName, io.conversation())
 		if err != nil {
-			dialog.ShowError(fmt.Errorf("Failed to write conversation: %v", err), io.ParentWindow)
+			ShowError(fmt.Errorf("Failed to save conversation: %v", err), io.ParentWindow)
 			return
 		}
 
 		// Save to conversations history
-		err = saveConversationToHistory(modelName, io.Conversation)
+		err = saveConversationToHistory(modelName, io.conversation())
 		if err != nil {
-			dialog.ShowError(fmt.Errorf("Failed to save to conversation history: %v", err), io.ParentWindow)
+			ShowError(fmt.Errorf("Failed to save to conversation history: %v", err), io.ParentWindow)
 		}
+		io.changed()
+		io.nameAfterFirstExchange(modelName)
 	}()
 }
 
+// GetContainer returns the chat view. It is built once and reused so the
+// tab showing it can be matched back to this chat.
 func (io *InputOutput) GetContainer() *fyne.Container {
-	io.ScrollContainer = container.NewVScroll(io.OutputLabel)
+	if io.content != nil {
+		return io.content
+	}
+
+	io.ScrollContainer = container.NewVScroll(io.MessageList)
 	io.ScrollContainer.SetMinSize(fyne.NewSize(400, 300))
-	io.OutputLabel.Wrapping = fyne.TextWrapWord
 
 	// Create a container for the model selection and clear button
-	topBar := container.NewHBox(
+	controls := container.NewHBox(
 		widget.NewLabel("Model:"),
 		io.ModelSelect,
-		widget.NewButton("Clear Chat", func() {
-			// Get the file path for the current model
-			filePath := fmt.Sprintf("./tmp/%s.txt", io.ModelSelect.Selected)
-
-			// Delete the file content by creating an empty file
-			err := os.WriteFile(filePath, []byte(""), 0644)
-			if err != nil {
-				dialog.ShowError(fmt.Errorf("Failed to clear chat history: %v", err), io.ParentWindow)
-				return
-			}
-
-			// Clear the current conversation
-			io.Conversation = []string{}
-			io.OutputLabel.SetText(fmt.Sprintf("Welcome to NeuraTalk! You are now chatting with %s.\n\nType your message below to begin.", io.ModelSelect.Selected))
-			io.OutputLabel.Refresh()
+		io.ClearChatButton,
+		widget.NewButton("Parameters", io.showParameters),
+		widget.NewButton("Details", io.showDetails),
+	)
+	if io.MCP != nil {
+		controls.Add(widget.NewButton("Prompts", io.showPromptPicker))
+	}
 
-			// Create a new chat instance with the same model
-			newIO := NewInputOutput([]string{io.ModelSelect.Selected}, io.ParentWindow, io.Settings)
-			newIO.ModelSelect.SetSelected(io.ModelSelect.Selected)
+	// Show how full the context window is on the right
+	meterSize := fyne.NewSize(200, io.ContextMeter.MinSize().Height)
+	topBar := container.NewBorder(nil, nil, controls, container.NewCenter(container.NewGridWrap(meterSize, io.ContextMeter)))
+	io.updateContextMeter()
 
-			// Update the last chat in the chat manager
-			if manager, ok := io.ParentWindow.(interface{ SetLastChat(*InputOutput) }); ok {
-				manager.SetLastChat(newIO)
-			}
-		}),
+	// Attachments sit above the input, the attach button next to it
+	bottomBar := container.NewVBox(
+		io.AttachmentBar,
+		container.NewBorder(nil, nil, io.AttachButton, nil, io.InputEntry),
+		io.InputEntry.Counter,
 	)
 
-	return container.NewBorder(
+	io.content = container.NewBorder(
 		topBar,             // top
-		io.InputEntry,      // bottom
+		bottomBar,          // bottom
 		nil,                // left
 		nil,                // right
 		io.ScrollContainer, // center
 	)
+	return io.content
 }
 
 // Add a method to manually control animation speed
@@ -508,6 +972,25 @@ func (io *InputOutput) SetAnimationSpeed(millisPerChar int) {
 	}
 }
 
+// StopGeneration cancels the reply being generated and shows a reply that
+// is still being typed out in full
+func (io *InputOutput) StopGeneration() {
+	if io.stopGeneration != nil {
+		io.stopGeneration()
+	}
+	if io.animating {
+		io.stopAnimation()
+		io.refreshMessages(io.Messages)
+	}
+}
+
+// FocusInput moves the keyboard focus to the composer
+func (io *InputOutput) FocusInput() {
+	if canvas := fyne.CurrentApp().Driver().CanvasForObject(io.InputEntry); canvas != nil {
+		canvas.Focus(io.InputEntry)
+	}
+}
+
 // Add a method to skip animation
 func (io *InputOutput) SkipAnimation() {
 	if io.animating {
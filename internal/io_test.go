@@ -0,0 +1,53 @@
+package internal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"fyne.io/fyne/v2/test"
+	"fyne.io/fyne/v2/widget"
+)
+
+func TestStoppingDeniesPendingToolCall(t *testing.T) {
+	a := test.NewApp()
+	t.Cleanup(a.Quit)
+	w := test.NewWindow(nil)
+	io := &InputOutput{ModelSelect: widget.NewSelect(nil, nil), ParentWindow: w}
+	call := ToolCall{Function: ToolCallFunction{Name: "read_file"}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	answer := make(chan bool)
+	go func() {
+		answer <- io.confirmToolCall(ctx, call)
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for w.Canvas().Overlays().Top() == nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if w.Canvas().Overlays().Top() == nil {
+		t.Fatal("approval was not asked for")
+	}
+
+	cancel()
+	select {
+	case allowed := <-answer:
+		if allowed {
+			t.Error("stopped tool call was allowed")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("approval still waits after stopping")
+	}
+	if w.Canvas().Overlays().Top() != nil {
+		t.Error("approval is still shown after stopping")
+	}
+
+	// Once stopped, later tool calls are denied without asking
+	if io.confirmToolCall(ctx, call) {
+		t.Error("tool call after stopping was allowed")
+	}
+	if w.Canvas().Overlays().Top() != nil {
+		t.Error("approval was asked for after stopping")
+	}
+}
@@ -0,0 +1,200 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+)
+
+// maxLogSize is how large the log file grows before it is rotated
+const maxLogSize = 1 << 20
+
+// keptLogFiles is how many rotated log files are kept next to the current one
+const keptLogFiles = 3
+
+// maxRecentLogLines is how many log lines the diagnostics view can show
+const maxRecentLogLines = 500
+
+// logLevel is the lowest level written, changeable while running
+var logLevel = new(slog.LevelVar)
+
+// recentLogs keeps the last lines written to the log
+var recentLogs = &logRing{}
+
+// logsDirectory holds the log file and its rotated copies
+func logsDirectory() string {
+	return filepath.Join(DataDir(), "logs")
+}
+
+// logFilePath returns the file currently logged to
+func logFilePath() string {
+	return filepath.Join(logsDirectory(), "neuratalk.log")
+}
+
+// rotatingFile appends to a file, moving it aside to name.1, name.2, ...
+// once it grows past maxLogSize
+type rotatingFile struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	size int64
+}
+
+// openRotatingFile opens a log file for appending
+func openRotatingFile(path string) (*rotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %v", err)
+	}
+	r := &rotatingFile{path: path}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %v", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to open log file: %v", err)
+	}
+	r.file = file
+	r.size = info.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.size > 0 && r.size+int64(len(p)) > maxLogSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	// A failed rotation left no file open, try again
+	if r.file == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the rotated files by one, dropping the oldest, and starts
+// a new file
+func (r *rotatingFile) rotate() error {
+	if r.file != nil {
+		r.file.Close()
+		r.file = nil
+	}
+	for i := keptLogFiles - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+	}
+	if err := os.Rename(r.path, r.path+".1"); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to rotate log file: %v", err)
+	}
+	return r.open()
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// logRing remembers the last maxRecentLogLines lines written to it
+type logRing struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *logRing) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		l.lines = append(l.lines, line)
+	}
+	if extra := len(l.lines) - maxRecentLogLines; extra > 0 {
+		l.lines = append([]string(nil), l.lines[extra:]...)
+	}
+	return len(p), nil
+}
+
+// Lines returns the remembered lines, oldest first
+func (l *logRing) Lines() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.lines...)
+}
+
+// InitLogging sends slog and the standard logger to stderr, the rotating
+// log file in the data directory and the diagnostics view. level is one of
+// debug, info, warn or error. The returned function closes the log file.
+// Logging goes on without the file when it can't be opened, and at info
+// level when the level is unknown.
+func InitLogging(level string) (func(), error) {
+	parsed, levelErr := parseLogLevel(level)
+	logLevel.Set(parsed)
+
+	// A writer that fails stops the ones after it, so stderr, which GUI
+	// builds may not have, comes last
+	writers := []io.Writer{recentLogs}
+	file, fileErr := openRotatingFile(logFilePath())
+	if fileErr == nil {
+		writers = append(writers, file)
+	}
+	writers = append(writers, os.Stderr)
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.MultiWriter(writers...), &slog.HandlerOptions{Level: logLevel})))
+
+	closeLog := func() {
+		if file != nil {
+			file.Close()
+		}
+	}
+	if levelErr != nil {
+		return closeLog, levelErr
+	}
+	return closeLog, fileErr
+}
+
+// parseLogLevel reads a level name, an empty name is info
+func parseLogLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if name == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return slog.LevelInfo, fmt.Errorf("unknown log level %q, use debug, info, warn or error", name)
+	}
+	return level, nil
+}
+
+// logLevelNames are the levels offered in the diagnostics view
+var logLevelNames = []string{"DEBUG", "INFO", "WARN", "ERROR"}
+
+// ShowError logs an error and shows it in a dialog
+func ShowError(err error, parent fyne.Window) {
+	slog.Error(err.Error())
+	dialog.ShowError(err, parent)
+}
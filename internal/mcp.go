@@ -0,0 +1,473 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// mcpProtocolVersion is the MCP revision this client speaks
+const mcpProtocolVersion = "2024-11-05"
+
+// MCPServerConfig declares an MCP server launched over stdio
+type MCPServerConfig struct {
+	Name    string            `json:"name"`
+	Command string            `json:"command"`
+	Args    []string          `json:"args,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+	Enabled bool              `json:"enabled"`
+}
+
+// MCPTool is a tool offered by an MCP server
+type MCPTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"inputSchema"`
+}
+
+// MCPResource is a piece of context an MCP server can provide
+type MCPResource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MIMEType    string `json:"mimeType,omitempty"`
+}
+
+// MCPPrompt is a prompt template offered by an MCP server
+type MCPPrompt struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description,omitempty"`
+	Arguments   []MCPPromptArgument `json:"arguments,omitempty"`
+}
+
+// MCPPromptArgument is a value filled into a prompt template
+type MCPPromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+// mcpContent is an item of a tool result, resource or prompt message
+type mcpContent struct {
+	Type     string       `json:"type"`
+	Text     string       `json:"text,omitempty"`
+	MIMEType string       `json:"mimeType,omitempty"`
+	Resource *mcpContents `json:"resource,omitempty"`
+}
+
+// mcpContents is the content of a resource
+type mcpContents struct {
+	URI      string `json:"uri"`
+	MIMEType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"`
+}
+
+// mcpServerCapabilities lists what a server supports
+type mcpServerCapabilities struct {
+	Tools     *struct{} `json:"tools,omitempty"`
+	Resources *struct{} `json:"resources,omitempty"`
+	Prompts   *struct{} `json:"prompts,omitempty"`
+}
+
+// rpcMessage is any JSON-RPC 2.0 message: request, notification or response
+type rpcMessage struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  any              `json:"params,omitempty"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *rpcError        `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// MCPClient is a connection to an MCP server running as a child process
+type MCPClient struct {
+	Config       MCPServerConfig
+	Capabilities mcpServerCapabilities
+
+	// OnListChanged is called when the server reports changed tools,
+	// resources or prompts
+	OnListChanged func()
+
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	writeMu sync.Mutex
+	nextID  atomic.Int64
+
+	pendingMu sync.Mutex
+	pending   map[int64]chan rpcMessage
+
+	done    chan struct{}
+	doneErr error
+}
+
+// StartMCPClient launches the server and performs the MCP handshake
+func StartMCPClient(ctx context.Context, config MCPServerConfig) (*MCPClient, error) {
+	if strings.TrimSpace(config.Command) == "" {
+		return nil, fmt.Errorf("no command configured")
+	}
+
+	cmd := exec.Command(config.Command, config.Args...)
+	cmd.Env = os.Environ()
+	for key, value := range config.Env {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %v", config.Command, err)
+	}
+
+	c := &MCPClient{
+		Config:  config,
+		cmd:     cmd,
+		stdin:   stdin,
+		pending: map[int64]chan rpcMessage{},
+		done:    make(chan struct{}),
+	}
+
+	// Servers log to stderr
+	go func() {
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			slog.Info("MCP server output", "server", config.Name, "line", scanner.Text())
+		}
+	}()
+	go c.readLoop(stdout)
+
+	var initResult struct {
+		Capabilities mcpServerCapabilities `json:"capabilities"`
+	}
+	err = c.call(ctx, "initialize", map[string]any{
+		"protocolVersion": mcpProtocolVersion,
+		"capabilities":    map[string]any{},
+		"clientInfo": map[string]any{
+			"name":    "NeuraTalk",
+			"version": "1.0",
+		},
+	}, &initResult)
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("failed to initialize: %v", err)
+	}
+	c.Capabilities = initResult.Capabilities
+
+	if err := c.notify("notifications/initialized", nil); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// readLoop dispatches every message the server writes to stdout
+func (c *MCPClient) readLoop(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var msg rpcMessage
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			slog.Warn("Invalid MCP message", "server", c.Config.Name, "err", err)
+			continue
+		}
+
+		switch {
+		case msg.Method != "" && msg.ID != nil:
+			c.handleRequest(msg)
+		case msg.Method != "":
+			c.handleNotification(msg)
+		case msg.ID != nil:
+			var id int64
+			if err := json.Unmarshal(*msg.ID, &id); err != nil {
+				continue
+			}
+			c.pendingMu.Lock()
+			ch, ok := c.pending[id]
+			delete(c.pending, id)
+			c.pendingMu.Unlock()
+			if ok {
+				ch <- msg
+			}
+		}
+	}
+
+	c.doneErr = scanner.Err()
+	if c.doneErr == nil {
+		c.doneErr = fmt.Errorf("server exited")
+	}
+	close(c.done)
+}
+
+// handleRequest answers requests the server sends to the client
+func (c *MCPClient) handleRequest(msg rpcMessage) {
+	reply := rpcMessage{JSONRPC: "2.0", ID: msg.ID}
+	if msg.Method == "ping" {
+		reply.Result = json.RawMessage("{}")
+	} else {
+		reply.Error = &rpcError{Code: -32601, Message: "method not supported: " + msg.Method}
+	}
+	if err := c.write(reply); err != nil {
+		slog.Warn("Failed to answer MCP request", "server", c.Config.Name, "method", msg.Method, "err", err)
+	}
+}
+
+// handleNotification reacts to notifications from the server
+func (c *MCPClient) handleNotification(msg rpcMessage) {
+	switch msg.Method {
+	case "notifications/tools/list_changed", "notifications/resources/list_changed", "notifications/prompts/list_changed":
+		if c.OnListChanged != nil {
+			go c.OnListChanged()
+		}
+	}
+}
+
+func (c *MCPClient) write(msg rpcMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err = c.stdin.Write(append(data, '\n'))
+	return err
+}
+
+// call sends a request and decodes its result into result
+func (c *MCPClient) call(ctx context.Context, method string, params any, result any) error {
+	id := c.nextID.Add(1)
+	rawID := json.RawMessage(fmt.Sprint(id))
+	ch := make(chan rpcMessage, 1)
+
+	c.pendingMu.Lock()
+	c.pending[id] = ch
+	c.pendingMu.Unlock()
+	defer func() {
+		c.pendingMu.Lock()
+		delete(c.pending, id)
+		c.pendingMu.Unlock()
+	}()
+
+	if params == nil {
+		params = map[string]any{}
+	}
+	if err := c.write(rpcMessage{JSONRPC: "2.0", ID: &rawID, Method: method, Params: params}); err != nil {
+		return fmt.Errorf("failed to send %s: %v", method, err)
+	}
+
+	select {
+	case msg := <-ch:
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result != nil && len(msg.Result) > 0 {
+			if err := json.Unmarshal(msg.Result, result); err != nil {
+				return fmt.Errorf("invalid %s result: %v", method, err)
+			}
+		}
+		return nil
+	case <-c.done:
+		return fmt.Errorf("server stopped: %v", c.doneErr)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *MCPClient) notify(method string, params any) error {
+	return c.write(rpcMessage{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+// ListTools returns every tool of the server, following pagination
+func (c *MCPClient) ListTools(ctx context.Context) ([]MCPTool, error) {
+	var tools []MCPTool
+	cursor := ""
+	for {
+		var page struct {
+			Tools      []MCPTool `json:"tools"`
+			NextCursor string    `json:"nextCursor"`
+		}
+		if err := c.call(ctx, "tools/list", cursorParams(cursor), &page); err != nil {
+			return nil, err
+		}
+		tools = append(tools, page.Tools...)
+		if page.NextCursor == "" {
+			return tools, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// CallTool runs a tool and returns its text output
+func (c *MCPClient) CallTool(ctx context.Context, name string, args map[string]any) (string, error) {
+	var result struct {
+		Content []mcpContent `json:"content"`
+		IsError bool         `json:"isError"`
+	}
+	err := c.call(ctx, "tools/call", map[string]any{"name": name, "arguments": args}, &result)
+	if err != nil {
+		return "", err
+	}
+
+	text := joinMCPContent(result.Content)
+	if result.IsError {
+		return "", fmt.Errorf("%s", text)
+	}
+	return text, nil
+}
+
+// ListResources returns every resource of the server
+func (c *MCPClient) ListResources(ctx context.Context) ([]MCPResource, error) {
+	var resources []MCPResource
+	cursor := ""
+	for {
+		var page struct {
+			Resources  []MCPResource `json:"resources"`
+			NextCursor string        `json:"nextCursor"`
+		}
+		if err := c.call(ctx, "resources/list", cursorParams(cursor), &page); err != nil {
+			return nil, err
+		}
+		resources = append(resources, page.Resources...)
+		if page.NextCursor == "" {
+			return resources, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// ReadResource returns the text of a resource
+func (c *MCPClient) ReadResource(ctx context.Context, uri string) (string, error) {
+	var result struct {
+		Contents []mcpContents `json:"contents"`
+	}
+	if err := c.call(ctx, "resources/read", map[string]any{"uri": uri}, &result); err != nil {
+		return "", err
+	}
+
+	parts := make([]string, 0, len(result.Contents))
+	for _, content := range result.Contents {
+		if content.Text != "" {
+			parts = append(parts, content.Text)
+		} else if content.Blob != "" {
+			parts = append(parts, fmt.Sprintf("[binary %s content]", content.MIMEType))
+		}
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
+
+// ListPrompts returns every prompt of the server
+func (c *MCPClient) ListPrompts(ctx context.Context) ([]MCPPrompt, error) {
+	var prompts []MCPPrompt
+	cursor := ""
+	for {
+		var page struct {
+			Prompts    []MCPPrompt `json:"prompts"`
+			NextCursor string      `json:"nextCursor"`
+		}
+		if err := c.call(ctx, "prompts/list", cursorParams(cursor), &page); err != nil {
+			return nil, err
+		}
+		prompts = append(prompts, page.Prompts...)
+		if page.NextCursor == "" {
+			return prompts, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// GetPrompt fills in a prompt template and returns its text
+func (c *MCPClient) GetPrompt(ctx context.Context, name string, args map[string]string) (string, error) {
+	var result struct {
+		Messages []struct {
+			Role    string     `json:"role"`
+			Content mcpContent `json:"content"`
+		} `json:"messages"`
+	}
+	err := c.call(ctx, "prompts/get", map[string]any{"name": name, "arguments": args}, &result)
+	if err != nil {
+		return "", err
+	}
+
+	contents := make([]mcpContent, 0, len(result.Messages))
+	for _, m := range result.Messages {
+		contents = append(contents, m.Content)
+	}
+	return joinMCPContent(contents), nil
+}
+
+// Done is closed when the connection to the server ends
+func (c *MCPClient) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns why the connection ended
+func (c *MCPClient) Err() error {
+	select {
+	case <-c.done:
+		return c.doneErr
+	default:
+		return nil
+	}
+}
+
+// Close stops the server
+func (c *MCPClient) Close() error {
+	c.stdin.Close()
+	if c.cmd.Process != nil {
+		c.cmd.Process.Kill()
+	}
+	return c.cmd.Wait()
+}
+
+// cursorParams builds the parameters of a paginated list request
+func cursorParams(cursor string) map[string]any {
+	if cursor == "" {
+		return nil
+	}
+	return map[string]any{"cursor": cursor}
+}
+
+// joinMCPContent flattens content items into text for the model
+func joinMCPContent(contents []mcpContent) string {
+	parts := make([]string, 0, len(contents))
+	for _, content := range contents {
+		switch content.Type {
+		case "text":
+			parts = append(parts, content.Text)
+		case "resource":
+			if content.Resource != nil && content.Resource.Text != "" {
+				parts = append(parts, content.Resource.Text)
+			}
+		default:
+			parts = append(parts, fmt.Sprintf("[%s content]", content.Type))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
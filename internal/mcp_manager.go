@@ -0,0 +1,347 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mcpStartTimeout bounds how long a server may take to start and list its tools
+const mcpStartTimeout = 30 * time.Second
+
+// mcpNameSanitizer replaces characters models don't accept in tool names
+var mcpNameSanitizer = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// MCPPromptRef points to a prompt of a running MCP server
+type MCPPromptRef struct {
+	Server string
+	Prompt MCPPrompt
+}
+
+// mcpServer is the state of a configured MCP server
+type mcpServer struct {
+	config    MCPServerConfig
+	client    *MCPClient
+	tools     []string
+	resources []MCPResource
+	prompts   []MCPPrompt
+	err       error
+}
+
+// MCPManager runs the MCP servers declared in the settings and exposes
+// their tools through the tool registry
+type MCPManager struct {
+	Tools *ToolRegistry
+
+	// OnStatusChanged is called whenever a server starts, stops or fails
+	OnStatusChanged func()
+
+	mu      sync.Mutex
+	servers map[string]*mcpServer
+}
+
+// NewMCPManager creates a manager registering tools into the given registry
+func NewMCPManager(tools *ToolRegistry) *MCPManager {
+	return &MCPManager{
+		Tools:   tools,
+		servers: map[string]*mcpServer{},
+	}
+}
+
+// mcpToolName builds the name a server tool is registered under
+func mcpToolName(server, tool string) string {
+	return mcpNameSanitizer.ReplaceAllString(server, "_") + "__" + mcpNameSanitizer.ReplaceAllString(tool, "_")
+}
+
+// Apply starts, stops and restarts servers to match the given configuration
+func (m *MCPManager) Apply(configs []MCPServerConfig) {
+	wanted := map[string]MCPServerConfig{}
+	for _, config := range configs {
+		if config.Enabled && config.Name != "" {
+			wanted[config.Name] = config
+		}
+	}
+
+	m.mu.Lock()
+	var stale []*mcpServer
+	for name, server := range m.servers {
+		config, ok := wanted[name]
+		if !ok || !reflect.DeepEqual(config, server.config) {
+			stale = append(stale, server)
+			delete(m.servers, name)
+		}
+	}
+	var started []*mcpServer
+	for name, config := range wanted {
+		if _, running := m.servers[name]; running {
+			continue
+		}
+		server := &mcpServer{config: config}
+		m.servers[name] = server
+		started = append(started, server)
+	}
+	m.mu.Unlock()
+
+	for _, server := range stale {
+		m.stop(server)
+	}
+	for _, server := range started {
+		go m.start(server)
+	}
+	m.statusChanged()
+}
+
+// start launches a server and registers what it offers
+func (m *MCPManager) start(server *mcpServer) {
+	ctx, cancel := context.WithTimeout(context.Background(), mcpStartTimeout)
+	defer cancel()
+
+	client, err := StartMCPClient(ctx, server.config)
+	if err != nil {
+		slog.Error("Failed to start MCP server", "server", server.config.Name, "err", err)
+		m.mu.Lock()
+		server.err = err
+		m.mu.Unlock()
+		m.statusChanged()
+		return
+	}
+
+	m.mu.Lock()
+	if m.servers[server.config.Name] != server {
+		// The server was removed while it was starting
+		m.mu.Unlock()
+		client.Close()
+		return
+	}
+	server.client = client
+	m.mu.Unlock()
+
+	client.OnListChanged = func() { m.refresh(server) }
+	m.refresh(server)
+
+	// Report servers that exit on their own
+	go func() {
+		<-client.Done()
+		m.mu.Lock()
+		exited := server.client == client
+		if exited {
+			server.err = fmt.Errorf("server exited: %v", client.Err())
+		}
+		m.mu.Unlock()
+		if exited {
+			m.statusChanged()
+		}
+	}()
+}
+
+// refresh lists the tools, resources and prompts of a server and registers
+// them again
+func (m *MCPManager) refresh(server *mcpServer) {
+	ctx, cancel := context.WithTimeout(context.Background(), mcpStartTimeout)
+	defer cancel()
+
+	m.mu.Lock()
+	client := server.client
+	m.mu.Unlock()
+	if client == nil {
+		return
+	}
+	name := server.config.Name
+
+	var tools []MCPTool
+	var resources []MCPResource
+	var prompts []MCPPrompt
+	var err error
+	if client.Capabilities.Tools != nil {
+		if tools, err = client.ListTools(ctx); err != nil {
+			slog.Warn("Failed to list MCP tools", "server", name, "err", err)
+		}
+	}
+	if client.Capabilities.Resources != nil {
+		if resources, err = client.ListResources(ctx); err != nil {
+			slog.Warn("Failed to list MCP resources", "server", name, "err", err)
+		}
+	}
+	if client.Capabilities.Prompts != nil {
+		if prompts, err = client.ListPrompts(ctx); err != nil {
+			slog.Warn("Failed to list MCP prompts", "server", name, "err", err)
+		}
+	}
+
+	m.mu.Lock()
+	defer func() {
+		m.mu.Unlock()
+		m.statusChanged()
+	}()
+	if m.servers[name] != server {
+		return
+	}
+
+	for _, toolName := range server.tools {
+		m.Tools.Unregister(toolName)
+	}
+	server.tools = nil
+	server.resources = resources
+	server.prompts = prompts
+
+	for _, tool := range tools {
+		toolName := mcpToolName(name, tool.Name)
+		remoteName := tool.Name
+		err := m.Tools.Register(Tool{
+			Name:        toolName,
+			Description: tool.Description,
+			Parameters:  tool.InputSchema,
+			Handler: func(ctx context.Context, args map[string]any) (string, error) {
+				return client.CallTool(ctx, remoteName, args)
+			},
+			Source:           "mcp:" + name,
+			RequiresApproval: true,
+		})
+		if err != nil {
+			slog.Warn("Failed to register MCP tool", "server", name, "tool", tool.Name, "err", err)
+			continue
+		}
+		server.tools = append(server.tools, toolName)
+	}
+
+	// Resources are offered to the model through a tool reading them
+	if len(resources) > 0 {
+		toolName := mcpToolName(name, "read_resource")
+		err := m.Tools.Register(Tool{
+			Name:        toolName,
+			Description: describeMCPResources(name, resources),
+			Parameters: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"uri": {"type": "string", "description": "URI of the resource to read"}
+				},
+				"required": ["uri"]
+			}`),
+			Handler: func(ctx context.Context, args map[string]any) (string, error) {
+				return client.ReadResource(ctx, stringArg(args, "uri"))
+			},
+			Source:           "mcp:" + name,
+			RequiresApproval: true,
+		})
+		if err != nil {
+			slog.Warn("Failed to register MCP resources", "server", name, "err", err)
+		} else {
+			server.tools = append(server.tools, toolName)
+		}
+	}
+}
+
+// describeMCPResources tells the model which resources a server has
+func describeMCPResources(server string, resources []MCPResource) string {
+	var description strings.Builder
+	fmt.Fprintf(&description, "Read a resource from the %s server. Available resources:", server)
+	for i, resource := range resources {
+		if i == 20 {
+			fmt.Fprintf(&description, "\n- and %d more", len(resources)-i)
+			break
+		}
+		fmt.Fprintf(&description, "\n- %s (%s)", resource.URI, resource.Name)
+		if resource.Description != "" {
+			fmt.Fprintf(&description, ": %s", resource.Description)
+		}
+	}
+	return description.String()
+}
+
+// stop unregisters the tools of a server and shuts it down
+func (m *MCPManager) stop(server *mcpServer) {
+	m.mu.Lock()
+	tools := server.tools
+	client := server.client
+	server.tools = nil
+	server.client = nil
+	m.mu.Unlock()
+
+	for _, toolName := range tools {
+		m.Tools.Unregister(toolName)
+	}
+	if client != nil {
+		client.Close()
+	}
+}
+
+// Close stops every server
+func (m *MCPManager) Close() {
+	m.mu.Lock()
+	servers := make([]*mcpServer, 0, len(m.servers))
+	for _, server := range m.servers {
+		servers = append(servers, server)
+	}
+	m.servers = map[string]*mcpServer{}
+	m.mu.Unlock()
+
+	for _, server := range servers {
+		m.stop(server)
+	}
+}
+
+// Status describes the state of a configured server
+func (m *MCPManager) Status(name string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	server, ok := m.servers[name]
+	switch {
+	case !ok:
+		return "stopped"
+	case server.err != nil:
+		return "error: " + server.err.Error()
+	case server.client == nil:
+		return "starting..."
+	}
+	return fmt.Sprintf("running, %d tools, %d resources, %d prompts", len(server.tools), len(server.resources), len(server.prompts))
+}
+
+// Prompts lists the prompts of all running servers
+func (m *MCPManager) Prompts() []MCPPromptRef {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var prompts []MCPPromptRef
+	for name, server := range m.servers {
+		for _, prompt := range server.prompts {
+			prompts = append(prompts, MCPPromptRef{Server: name, Prompt: prompt})
+		}
+	}
+	sort.Slice(prompts, func(i, j int) bool {
+		if prompts[i].Server != prompts[j].Server {
+			return prompts[i].Server < prompts[j].Server
+		}
+		return prompts[i].Prompt.Name < prompts[j].Prompt.Name
+	})
+	return prompts
+}
+
+// GetPrompt fills in a prompt of a running server
+func (m *MCPManager) GetPrompt(ctx context.Context, ref MCPPromptRef, args map[string]string) (string, error) {
+	m.mu.Lock()
+	server, ok := m.servers[ref.Server]
+	var client *MCPClient
+	if ok {
+		client = server.client
+	}
+	m.mu.Unlock()
+
+	if client == nil {
+		return "", fmt.Errorf("server %s is not running", ref.Server)
+	}
+	return client.GetPrompt(ctx, ref.Prompt.Name, args)
+}
+
+func (m *MCPManager) statusChanged() {
+	if m.OnStatusChanged != nil {
+		m.OnStatusChanged()
+	}
+}
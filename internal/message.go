@@ -0,0 +1,125 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Message roles used in a conversation
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
+	RoleSystem    = "system"
+)
+
+// Message is a single turn of a conversation
+type Message struct {
+	Role    string    `json:"role"`
+	Content string    `json:"content"`
+	Images  []string  `json:"images,omitempty"`
+	Time    time.Time `json:"time"`
+	// ToolCalls holds the tools an assistant message asked to run
+	ToolCalls []ToolCall `json:"toolCalls,omitempty"`
+	// ToolName names the tool whose result a tool message carries
+	ToolName string `json:"toolName,omitempty"`
+	// Tokens is the token count reported by the backend, 0 if unknown
+	Tokens int `json:"tokens,omitempty"`
+	// Pinned messages are never dropped from the context
+	Pinned bool `json:"pinned,omitempty"`
+	// Summarized messages are only sent as part of the running summary
+	Summarized bool `json:"summarized,omitempty"`
+	// Stats describes how the backend generated a reply
+	Stats *GenerationStats `json:"stats,omitempty"`
+}
+
+// ConversationInfo is how the user labelled a conversation
+type ConversationInfo struct {
+	// Title names the conversation in its tab and in the history
+	Title string `json:"title,omitempty"`
+	// Pinned conversations come first in the tabs and the history
+	Pinned bool `json:"pinned,omitempty"`
+	// Archived conversations are hidden from the history
+	Archived bool `json:"archived,omitempty"`
+	// Color is one of conversationColors, empty for none
+	Color string `json:"color,omitempty"`
+	// Project names the project the conversation belongs to, empty for none
+	Project string `json:"project,omitempty"`
+}
+
+// Conversation is a chat as it is stored on disk
+type Conversation struct {
+	ConversationInfo
+	// Summary condenses the messages that no longer fit the context
+	Summary  string    `json:"summary,omitempty"`
+	Messages []Message `json:"messages"`
+	// Parameters override the model's parameters for this chat
+	Parameters *ParameterOverrides `json:"parameters,omitempty"`
+}
+
+// Prefix returns the label shown in front of the message text
+func (m Message) Prefix() string {
+	switch m.Role {
+	case RoleAssistant:
+		return "AI: "
+	case RoleTool:
+		return fmt.Sprintf("Tool %s: ", m.ToolName)
+	}
+	return "You: "
+}
+
+// String formats the message the way it is displayed in the chat
+func (m Message) String() string {
+	text := m.Prefix() + m.Content
+	for i, call := range m.ToolCalls {
+		if m.Content != "" || i > 0 {
+			text += "\n"
+		}
+		text += "[calling " + formatToolCall(call) + "]"
+	}
+	return text
+}
+
+// formatToolCall shows a tool call as name(arguments)
+func formatToolCall(call ToolCall) string {
+	args, err := json.Marshal(call.Function.Arguments)
+	if err != nil || call.Function.Arguments == nil {
+		args = []byte("{}")
+	}
+	return call.Function.Name + "(" + string(args) + ")"
+}
+
+// formatConversation joins messages into the plain text transcript format
+func formatConversation(messages []Message) string {
+	parts := make([]string, 0, len(messages))
+	for _, m := range messages {
+		parts = append(parts, m.String())
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// parseLegacyConversation converts the old "You: ...\n\nAI: ..." transcript
+// format into messages. Paragraphs that don't start with a prefix belong to
+// the previous message.
+func parseLegacyConversation(content string) []Message {
+	var messages []Message
+	for _, part := range strings.Split(content, "\n\n") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(part, "You: "):
+			messages = append(messages, Message{Role: RoleUser, Content: strings.TrimPrefix(part, "You: ")})
+		case strings.HasPrefix(part, "AI: "):
+			messages = append(messages, Message{Role: RoleAssistant, Content: strings.TrimPrefix(part, "AI: ")})
+		case len(messages) > 0:
+			messages[len(messages)-1].Content += "\n\n" + part
+		default:
+			messages = append(messages, Message{Role: RoleUser, Content: part})
+		}
+	}
+	return messages
+}
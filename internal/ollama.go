@@ -0,0 +1,239 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// defaultOllamaURL is where a local Ollama server listens unless OLLAMA_HOST says otherwise
+const defaultOllamaURL = "http://127.0.0.1:11434"
+
+// OllamaClient talks to the HTTP API of an Ollama server
+type OllamaClient struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// ChatMessage is a message in the format of the Ollama chat API
+type ChatMessage struct {
+	Role      string     `json:"role"`
+	Content   string     `json:"content"`
+	Images    [][]byte   `json:"images,omitempty"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	ToolName  string     `json:"tool_name,omitempty"`
+}
+
+// ToolCall is a request from the model to run a tool
+type ToolCall struct {
+	Function ToolCallFunction `json:"function"`
+}
+
+// ToolCallFunction names the tool to run and the arguments to run it with
+type ToolCallFunction struct {
+	Name      string         `json:"name"`
+	Arguments map[string]any `json:"arguments"`
+}
+
+// ToolDefinition describes a tool to the model
+type ToolDefinition struct {
+	Type     string             `json:"type"`
+	Function ToolDefinitionFunc `json:"function"`
+}
+
+// ToolDefinitionFunc holds the name, description and JSON schema of a tool
+type ToolDefinitionFunc struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Parameters  json.RawMessage `json:"parameters"`
+}
+
+// ChatRequest is the body of a request to /api/chat
+type ChatRequest struct {
+	Model    string           `json:"model"`
+	Messages []ChatMessage    `json:"messages"`
+	Tools    []ToolDefinition `json:"tools,omitempty"`
+	Stream   bool             `json:"stream"`
+	Options  map[string]any   `json:"options,omitempty"`
+	// Format "json" makes the model answer with JSON
+	Format string `json:"format,omitempty"`
+	// KeepAlive is a duration string or a number of seconds
+	KeepAlive any `json:"keep_alive,omitempty"`
+}
+
+// ChatResponse is a (partial, when streaming) reply from /api/chat
+type ChatResponse struct {
+	Model              string      `json:"model"`
+	Message            ChatMessage `json:"message"`
+	Done               bool        `json:"done"`
+	DoneReason         string      `json:"done_reason,omitempty"`
+	TotalDuration      int64       `json:"total_duration,omitempty"`
+	LoadDuration       int64       `json:"load_duration,omitempty"`
+	PromptEvalCount    int         `json:"prompt_eval_count,omitempty"`
+	PromptEvalDuration int64       `json:"prompt_eval_duration,omitempty"`
+	EvalCount          int         `json:"eval_count,omitempty"`
+	EvalDuration       int64       `json:"eval_duration,omitempty"`
+	Error              string      `json:"error,omitempty"`
+
+	// TimeToFirstToken is measured by ChatOnce, from sending the request
+	// until the first content arrived
+	TimeToFirstToken time.Duration `json:"-"`
+}
+
+// NewOllamaClient creates a client for the server named by OLLAMA_HOST,
+// falling back to the default local address
+func NewOllamaClient() *OllamaClient {
+	return &OllamaClient{
+		BaseURL:    ollamaBaseURL(os.Getenv("OLLAMA_HOST")),
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+// ollamaBaseURL turns an OLLAMA_HOST value, which may omit the scheme or
+// port, into a base URL
+func ollamaBaseURL(host string) string {
+	host = strings.TrimRight(strings.TrimSpace(host), "/")
+	if host == "" {
+		return defaultOllamaURL
+	}
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+	if strings.Count(host, ":") == 1 {
+		host += ":11434"
+	}
+	return host
+}
+
+// Chat sends a chat request. fn is called for every streamed chunk, or once
+// with the full reply when streaming is off.
+func (c *OllamaClient) Chat(ctx context.Context, req *ChatRequest, fn func(ChatResponse) error) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to marshal chat request: %v", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/api/chat", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create chat request: %v", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.HTTPClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to reach ollama at %s: %v", c.BaseURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return readOllamaError(resp)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 8*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var chunk ChatResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return fmt.Errorf("failed to parse chat response: %v", err)
+		}
+		if chunk.Error != "" {
+			return fmt.Errorf("ollama: %s", chunk.Error)
+		}
+		if err := fn(chunk); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read chat response: %v", err)
+	}
+	return nil
+}
+
+// ChatOnce sends a chat request and collects the streamed reply into a
+// single response
+func (c *OllamaClient) ChatOnce(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	return c.ChatStream(ctx, req, nil)
+}
+
+// ChatStream works like ChatOnce and also hands the text received so far to
+// onContent every time more of it arrives
+func (c *OllamaClient) ChatStream(ctx context.Context, req *ChatRequest, onContent func(string)) (*ChatResponse, error) {
+	var result ChatResponse
+	var content strings.Builder
+	var toolCalls []ToolCall
+	var firstToken time.Duration
+
+	start := time.Now()
+	err := c.Chat(ctx, req, func(chunk ChatResponse) error {
+		if firstToken == 0 && (chunk.Message.Content != "" || len(chunk.Message.ToolCalls) > 0) {
+			firstToken = time.Since(start)
+		}
+		content.WriteString(chunk.Message.Content)
+		if onContent != nil && chunk.Message.Content != "" {
+			onContent(content.String())
+		}
+		toolCalls = append(toolCalls, chunk.Message.ToolCalls...)
+		if chunk.Done {
+			result = chunk
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result.Message.Role = RoleAssistant
+	result.Message.Content = content.String()
+	result.Message.ToolCalls = toolCalls
+	result.TimeToFirstToken = firstToken
+	return &result, nil
+}
+
+// Version asks the server for its version, which also tells whether it can
+// be reached at all
+func (c *OllamaClient) Version(ctx context.Context) (string, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+"/api/version", nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %v", err)
+	}
+	resp, err := c.HTTPClient.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to reach ollama at %s: %v", c.BaseURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", readOllamaError(resp)
+	}
+	var body struct {
+		Version string `json:"version"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode version: %v", err)
+	}
+	return body.Version, nil
+}
+
+// readOllamaError extracts the error message from a failed API response
+func readOllamaError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	var body struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(data, &body); err == nil && body.Error != "" {
+		return fmt.Errorf("ollama: %s", body.Error)
+	}
+	return fmt.Errorf("ollama: unexpected status %s", resp.Status)
+}
@@ -0,0 +1,132 @@
+package internal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AdvancedOptions are the less common Ollama options. Anything left unset
+// uses the default of the model.
+type AdvancedOptions struct {
+	// Values holds numeric options by their Ollama name, e.g. "repeat_penalty"
+	Values map[string]float64 `json:"values,omitempty"`
+	// Stop sequences end the reply when the model produces them
+	Stop []string `json:"stop,omitempty"`
+	// KeepAlive is how long the model stays loaded, e.g. "10m" or "-1"
+	KeepAlive string `json:"keepAlive,omitempty"`
+	// JSONFormat makes the model answer with valid JSON
+	JSONFormat bool `json:"jsonFormat,omitempty"`
+}
+
+// optionSpec describes a numeric Ollama option
+type optionSpec struct {
+	Key     string
+	Name    string
+	Hint    string
+	Min     float64
+	Max     float64
+	Integer bool
+}
+
+// ollamaOptionSpecs are the numeric options offered in the settings, with
+// the range the Ollama backend accepts
+var ollamaOptionSpecs = []optionSpec{
+	{"seed", "Seed", "same seed and prompt give the same answer", 0, 2147483647, true},
+	{"repeat_penalty", "Repeat Penalty", "e.g. 1.1, higher repeats less", 0, 2, false},
+	{"repeat_last_n", "Repeat Last N", "tokens checked for repetition, -1 = context", -1, 32768, true},
+	{"presence_penalty", "Presence Penalty", "-2 to 2", -2, 2, false},
+	{"frequency_penalty", "Frequency Penalty", "-2 to 2", -2, 2, false},
+	{"min_p", "Min P", "0 to 1, e.g. 0.05", 0, 1, false},
+	{"mirostat", "Mirostat", "", 0, 2, true},
+	{"mirostat_tau", "Mirostat Tau", "e.g. 5.0, lower is more focused", 0, 10, false},
+	{"mirostat_eta", "Mirostat Eta", "e.g. 0.1, learning rate", 0, 1, false},
+	{"num_gpu", "GPU Layers", "layers offloaded to the GPU", -1, 1024, true},
+	{"num_thread", "CPU Threads", "threads used for generation", 1, 1024, true},
+}
+
+// mirostatModes are the choices of the mirostat option
+var mirostatModes = []string{"Off", "Mirostat", "Mirostat 2.0"}
+
+// findOptionSpec looks up a numeric option by its Ollama name
+func findOptionSpec(key string) (optionSpec, bool) {
+	for _, spec := range ollamaOptionSpecs {
+		if spec.Key == key {
+			return spec, true
+		}
+	}
+	return optionSpec{}, false
+}
+
+// parseOptionValue checks a value typed for a numeric option. An empty text
+// means the model default.
+func parseOptionValue(spec optionSpec, text string) (float64, bool, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return 0, false, nil
+	}
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("%s must be a number", spec.Name)
+	}
+	if spec.Integer && value != float64(int64(value)) {
+		return 0, false, fmt.Errorf("%s must be a whole number", spec.Name)
+	}
+	if value < spec.Min || value > spec.Max {
+		return 0, false, fmt.Errorf("%s must be between %g and %g", spec.Name, spec.Min, spec.Max)
+	}
+	return value, true, nil
+}
+
+// parseKeepAlive checks a keep alive value. Ollama takes a number of
+// seconds (negative keeps the model loaded) or a duration like "5m".
+func parseKeepAlive(text string) (any, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, nil
+	}
+	if seconds, err := strconv.Atoi(text); err == nil {
+		return seconds, nil
+	}
+	if _, err := time.ParseDuration(text); err != nil {
+		return nil, fmt.Errorf("keep alive must be seconds or a duration like 5m or 1h")
+	}
+	return text, nil
+}
+
+// applyTo adds the options that are set to the options of a request
+func (o AdvancedOptions) applyTo(options map[string]any) {
+	for _, spec := range ollamaOptionSpecs {
+		value, ok := o.Values[spec.Key]
+		if !ok {
+			continue
+		}
+		if spec.Integer {
+			options[spec.Key] = int(value)
+		} else {
+			options[spec.Key] = value
+		}
+	}
+	if len(o.Stop) > 0 {
+		options["stop"] = o.Stop
+	}
+}
+
+// NewChatRequest builds a streaming chat request with all generation
+// settings for a model and, optionally, a chat
+func (d SettingsData) NewChatRequest(modelName string, chat *ParameterOverrides, messages []ChatMessage) *ChatRequest {
+	req := &ChatRequest{
+		Model:    modelName,
+		Messages: messages,
+		Stream:   true,
+		Options:  d.OptionsFor(modelName, chat),
+	}
+	if keepAlive, err := parseKeepAlive(d.Advanced.KeepAlive); err == nil && keepAlive != nil {
+		req.KeepAlive = keepAlive
+	}
+	if d.Advanced.JSONFormat {
+		req.Format = "json"
+	}
+	return req
+}
@@ -0,0 +1,126 @@
+package internal
+
+// Where the effective value of a generation parameter comes from
+const (
+	SourceGlobal = "Global"
+	SourceModel  = "Model"
+	SourceChat   = "Chat"
+)
+
+// GenerationParameters are the values that shape how a model answers
+type GenerationParameters struct {
+	Temperature   float64
+	MaxTokens     float64
+	TopP          float64
+	TopK          float64
+	ContextLength float64
+}
+
+// Options converts the parameters to the options of a chat request
+func (p GenerationParameters) Options() map[string]any {
+	return map[string]any{
+		"temperature": p.Temperature,
+		"top_p":       p.TopP,
+		"top_k":       int(p.TopK),
+		"num_ctx":     int(p.ContextLength),
+		"num_predict": int(p.MaxTokens),
+	}
+}
+
+// ParameterOverrides replace some of the global parameters for a model or
+// a chat. Unset fields keep the value of the layer below.
+type ParameterOverrides struct {
+	Temperature   *float64 `json:"temperature,omitempty"`
+	MaxTokens     *float64 `json:"maxTokens,omitempty"`
+	TopP          *float64 `json:"topP,omitempty"`
+	TopK          *float64 `json:"topK,omitempty"`
+	ContextLength *float64 `json:"contextLength,omitempty"`
+}
+
+// parameterSpec describes a generation parameter for the editors
+type parameterSpec struct {
+	Name     string
+	Min, Max float64
+	Step     float64
+	Format   string
+	value    func(*GenerationParameters) *float64
+	override func(*ParameterOverrides) **float64
+}
+
+// parameterSpecs lists the parameters that can be overridden, with the same
+// ranges as the global sliders
+var parameterSpecs = []parameterSpec{
+	{"Temperature", 0, 2, 0.05, "%.2f",
+		func(p *GenerationParameters) *float64 { return &p.Temperature },
+		func(o *ParameterOverrides) **float64 { return &o.Temperature }},
+	{"Max Tokens", 100, 4096, 1, "%.0f",
+		func(p *GenerationParameters) *float64 { return &p.MaxTokens },
+		func(o *ParameterOverrides) **float64 { return &o.MaxTokens }},
+	{"Top P", 0, 1, 0.01, "%.2f",
+		func(p *GenerationParameters) *float64 { return &p.TopP },
+		func(o *ParameterOverrides) **float64 { return &o.TopP }},
+	{"Top K", 1, 100, 1, "%.0f",
+		func(p *GenerationParameters) *float64 { return &p.TopK },
+		func(o *ParameterOverrides) **float64 { return &o.TopK }},
+	{"Context Length", 512, 8192, 1, "%.0f",
+		func(p *GenerationParameters) *float64 { return &p.ContextLength },
+		func(o *ParameterOverrides) **float64 { return &o.ContextLength }},
+}
+
+// IsEmpty reports whether nothing is overridden
+func (o ParameterOverrides) IsEmpty() bool {
+	for _, spec := range parameterSpecs {
+		if *spec.override(&o) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// applyTo replaces the overridden parameters and notes where they came from
+func (o ParameterOverrides) applyTo(p *GenerationParameters, sources map[string]string, source string) {
+	for _, spec := range parameterSpecs {
+		if value := *spec.override(&o); value != nil {
+			*spec.value(p) = *value
+			sources[spec.Name] = source
+		}
+	}
+}
+
+// Parameters returns the global generation parameters
+func (d SettingsData) Parameters() GenerationParameters {
+	return GenerationParameters{
+		Temperature:   d.Temperature,
+		MaxTokens:     d.MaxTokens,
+		TopP:          d.TopP,
+		TopK:          d.TopK,
+		ContextLength: d.ContextLength,
+	}
+}
+
+// ResolveParameters layers the profile of the model and the overrides of the
+// chat over the global parameters. The sources map each parameter name to the
+// layer its value comes from.
+func (d SettingsData) ResolveParameters(modelName string, chat *ParameterOverrides) (GenerationParameters, map[string]string) {
+	params := d.Parameters()
+	sources := map[string]string{}
+	for _, spec := range parameterSpecs {
+		sources[spec.Name] = SourceGlobal
+	}
+
+	if profile, ok := d.ModelProfiles[modelName]; ok {
+		profile.applyTo(&params, sources, SourceModel)
+	}
+	if chat != nil {
+		chat.applyTo(&params, sources, SourceChat)
+	}
+	return params, sources
+}
+
+// OptionsFor returns the request options for a model and, optionally, a chat
+func (d SettingsData) OptionsFor(modelName string, chat *ParameterOverrides) map[string]any {
+	params, _ := d.ResolveParameters(modelName, chat)
+	options := params.Options()
+	d.Advanced.applyTo(options)
+	return options
+}
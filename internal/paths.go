@@ -0,0 +1,409 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// appName is the directory name used below the user's config, data and cache
+// directories
+const appName = "neuratalk"
+
+// Environment variables overriding where NeuraTalk keeps its files
+const (
+	// HomeEnv puts config, data and cache below a single directory
+	HomeEnv = "NEURATALK_HOME"
+	// PortableEnv keeps everything next to the executable when set to 1
+	PortableEnv = "NEURATALK_PORTABLE"
+)
+
+// portableMarker is a file next to the executable turning on portable mode
+const portableMarker = "portable"
+
+// AppPaths are the directories NeuraTalk reads and writes
+type AppPaths struct {
+	Config string
+	Data   string
+	Cache  string
+}
+
+// paths is resolved by InitPaths; until then files go to the XDG defaults
+var paths = defaultPaths()
+
+// ConfigDir holds the settings
+func ConfigDir() string {
+	return paths.Config
+}
+
+// DataDir holds chats, history, images and votes
+func DataDir() string {
+	return paths.Data
+}
+
+// CacheDir holds files that can be recreated at any time
+func CacheDir() string {
+	return paths.Cache
+}
+
+// homePaths lays out the directories below a single root
+func homePaths(root string) AppPaths {
+	return AppPaths{
+		Config: filepath.Join(root, "config"),
+		Data:   filepath.Join(root, "data"),
+		Cache:  filepath.Join(root, "cache"),
+	}
+}
+
+// defaultPaths follows the XDG base directory spec on Linux and the
+// platform conventions elsewhere
+func defaultPaths() AppPaths {
+	home, _ := os.UserHomeDir()
+
+	config, err := os.UserConfigDir()
+	if err != nil {
+		config = filepath.Join(home, ".config")
+	}
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		cache = filepath.Join(home, ".cache")
+	}
+
+	// Go has no data directory lookup, on Linux it is XDG_DATA_HOME
+	data := config
+	switch runtime.GOOS {
+	case "darwin", "windows", "ios", "android", "plan9":
+	default:
+		data = os.Getenv("XDG_DATA_HOME")
+		if data == "" || !filepath.IsAbs(data) {
+			data = filepath.Join(home, ".local", "share")
+		}
+	}
+
+	return AppPaths{
+		Config: filepath.Join(config, appName),
+		Data:   filepath.Join(data, appName),
+		Cache:  filepath.Join(cache, appName),
+	}
+}
+
+// isPortable reports whether portable mode was asked for by the
+// environment or a marker file next to the executable
+func isPortable(exeDir string) bool {
+	if os.Getenv(PortableEnv) == "1" {
+		return true
+	}
+	_, err := os.Stat(filepath.Join(exeDir, portableMarker))
+	return err == nil
+}
+
+// resolvePaths picks the directories. An explicit home wins over the
+// environment, then portable mode, then the platform defaults.
+func resolvePaths(home string, portable bool) (AppPaths, error) {
+	if home == "" {
+		home = os.Getenv(HomeEnv)
+	}
+	if home != "" {
+		abs, err := filepath.Abs(home)
+		if err != nil {
+			return AppPaths{}, fmt.Errorf("failed to resolve home directory: %v", err)
+		}
+		return homePaths(abs), nil
+	}
+
+	exeDir, err := executableDir()
+	if portable || (err == nil && isPortable(exeDir)) {
+		if err != nil {
+			return AppPaths{}, fmt.Errorf("failed to locate executable for portable mode: %v", err)
+		}
+		return homePaths(exeDir), nil
+	}
+	return defaultPaths(), nil
+}
+
+// InitPaths resolves where files are kept, creates the directories and
+// moves data left by older versions
+func InitPaths(home string, portable bool) (AppPaths, error) {
+	resolved, err := resolvePaths(home, portable)
+	if err != nil {
+		return paths, err
+	}
+	paths = resolved
+
+	for _, dir := range []string{paths.Config, paths.Data, paths.Cache} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return paths, fmt.Errorf("failed to create %s: %v", dir, err)
+		}
+	}
+
+	migrateLegacyData(legacyRoots()...)
+	return paths, nil
+}
+
+// legacyRoots lists the directories older versions may have kept their
+// data in: they wrote relative to the working directory, which was the
+// directory of the executable when started from a checkout
+func legacyRoots() []string {
+	var roots []string
+	add := func(dir string, err error) {
+		if err != nil {
+			return
+		}
+		for _, root := range roots {
+			if root == dir || sameFile(root, dir) {
+				return
+			}
+		}
+		roots = append(roots, dir)
+	}
+	add(executableDir())
+	add(os.Getwd())
+	return roots
+}
+
+// executableDir returns the directory of the running executable
+func executableDir() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	return filepath.Dir(exe), nil
+}
+
+// legacyMigrationMarker is written once the data of older versions was
+// found, so it is only moved on the first start that finds it
+func legacyMigrationMarker() string {
+	return filepath.Join(DataDir(), ".legacy-migrated")
+}
+
+// isLegacyFile reports whether a directory entry is a regular file with
+// one of the extensions
+func isLegacyFile(entry fs.DirEntry, extensions ...string) bool {
+	if !entry.Type().IsRegular() {
+		return false
+	}
+	ext := strings.ToLower(filepath.Ext(entry.Name()))
+	for _, e := range extensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// migrateLegacyData moves the files older versions kept in the tmp and
+// conversations directories below the roots, and copies
+// config/settings.json. Only files these versions wrote are touched,
+// entries that already exist at the new location are left alone, and once
+// data was found it doesn't run again.
+func migrateLegacyData(roots ...string) {
+	if _, err := os.Stat(legacyMigrationMarker()); err == nil {
+		return
+	}
+	found := false
+	for _, root := range roots {
+		if migrateLegacyRoot(root) {
+			found = true
+		}
+	}
+	if !found {
+		return
+	}
+	if err := os.WriteFile(legacyMigrationMarker(), nil, 0600); err != nil {
+		slog.Error("Failed to record the migration of legacy data", "err", err)
+	}
+}
+
+// migrateLegacyRoot migrates the data below one root and reports whether
+// the root held data of an older version. Migrated files are made readable
+// by the user only, like the files the store writes.
+func migrateLegacyRoot(root string) bool {
+	found := false
+	move := func(src, dst string) {
+		if err := moveEntry(src, dst); err != nil {
+			slog.Error("Failed to migrate legacy data", "path", src, "err", err)
+			return
+		}
+		if exists, _ := os.Stat(src); exists != nil {
+			return
+		}
+		found = true
+		if err := os.Chmod(dst, 0600); err != nil {
+			slog.Error("Failed to restrict migrated data", "path", dst, "err", err)
+		}
+		for dir := filepath.Dir(dst); strings.HasPrefix(dir, DataDir()+string(filepath.Separator)); dir = filepath.Dir(dir) {
+			if err := os.Chmod(dir, 0700); err != nil {
+				slog.Error("Failed to restrict migrated data", "path", dir, "err", err)
+			}
+		}
+	}
+
+	// The settings may be tracked in a checkout, so they are only copied
+	legacySettings := filepath.Join(root, "config", "settings.json")
+	if _, err := os.Stat(legacySettings); err == nil {
+		found = true
+		if _, err := os.Stat(settingsFilePath()); os.IsNotExist(err) {
+			err := os.MkdirAll(filepath.Dir(settingsFilePath()), 0700)
+			if err == nil {
+				err = copyFile(legacySettings, settingsFilePath())
+			}
+			if err == nil {
+				err = os.Chmod(settingsFilePath(), 0600)
+			}
+			if err != nil {
+				slog.Error("Failed to migrate legacy data", "path", legacySettings, "err", err)
+			}
+		}
+	}
+
+	legacyTmp := filepath.Join(root, "tmp")
+	if entries, err := os.ReadDir(legacyTmp); err == nil {
+		for _, entry := range entries {
+			src := filepath.Join(legacyTmp, entry.Name())
+			switch {
+			case entry.Name() == "comparisons.jsonl" && entry.Type().IsRegular():
+				move(src, comparisonsFilePath())
+			case entry.Name() == "arena.jsonl" && entry.Type().IsRegular():
+				move(src, arenaFilePath())
+			case isLegacyFile(entry, ".txt", ".json"):
+				move(src, filepath.Join(chatsDirectory(), entry.Name()))
+			}
+		}
+	}
+
+	legacyImages := filepath.Join(legacyTmp, "images")
+	if entries, err := os.ReadDir(legacyImages); err == nil {
+		for _, entry := range entries {
+			if isLegacyFile(entry, imageExtensions...) {
+				move(filepath.Join(legacyImages, entry.Name()), filepath.Join(imagesDirectory(), entry.Name()))
+			}
+		}
+	}
+
+	legacyConversations := filepath.Join(root, "conversations")
+	if models, err := os.ReadDir(legacyConversations); err == nil {
+		for _, model := range models {
+			if !model.IsDir() {
+				continue
+			}
+			modelDir := filepath.Join(legacyConversations, model.Name())
+			entries, err := os.ReadDir(modelDir)
+			if err != nil {
+				continue
+			}
+			for _, entry := range entries {
+				if isLegacyFile(entry, ".txt", ".json") {
+					move(filepath.Join(modelDir, entry.Name()), filepath.Join(conversationsDirectory(), model.Name(), entry.Name()))
+				}
+			}
+			os.Remove(modelDir)
+		}
+	}
+
+	// Only removes the old directories once they are empty
+	for _, dir := range []string{legacyImages, legacyTmp, legacyConversations} {
+		os.Remove(dir)
+	}
+	return found
+}
+
+// moveEntry moves a file or directory unless the destination exists.
+// Directories are merged entry by entry; moves across file systems copy.
+func moveEntry(src, dst string) error {
+	srcInfo, err := os.Stat(src)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if sameFile(src, dst) {
+		return nil
+	}
+
+	if dstInfo, err := os.Stat(dst); err == nil {
+		if !srcInfo.IsDir() || !dstInfo.IsDir() {
+			return nil
+		}
+		entries, err := os.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := moveEntry(filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name())); err != nil {
+				return err
+			}
+		}
+		os.Remove(src)
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	if err := copyTree(src, dst); err != nil {
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+// sameFile reports whether both paths name the same existing file
+func sameFile(a, b string) bool {
+	aInfo, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bInfo, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(aInfo, bInfo)
+}
+
+// copyTree copies a file or a directory with everything in it
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		return copyFile(path, target)
+	})
+}
+
+// copyFile copies a single file
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,161 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// usePaths points the store at a temporary directory for one test
+func usePaths(t *testing.T) AppPaths {
+	t.Helper()
+	previous := paths
+	paths = homePaths(t.TempDir())
+	t.Cleanup(func() {
+		paths = previous
+	})
+	return paths
+}
+
+func writeTestFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestMigrateLegacyData(t *testing.T) {
+	usePaths(t)
+	root := t.TempDir()
+
+	legacy := map[string]string{
+		"tmp/llama3.txt":              filepath.Join(chatsDirectory(), "llama3.txt"),
+		"tmp/mistral.json":            filepath.Join(chatsDirectory(), "mistral.json"),
+		"tmp/comparisons.jsonl":       comparisonsFilePath(),
+		"tmp/arena.jsonl":             arenaFilePath(),
+		"tmp/images/abc.png":          filepath.Join(imagesDirectory(), "abc.png"),
+		"conversations/llama3/1.txt":  filepath.Join(conversationsDirectory(), "llama3", "1.txt"),
+		"conversations/llama3/2.json": filepath.Join(conversationsDirectory(), "llama3", "2.json"),
+	}
+	unrelated := []string{
+		"tmp/notes.md",
+		"tmp/project/main.go",
+		"tmp/images/report.pdf",
+		"conversations/llama3/readme.md",
+		"conversations/summary.txt",
+	}
+	for src := range legacy {
+		writeTestFile(t, filepath.Join(root, src), src)
+	}
+	for _, src := range unrelated {
+		writeTestFile(t, filepath.Join(root, src), src)
+	}
+	writeTestFile(t, filepath.Join(root, "config", "settings.json"), "{}")
+
+	migrateLegacyData(root)
+
+	for src, dst := range legacy {
+		if exists(filepath.Join(root, src)) {
+			t.Errorf("%s was not moved", src)
+		}
+		if !exists(dst) {
+			t.Errorf("%s was not moved to %s", src, dst)
+		}
+	}
+	for _, src := range unrelated {
+		if !exists(filepath.Join(root, src)) {
+			t.Errorf("unrelated %s was moved", src)
+		}
+	}
+	if !exists(filepath.Join(root, "config", "settings.json")) {
+		t.Error("the legacy settings were removed instead of copied")
+	}
+	if !exists(settingsFilePath()) {
+		t.Error("the legacy settings were not copied")
+	}
+
+	// Later starts leave the directory alone
+	writeTestFile(t, filepath.Join(root, "tmp", "gemma.txt"), "new")
+	migrateLegacyData(root)
+	if !exists(filepath.Join(root, "tmp", "gemma.txt")) {
+		t.Error("files were moved again after the first start")
+	}
+}
+
+func TestMigrateLegacyDataKeepsExistingFiles(t *testing.T) {
+	usePaths(t)
+	root := t.TempDir()
+
+	writeTestFile(t, filepath.Join(root, "tmp", "llama3.txt"), "old")
+	writeTestFile(t, filepath.Join(chatsDirectory(), "llama3.txt"), "current")
+
+	migrateLegacyData(root)
+
+	data, err := os.ReadFile(filepath.Join(chatsDirectory(), "llama3.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "current" {
+		t.Errorf("existing chat was overwritten with %q", data)
+	}
+}
+
+func TestMigrateLegacyDataFromEveryRoot(t *testing.T) {
+	usePaths(t)
+	exeDir := t.TempDir()
+	workDir := t.TempDir()
+
+	// Nothing to migrate leaves later starts free to look again
+	migrateLegacyData(exeDir, workDir)
+	if exists(legacyMigrationMarker()) {
+		t.Fatal("migration was recorded without finding any data")
+	}
+
+	writeTestFile(t, filepath.Join(workDir, "tmp", "llama3.txt"), "chat")
+	writeTestFile(t, filepath.Join(workDir, "conversations", "llama3", "1.json"), "{}")
+	migrateLegacyData(exeDir, workDir)
+
+	if !exists(legacyMigrationMarker()) {
+		t.Error("migration was not recorded")
+	}
+	for path, want := range map[string]os.FileMode{
+		filepath.Join(chatsDirectory(), "llama3.txt"):               0600,
+		filepath.Join(conversationsDirectory(), "llama3", "1.json"): 0600,
+		chatsDirectory(): 0700,
+		filepath.Join(conversationsDirectory(), "llama3"): 0700,
+		conversationsDirectory():                          0700,
+	} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Errorf("%s was not migrated: %v", path, err)
+			continue
+		}
+		if mode := info.Mode().Perm(); mode != want {
+			t.Errorf("%s has mode %o, want %o", path, mode, want)
+		}
+	}
+}
+
+func TestLegacyRootsIncludeWorkingDirectory(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	roots := legacyRoots()
+	found := 0
+	for _, root := range roots {
+		if sameFile(root, dir) {
+			found++
+		}
+	}
+	if found != 1 {
+		t.Errorf("roots %v list the working directory %d times, want once", roots, found)
+	}
+}
@@ -0,0 +1,172 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxKnowledgeFileSize keeps a single knowledge file from filling the context
+const maxKnowledgeFileSize = 256 * 1024
+
+// Project groups conversations that share a system prompt, a model and
+// knowledge files
+type Project struct {
+	Name string `json:"name"`
+	// Model is the model new chats of the project start with
+	Model string `json:"model,omitempty"`
+	// SystemPrompt is sent at the start of every chat of the project
+	SystemPrompt string `json:"systemPrompt,omitempty"`
+	// Files are text files whose content is sent along with the system prompt
+	Files []string `json:"files,omitempty"`
+}
+
+// projectsFilePath is where the projects are saved
+func projectsFilePath() string {
+	return filepath.Join(DataDir(), "projects.json")
+}
+
+// loadProjects reads the saved projects
+func loadProjects() ([]Project, error) {
+	data, err := readStoreFile(projectsFilePath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read projects: %v", err)
+	}
+	var projects []Project
+	if err := json.Unmarshal(data, &projects); err != nil {
+		return nil, fmt.Errorf("failed to parse projects: %v", err)
+	}
+	return projects, nil
+}
+
+// saveProjects replaces the saved projects
+func saveProjects(projects []Project) error {
+	if projects == nil {
+		projects = []Project{}
+	}
+	data, err := json.MarshalIndent(projects, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal projects: %v", err)
+	}
+
+	// System prompts can be as private as the chats, so projects are
+	// encrypted with the history
+	filePath := projectsFilePath()
+	if err := os.MkdirAll(filepath.Dir(filePath), 0700); err != nil {
+		return fmt.Errorf("failed to create data directory: %v", err)
+	}
+	if err := writeStoreFile(filePath, data); err != nil {
+		return fmt.Errorf("failed to write projects file: %v", err)
+	}
+	return nil
+}
+
+// findProject returns the project with the given name
+func findProject(projects []Project, name string) (Project, bool) {
+	for _, p := range projects {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Project{}, false
+}
+
+// readKnowledgeFile reads a knowledge file, refusing files that are too big
+// or not text
+func readKnowledgeFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.Size() > maxKnowledgeFileSize {
+		return "", fmt.Errorf("%s is larger than %d KB", filepath.Base(path), maxKnowledgeFileSize/1024)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if !utf8.Valid(data) {
+		return "", fmt.Errorf("%s is not a text file", filepath.Base(path))
+	}
+	return string(data), nil
+}
+
+// contextMessage builds the system message carrying the system prompt and
+// the knowledge files of the project. It reports false when the project
+// has neither.
+func (p Project) contextMessage() (Message, bool, error) {
+	var content strings.Builder
+	content.WriteString(strings.TrimSpace(p.SystemPrompt))
+
+	if len(p.Files) > 0 {
+		if content.Len() > 0 {
+			content.WriteString("\n\n")
+		}
+		content.WriteString("Knowledge files of the project:")
+		for _, path := range p.Files {
+			text, err := readKnowledgeFile(path)
+			if err != nil {
+				return Message{}, false, fmt.Errorf("failed to read knowledge file: %v", err)
+			}
+			fmt.Fprintf(&content, "\n\n### %s\n```\n%s\n```", filepath.Base(path), strings.TrimRight(text, "\n"))
+		}
+	}
+
+	if content.Len() == 0 {
+		return Message{}, false, nil
+	}
+	return Message{Role: RoleSystem, Content: content.String()}, true, nil
+}
+
+// setStoredProject moves the saved chat of a model into a project, or out
+// of any project with an empty name
+func setStoredProject(modelName string, project string) error {
+	conversation, err := loadConversation(modelName)
+	if err != nil {
+		return err
+	}
+	conversation.Project = project
+	return saveConversation(modelName, conversation)
+}
+
+// projectContext returns the messages the project of the chat adds in front
+// of the conversation
+func (io *InputOutput) projectContext() ([]Message, error) {
+	if io.Info.Project == "" {
+		return nil, nil
+	}
+	projects, err := loadProjects()
+	if err != nil {
+		return nil, err
+	}
+	project, ok := findProject(projects, io.Info.Project)
+	if !ok {
+		return nil, nil
+	}
+	message, ok, err := project.contextMessage()
+	if err != nil || !ok {
+		return nil, err
+	}
+	return []Message{message}, nil
+}
+
+// StartProjectChat starts a new conversation of the chat inside a project.
+// A running conversation is kept in the history first.
+func (io *InputOutput) StartProjectChat(project string) {
+	if io.Info.Project == project && len(io.Messages) == 0 {
+		return
+	}
+	if len(io.Messages) > 0 && io.SelectedModel != "" {
+		if err := saveConversationToHistory(io.SelectedModel, io.conversation()); err != nil {
+			ShowError(fmt.Errorf("Failed to save conversation history: %v", err), io.ParentWindow)
+			return
+		}
+	}
+	io.resetConversation(ConversationInfo{Project: project})
+}
@@ -0,0 +1,533 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RetentionPolicy limits how much conversation history is kept for every
+// model. Zero means no limit.
+type RetentionPolicy struct {
+	// MaxAgeDays removes snapshots older than this many days
+	MaxAgeDays int `json:"maxAgeDays,omitempty"`
+	// MaxCount keeps only the newest snapshots
+	MaxCount int `json:"maxCount,omitempty"`
+	// MaxSizeMB removes the oldest snapshots once they take more space
+	MaxSizeMB int `json:"maxSizeMB,omitempty"`
+}
+
+// retentionLimit describes a limit of the retention policy for the settings
+type retentionLimit struct {
+	Name  string
+	Unit  string
+	Max   int
+	value func(*RetentionPolicy) *int
+}
+
+// retentionLimits are the limits of the retention policy, in the order the
+// settings show them
+var retentionLimits = []retentionLimit{
+	{"Maximum age", "days", 3650, func(p *RetentionPolicy) *int { return &p.MaxAgeDays }},
+	{"Maximum count", "snapshots", 100000, func(p *RetentionPolicy) *int { return &p.MaxCount }},
+	{"Maximum size", "MB", 100000, func(p *RetentionPolicy) *int { return &p.MaxSizeMB }},
+}
+
+// janitorInterval is how often the janitor applies the retention policy
+const janitorInterval = time.Hour
+
+// orphanImageAge keeps images that no conversation uses yet, such as
+// attachments of a message still being written
+const orphanImageAge = 24 * time.Hour
+
+// historyFiles serializes changes to the history between saving chats and
+// cleaning up
+var historyFiles sync.Mutex
+
+// retentionReport tells what a cleanup removed
+type retentionReport struct {
+	Removed int
+	Freed   int64
+}
+
+func (r retentionReport) String() string {
+	if r.Removed == 0 {
+		return "Nothing to clean up"
+	}
+	return fmt.Sprintf("Removed %d files, freeing %.1f MB", r.Removed, float64(r.Freed)/(1024*1024))
+}
+
+// historySnapshot is a conversation saved to the history
+type historySnapshot struct {
+	storedConversation
+	Time time.Time
+	Size int64
+}
+
+// snapshotTime reads the time a snapshot was saved from its name, falling
+// back to the modification time. Encrypting the history rewrites every
+// file, so the name is more reliable.
+func snapshotTime(path string, info os.FileInfo) time.Time {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if len(name) >= len("20060102_150405") {
+		if t, err := time.ParseInLocation("20060102_150405", name[len(name)-len("20060102_150405"):], time.Local); err == nil {
+			return t
+		}
+	}
+	return info.ModTime()
+}
+
+// loadSnapshots reads the history grouped by model, oldest first
+func loadSnapshots() map[string][]historySnapshot {
+	snapshots := map[string][]historySnapshot{}
+	for _, stored := range readStoredConversations(conversationsDirectory()) {
+		info, err := os.Stat(stored.Path)
+		if err != nil {
+			continue
+		}
+		snapshots[stored.Model] = append(snapshots[stored.Model], historySnapshot{
+			storedConversation: stored,
+			Time:               snapshotTime(stored.Path, info),
+			Size:               info.Size(),
+		})
+	}
+	for _, list := range snapshots {
+		sort.SliceStable(list, func(i, j int) bool {
+			return list[i].Time.Before(list[j].Time)
+		})
+	}
+	return snapshots
+}
+
+// messagePrefixHashes hashes every prefix of the messages, the last hash
+// covering all of them. Flags that change later, such as pins, are left out.
+func messagePrefixHashes(messages []Message) [][32]byte {
+	hashes := make([][32]byte, len(messages))
+	var previous [32]byte
+	for i, m := range messages {
+		h := sha256.New()
+		h.Write(previous[:])
+		fmt.Fprintf(h, "%s\x00%s\x00%d\x00%s\x00%s", m.Role, m.ToolName, m.Time.UnixNano(), strings.Join(m.Images, ","), m.Content)
+		h.Sum(previous[:0])
+		hashes[i] = previous
+	}
+	return hashes
+}
+
+// isPrefixOf reports whether the messages of a start a later conversation b
+func isPrefixOf(a []Message, b []Message) bool {
+	if len(a) > len(b) {
+		return false
+	}
+	if len(a) == 0 {
+		return true
+	}
+	return messagePrefixHashes(a)[len(a)-1] == messagePrefixHashes(b)[len(a)-1]
+}
+
+// keptSnapshot reports whether a snapshot is kept whatever the policy says:
+// the snapshots of pinned chats and the newest snapshot of a model.
+// snapshots are sorted oldest first.
+func keptSnapshot(snapshots []historySnapshot, i int) bool {
+	return i == len(snapshots)-1 || snapshots[i].Conversation.Pinned
+}
+
+// redundantSnapshots finds the snapshots of a model that another one
+// contains in full: the snapshots saved after every reply of a chat only
+// differ by the newest messages. Of identical snapshots the newest is kept.
+// snapshots are sorted oldest first.
+func redundantSnapshots(snapshots []historySnapshot) []historySnapshot {
+	kept := map[string]bool{}
+	for i := range snapshots {
+		if keptSnapshot(snapshots, i) {
+			kept[snapshots[i].Path] = true
+		}
+	}
+
+	order := make([]historySnapshot, len(snapshots))
+	copy(order, snapshots)
+	sort.SliceStable(order, func(i, j int) bool {
+		if len(order[i].Conversation.Messages) != len(order[j].Conversation.Messages) {
+			return len(order[i].Conversation.Messages) > len(order[j].Conversation.Messages)
+		}
+		return order[i].Time.After(order[j].Time)
+	})
+
+	var redundant []historySnapshot
+	covered := map[[32]byte]bool{}
+	for _, snapshot := range order {
+		messages := snapshot.Conversation.Messages
+		if len(messages) == 0 {
+			if !kept[snapshot.Path] {
+				redundant = append(redundant, snapshot)
+			}
+			continue
+		}
+		hashes := messagePrefixHashes(messages)
+		if covered[hashes[len(hashes)-1]] && !kept[snapshot.Path] {
+			redundant = append(redundant, snapshot)
+			continue
+		}
+		for _, h := range hashes {
+			covered[h] = true
+		}
+	}
+	return redundant
+}
+
+// expiredSnapshots finds the snapshots of a model the policy doesn't keep,
+// oldest first. Kept snapshots count towards the limits but never expire.
+// snapshots are sorted oldest first.
+func expiredSnapshots(snapshots []historySnapshot, policy RetentionPolicy, now time.Time) []historySnapshot {
+	expired := make([]bool, len(snapshots))
+	remaining := len(snapshots)
+	var size int64
+	for _, snapshot := range snapshots {
+		size += snapshot.Size
+	}
+	expire := func(i int) {
+		expired[i] = true
+		remaining--
+		size -= snapshots[i].Size
+	}
+
+	if policy.MaxAgeDays > 0 {
+		cutoff := now.AddDate(0, 0, -policy.MaxAgeDays)
+		for i, snapshot := range snapshots {
+			if snapshot.Time.Before(cutoff) && !keptSnapshot(snapshots, i) {
+				expire(i)
+			}
+		}
+	}
+	if policy.MaxCount > 0 {
+		for i := range snapshots {
+			if remaining <= policy.MaxCount {
+				break
+			}
+			if !expired[i] && !keptSnapshot(snapshots, i) {
+				expire(i)
+			}
+		}
+	}
+	if policy.MaxSizeMB > 0 {
+		limit := int64(policy.MaxSizeMB) * 1024 * 1024
+		for i := range snapshots {
+			if size <= limit {
+				break
+			}
+			if !expired[i] && !keptSnapshot(snapshots, i) {
+				expire(i)
+			}
+		}
+	}
+
+	var result []historySnapshot
+	for i, snapshot := range snapshots {
+		if expired[i] {
+			result = append(result, snapshot)
+		}
+	}
+	return result
+}
+
+// secureRemove overwrites a file with zeros before removing it. Copy-on-write
+// file systems and SSDs may still keep the old blocks.
+func secureRemove(path string) (int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	if file, err := os.OpenFile(path, os.O_WRONLY, 0); err == nil {
+		zeros := make([]byte, 32*1024)
+		for left := info.Size(); left > 0; {
+			n := min(left, int64(len(zeros)))
+			if _, err := file.Write(zeros[:n]); err != nil {
+				break
+			}
+			left -= n
+		}
+		file.Sync()
+		file.Close()
+	}
+	if err := os.Remove(path); err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// removeSnapshots removes snapshots and adds them to the report
+func removeSnapshots(snapshots []historySnapshot, report *retentionReport) error {
+	var failed error
+	for _, snapshot := range snapshots {
+		size, err := secureRemove(snapshot.Path)
+		if err != nil && !os.IsNotExist(err) {
+			failed = fmt.Errorf("failed to remove %s: %v", filepath.Base(snapshot.Path), err)
+			continue
+		}
+		if err == nil {
+			report.Removed++
+			report.Freed += size
+		}
+	}
+	return failed
+}
+
+// referencedImages lists the images the stored conversations use
+func referencedImages() map[string]bool {
+	images := map[string]bool{}
+	for _, stored := range loadStoredConversations() {
+		for _, m := range stored.Conversation.Messages {
+			for _, name := range m.Images {
+				images[name] = true
+			}
+		}
+	}
+	return images
+}
+
+// removeUnusedImages removes the images no conversation uses any more.
+// Only images in candidates are removed when it isn't nil, otherwise any
+// image older than orphanImageAge.
+func removeUnusedImages(candidates map[string]bool, now time.Time, report *retentionReport) {
+	entries, err := os.ReadDir(imagesDirectory())
+	if err != nil {
+		return
+	}
+	used := referencedImages()
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || used[name] || filepath.Ext(name) == ".tmp" {
+			continue
+		}
+		if candidates != nil && !candidates[name] {
+			continue
+		}
+		if candidates == nil {
+			info, err := entry.Info()
+			if err != nil || now.Sub(info.ModTime()) < orphanImageAge {
+				continue
+			}
+		}
+		if size, err := secureRemove(imagePath(name)); err == nil {
+			report.Removed++
+			report.Freed += size
+		}
+	}
+}
+
+// removeEmptyDirectories removes the model folders of the history that
+// have no snapshots left
+func removeEmptyDirectories(root string) {
+	var dirs []string
+	filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err == nil && d.IsDir() && path != root {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	// Deepest first so parents of nested model names empty out too
+	for i := len(dirs) - 1; i >= 0; i-- {
+		os.Remove(dirs[i])
+	}
+}
+
+// CleanHistory removes redundant snapshots and those the policy doesn't
+// keep, then the images no conversation uses
+func CleanHistory(policy RetentionPolicy) (retentionReport, error) {
+	historyFiles.Lock()
+	defer historyFiles.Unlock()
+	if HistoryLocked() {
+		return retentionReport{}, ErrHistoryLocked
+	}
+
+	now := time.Now()
+	var report retentionReport
+	var failed error
+	for _, snapshots := range loadSnapshots() {
+		redundant := redundantSnapshots(snapshots)
+		skip := map[string]bool{}
+		for _, snapshot := range redundant {
+			skip[snapshot.Path] = true
+		}
+		var kept []historySnapshot
+		for _, snapshot := range snapshots {
+			if !skip[snapshot.Path] {
+				kept = append(kept, snapshot)
+			}
+		}
+		if err := removeSnapshots(append(redundant, expiredSnapshots(kept, policy, now)...), &report); err != nil {
+			failed = err
+		}
+	}
+	removeEmptyDirectories(conversationsDirectory())
+	removeUnusedImages(nil, now, &report)
+	return report, failed
+}
+
+// DeleteModelHistory removes the running chat, the history and the images
+// of a model
+func DeleteModelHistory(modelName string) error {
+	historyFiles.Lock()
+	defer historyFiles.Unlock()
+	if HistoryLocked() {
+		return ErrHistoryLocked
+	}
+
+	// Note the images of the model before their conversations are gone
+	images := map[string]bool{}
+	var files []string
+	for _, stored := range loadStoredConversations() {
+		if stored.Model != modelName {
+			continue
+		}
+		files = append(files, stored.Path)
+		for _, m := range stored.Conversation.Messages {
+			for _, name := range m.Images {
+				images[name] = true
+			}
+		}
+	}
+	// Files that can't be read are removed as well
+	files = append(files, conversationFilePath(modelName), legacyConversationFilePath(modelName))
+	modelDir := filepath.Join(conversationsDirectory(), modelName)
+	filepath.WalkDir(modelDir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			files = append(files, path)
+		}
+		return nil
+	})
+
+	var report retentionReport
+	var failed error
+	for _, path := range files {
+		if _, err := secureRemove(path); err != nil && !os.IsNotExist(err) {
+			failed = fmt.Errorf("failed to remove %s: %v", filepath.Base(path), err)
+		}
+	}
+	if err := os.RemoveAll(modelDir); err != nil && failed == nil {
+		failed = fmt.Errorf("failed to remove %s: %v", modelDir, err)
+	}
+	removeEmptyDirectories(conversationsDirectory())
+	removeUnusedImages(images, time.Now(), &report)
+	return failed
+}
+
+// replacePreviousSnapshot removes the newest snapshot of a model when the
+// conversation just saved continues it, so a chat keeps one snapshot
+// instead of one per reply. Called with historyFiles held.
+func replacePreviousSnapshot(modelDir string, saved string, conversation Conversation) {
+	entries, err := os.ReadDir(modelDir)
+	if err != nil {
+		return
+	}
+	var previous string
+	for _, entry := range entries {
+		path := filepath.Join(modelDir, entry.Name())
+		ext := filepath.Ext(path)
+		if entry.IsDir() || path == saved || (ext != ".json" && ext != ".txt") {
+			continue
+		}
+		// Names end in a timestamp, so the last one is the newest
+		if previous == "" || entry.Name() > filepath.Base(previous) {
+			previous = path
+		}
+	}
+	if previous == "" {
+		return
+	}
+	data, err := readStoreFile(previous)
+	if err != nil {
+		return
+	}
+	var old Conversation
+	if filepath.Ext(previous) == ".txt" {
+		old.Messages = parseLegacyConversation(string(data))
+	} else if old, err = parseConversation(data); err != nil {
+		return
+	}
+	if isPrefixOf(old.Messages, conversation.Messages) {
+		if _, err := secureRemove(previous); err != nil {
+			slog.Warn("Failed to remove replaced snapshot", "file", previous, "err", err)
+		}
+	}
+}
+
+// HistoryJanitor applies the retention policy in the background, on start
+// and every janitorInterval
+type HistoryJanitor struct {
+	// Policy returns the retention policy to apply
+	Policy func() RetentionPolicy
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// NewHistoryJanitor creates a janitor for the policy
+func NewHistoryJanitor(policy func() RetentionPolicy) *HistoryJanitor {
+	return &HistoryJanitor{Policy: policy, stop: make(chan struct{})}
+}
+
+// Start cleans up right away and then periodically until Close
+func (j *HistoryJanitor) Start() {
+	go func() {
+		ticker := time.NewTicker(janitorInterval)
+		defer ticker.Stop()
+		for {
+			j.run()
+			select {
+			case <-ticker.C:
+			case <-j.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (j *HistoryJanitor) run() {
+	report, err := CleanHistory(j.Policy())
+	if err != nil {
+		slog.Error("Failed to clean up conversation history", "err", err)
+	}
+	if report.Removed > 0 {
+		slog.Info("Cleaned up conversation history", "report", report.String())
+	}
+}
+
+// Close stops the janitor
+func (j *HistoryJanitor) Close() {
+	j.once.Do(func() {
+		close(j.stop)
+	})
+}
+
+// formatRetentionLimit shows a limit in its entry, empty for none
+func formatRetentionLimit(value int) string {
+	if value <= 0 {
+		return ""
+	}
+	return strconv.Itoa(value)
+}
+
+// parseRetentionLimit reads a limit typed into the settings, empty or 0
+// for none
+func parseRetentionLimit(limit retentionLimit, text string) (int, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return 0, nil
+	}
+	value, err := strconv.Atoi(text)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("enter a whole number of %s", limit.Unit)
+	}
+	if value > limit.Max {
+		return 0, fmt.Errorf("at most %d %s", limit.Max, limit.Unit)
+	}
+	return value, nil
+}
@@ -0,0 +1,133 @@
+package internal
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var retentionNow = time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// testSnapshot describes a snapshot for the retention tests
+type testSnapshot struct {
+	ageDays  int
+	sizeMB   int64
+	messages int
+	pinned   bool
+}
+
+// makeSnapshots builds snapshots named 0, 1, ... in the given order, whose
+// messages are prefixes of one chat unless distinct is set
+func makeSnapshots(specs []testSnapshot, distinct bool) []historySnapshot {
+	snapshots := make([]historySnapshot, len(specs))
+	for i, spec := range specs {
+		messages := make([]Message, spec.messages)
+		for j := range messages {
+			content := fmt.Sprintf("message %d", j)
+			if distinct {
+				content = fmt.Sprintf("snapshot %d message %d", i, j)
+			}
+			messages[j] = Message{Role: RoleUser, Content: content, Time: retentionNow.Add(time.Duration(j) * time.Minute)}
+		}
+		snapshot := historySnapshot{
+			Time: retentionNow.AddDate(0, 0, -spec.ageDays),
+			Size: spec.sizeMB * 1024 * 1024,
+		}
+		snapshot.Path = fmt.Sprint(i)
+		snapshot.Conversation.Messages = messages
+		snapshot.Conversation.Pinned = spec.pinned
+		snapshots[i] = snapshot
+	}
+	return snapshots
+}
+
+func snapshotNames(snapshots []historySnapshot) []string {
+	names := []string{}
+	for _, snapshot := range snapshots {
+		names = append(names, snapshot.Path)
+	}
+	return names
+}
+
+func TestExpiredSnapshots(t *testing.T) {
+	// Five snapshots, oldest first, one a day, 1 MB each
+	week := []testSnapshot{
+		{ageDays: 5, sizeMB: 1, messages: 1},
+		{ageDays: 4, sizeMB: 1, messages: 1},
+		{ageDays: 3, sizeMB: 1, messages: 1},
+		{ageDays: 2, sizeMB: 1, messages: 1},
+		{ageDays: 1, sizeMB: 1, messages: 1},
+	}
+	pinnedOldest := append([]testSnapshot{{ageDays: 5, sizeMB: 1, messages: 1, pinned: true}}, week[1:]...)
+
+	tests := []struct {
+		name      string
+		snapshots []testSnapshot
+		policy    RetentionPolicy
+		want      []string
+	}{
+		{"no limits", week, RetentionPolicy{}, []string{}},
+		{"age", week, RetentionPolicy{MaxAgeDays: 3}, []string{"0", "1"}},
+		{"count", week, RetentionPolicy{MaxCount: 2}, []string{"0", "1", "2"}},
+		{"size", week, RetentionPolicy{MaxSizeMB: 3}, []string{"0", "1"}},
+		{"count within limit", week, RetentionPolicy{MaxCount: 10}, []string{}},
+		{"age then count", week, RetentionPolicy{MaxAgeDays: 4, MaxCount: 2}, []string{"0", "1", "2"}},
+		{"count stricter than age", week, RetentionPolicy{MaxAgeDays: 10, MaxCount: 4}, []string{"0"}},
+		{"age then size", week, RetentionPolicy{MaxAgeDays: 4, MaxSizeMB: 2}, []string{"0", "1", "2"}},
+		{"count then size", week, RetentionPolicy{MaxCount: 4, MaxSizeMB: 2}, []string{"0", "1", "2"}},
+		{"all limits", week, RetentionPolicy{MaxAgeDays: 10, MaxCount: 4, MaxSizeMB: 3}, []string{"0", "1"}},
+		{"newest kept when all are too old", []testSnapshot{{ageDays: 30, sizeMB: 1, messages: 1}, {ageDays: 20, sizeMB: 1, messages: 1}, {ageDays: 10, sizeMB: 1, messages: 1}}, RetentionPolicy{MaxAgeDays: 7}, []string{"0", "1"}},
+		{"newest kept when too large", []testSnapshot{{ageDays: 2, sizeMB: 2, messages: 1}, {ageDays: 1, sizeMB: 5, messages: 1}}, RetentionPolicy{MaxSizeMB: 1}, []string{"0"}},
+		{"pinned kept past the age", pinnedOldest, RetentionPolicy{MaxAgeDays: 3}, []string{"1"}},
+		{"pinned counts towards the count", pinnedOldest, RetentionPolicy{MaxCount: 3}, []string{"1", "2"}},
+		{"pinned counts towards the size", pinnedOldest, RetentionPolicy{MaxSizeMB: 2}, []string{"1", "2", "3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snapshots := makeSnapshots(tt.snapshots, true)
+			got := snapshotNames(expiredSnapshots(snapshots, tt.policy, retentionNow))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expired = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedundantSnapshots(t *testing.T) {
+	tests := []struct {
+		name      string
+		snapshots []testSnapshot
+		distinct  bool
+		want      []string
+	}{
+		{"prefixes of the newest", []testSnapshot{{ageDays: 3, messages: 2}, {ageDays: 2, messages: 4}, {ageDays: 1, messages: 6}}, false, []string{"1", "0"}},
+		{"identical keep the newest", []testSnapshot{{ageDays: 3, messages: 4}, {ageDays: 2, messages: 4}, {ageDays: 1, messages: 4}}, false, []string{"1", "0"}},
+		{"different chats", []testSnapshot{{ageDays: 2, messages: 4}, {ageDays: 1, messages: 2}}, true, []string{}},
+		{"empty", []testSnapshot{{ageDays: 2}, {ageDays: 1, messages: 2}}, false, []string{"0"}},
+		{"newest kept as a prefix", []testSnapshot{{ageDays: 2, messages: 6}, {ageDays: 1, messages: 2}}, false, []string{}},
+		{"newest kept when empty", []testSnapshot{{ageDays: 2, messages: 2}, {ageDays: 1}}, false, []string{}},
+		{"pinned kept as a prefix", []testSnapshot{{ageDays: 3, messages: 2, pinned: true}, {ageDays: 2, messages: 4}, {ageDays: 1, messages: 6}}, false, []string{"1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snapshots := makeSnapshots(tt.snapshots, tt.distinct)
+			got := snapshotNames(redundantSnapshots(snapshots))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("redundant = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPinningMessagesKeepsSnapshotsRedundant(t *testing.T) {
+	snapshots := makeSnapshots([]testSnapshot{{ageDays: 2, messages: 3}, {ageDays: 1, messages: 4}}, false)
+	snapshots[1].Conversation.Messages[0].Pinned = true
+
+	if !isPrefixOf(snapshots[0].Conversation.Messages, snapshots[1].Conversation.Messages) {
+		t.Error("pinning a message broke the prefix")
+	}
+	if got := snapshotNames(redundantSnapshots(snapshots)); !reflect.DeepEqual(got, []string{"0"}) {
+		t.Errorf("redundant = %v, want [0]", got)
+	}
+}
@@ -0,0 +1,146 @@
+package internal
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// searchSnippetLength is how much text around a match a result shows
+const searchSnippetLength = 80
+
+// searchResult is a chat whose title or messages match a search
+type searchResult struct {
+	Model   string
+	Label   string
+	Snippet string
+}
+
+// searchChats finds the chats whose title or messages contain the query,
+// ignoring case
+func searchChats(chats []storedConversation, query string) []searchResult {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return nil
+	}
+
+	var results []searchResult
+	for _, chat := range chats {
+		label := chat.Conversation.Title
+		if label == "" {
+			label = chat.Model
+		}
+		if strings.Contains(strings.ToLower(label), query) {
+			results = append(results, searchResult{Model: chat.Model, Label: label})
+			continue
+		}
+		for _, message := range chat.Conversation.Messages {
+			if snippet, ok := matchSnippet(message.Content, query); ok {
+				results = append(results, searchResult{Model: chat.Model, Label: label, Snippet: snippet})
+				break
+			}
+		}
+	}
+	return results
+}
+
+// matchSnippet returns the text around the first match of a lowercase query
+func matchSnippet(text string, query string) (string, bool) {
+	lower := strings.ToLower(text)
+	index := strings.Index(lower, query)
+	if index < 0 {
+		return "", false
+	}
+	runes := []rune(text)
+	// Lowercasing rarely changes the length of the text
+	at := min(utf8.RuneCountInString(lower[:index]), len(runes))
+
+	start := max(at-searchSnippetLength/2, 0)
+	end := min(start+searchSnippetLength, len(runes))
+	snippet := strings.Join(strings.Fields(string(runes[start:end])), " ")
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(runes) {
+		snippet += "…"
+	}
+	return snippet, true
+}
+
+// ShowConversationSearch searches the saved chats and opens the chosen one
+func ShowConversationSearch(w fyne.Window, open func(model string)) {
+	// Chats in the old text format may sit next to their converted file
+	var chats []storedConversation
+	seen := map[string]bool{}
+	for _, stored := range readStoredConversations(chatsDirectory()) {
+		if !seen[stored.Model] {
+			seen[stored.Model] = true
+			chats = append(chats, stored)
+		}
+	}
+
+	var results []searchResult
+	status := widget.NewLabel("Type to search the titles and messages of your chats")
+	status.Importance = widget.LowImportance
+
+	list := widget.NewList(
+		func() int {
+			return len(results)
+		},
+		func() fyne.CanvasObject {
+			snippet := widget.NewLabel("")
+			snippet.Importance = widget.LowImportance
+			snippet.Truncation = fyne.TextTruncateEllipsis
+			title := widget.NewLabel("")
+			title.TextStyle = fyne.TextStyle{Bold: true}
+			title.Truncation = fyne.TextTruncateEllipsis
+			return container.NewVBox(title, snippet)
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			if id >= len(results) {
+				return
+			}
+			row := item.(*fyne.Container)
+			row.Objects[0].(*widget.Label).SetText(results[id].Label)
+			row.Objects[1].(*widget.Label).SetText(results[id].Snippet)
+		},
+	)
+
+	var search dialog.Dialog
+	list.OnSelected = func(id widget.ListItemID) {
+		if id < len(results) {
+			search.Hide()
+			open(results[id].Model)
+		}
+	}
+
+	query := widget.NewEntry()
+	query.SetPlaceHolder("Search conversations...")
+	query.OnChanged = func(text string) {
+		results = searchChats(chats, text)
+		switch {
+		case strings.TrimSpace(text) == "":
+			status.SetText("Type to search the titles and messages of your chats")
+		case len(results) == 0:
+			status.SetText("No matching conversations")
+		default:
+			status.SetText("")
+		}
+		list.UnselectAll()
+		list.Refresh()
+	}
+	query.OnSubmitted = func(string) {
+		if len(results) > 0 {
+			list.Select(0)
+		}
+	}
+
+	search = dialog.NewCustom("Search Conversations", "Close", container.NewBorder(query, status, nil, nil, list), w)
+	search.Resize(fyne.NewSize(520, 460))
+	search.Show()
+	w.Canvas().Focus(query)
+}
@@ -0,0 +1,132 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"fyne.io/fyne/v2"
+)
+
+// Kinds of tabs that are reopened with a session
+const (
+	TabChat        = "chat"
+	TabHome        = "home"
+	TabOptions     = "options"
+	TabStatistics  = "statistics"
+	TabDashboard   = "dashboard"
+	TabDiagnostics = "diagnostics"
+	TabCompare     = "compare"
+	TabArena       = "arena"
+	TabBatch       = "batch"
+)
+
+// SessionTab is a tab that was open when NeuraTalk was closed
+type SessionTab struct {
+	Kind  string `json:"kind"`
+	Title string `json:"title,omitempty"`
+	// Model is the model of a chat, its conversation is loaded from disk
+	Model string `json:"model,omitempty"`
+	// Draft is the message that was typed but not sent
+	Draft string `json:"draft,omitempty"`
+	// Scroll is how far down the chat was scrolled
+	Scroll float32 `json:"scroll,omitempty"`
+}
+
+// Session is the layout of the window, reopened on the next start
+type Session struct {
+	Tabs     []SessionTab `json:"tabs"`
+	Selected int          `json:"selected"`
+	Width    float32      `json:"width,omitempty"`
+	Height   float32      `json:"height,omitempty"`
+}
+
+// sessionFilePath is where the session is saved
+func sessionFilePath() string {
+	return filepath.Join(DataDir(), "session.json")
+}
+
+// LoadSession reads the session saved on the last exit. Without one the
+// session has no tabs.
+func LoadSession() (Session, error) {
+	var session Session
+	data, err := readStoreFile(sessionFilePath())
+	if os.IsNotExist(err) {
+		return session, nil
+	}
+	if err != nil {
+		return session, fmt.Errorf("failed to read session: %v", err)
+	}
+	if err := json.Unmarshal(data, &session); err != nil {
+		return Session{}, fmt.Errorf("failed to parse session: %v", err)
+	}
+	return session, nil
+}
+
+// SaveSession saves the session to be restored on the next start
+func SaveSession(session Session) error {
+	data, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal session: %v", err)
+	}
+
+	// Drafts are kept encrypted along with the history
+	filePath := sessionFilePath()
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %v", err)
+	}
+	if err := writeStoreFile(filePath, data); err != nil {
+		return fmt.Errorf("failed to write session file: %v", err)
+	}
+	return nil
+}
+
+// SessionTab describes the chat for the session
+func (io *InputOutput) SessionTab(title string) SessionTab {
+	tab := SessionTab{
+		Kind:  TabChat,
+		Title: title,
+		Model: io.ModelSelect.Selected,
+		Draft: io.InputEntry.Text,
+	}
+	if io.ScrollContainer != nil {
+		tab.Scroll = io.ScrollContainer.Offset.Y
+	}
+	return tab
+}
+
+// RestoreSessionTab reopens the conversation, draft and scroll position of
+// a chat saved with the session
+func (io *InputOutput) RestoreSessionTab(tab SessionTab) {
+	if tab.Model != "" {
+		io.ModelSelect.SetSelected(tab.Model)
+	}
+	io.InputEntry.SetText(tab.Draft)
+	io.pendingScroll = tab.Scroll
+}
+
+// ApplyPendingScroll scrolls a restored chat back to where it was once its
+// tab is shown. The tab is laid out shortly after being selected.
+func (io *InputOutput) ApplyPendingScroll() {
+	offset := io.pendingScroll
+	if offset <= 0 || io.ScrollContainer == nil {
+		return
+	}
+	io.pendingScroll = 0
+
+	go func() {
+		for i := 0; i < 40; i++ {
+			scroll := io.ScrollContainer
+			visible := scroll.Size().Height
+			content := scroll.Content.Size().Height
+			if visible > 0 && content > visible {
+				scroll.Offset = fyne.NewPos(0, min(offset, content-visible))
+				scroll.Refresh()
+				return
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	}()
+}
@@ -3,8 +3,10 @@ package internal
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
@@ -15,6 +17,9 @@ import (
 
 // SettingsData represents the structure of saved settings
 type SettingsData struct {
+	// Version is the settings schema the file was written with
+	Version int `json:"version"`
+
 	Theme          string  `json:"theme"`
 	FontSize       string  `json:"fontSize"`
 	AutoScroll     bool    `json:"autoScroll"`
@@ -25,15 +30,37 @@ type SettingsData struct {
 	TopP           float64 `json:"topP"`
 	TopK           float64 `json:"topK"`
 	ContextLength  float64 `json:"contextLength"`
+	ToolsEnabled   bool    `json:"toolsEnabled"`
+	ToolsDirectory string  `json:"toolsDirectory"`
+
+	// SummarizeContext folds old messages into a summary instead of dropping them
+	SummarizeContext bool `json:"summarizeContext"`
+	// AutoTitle names new chats by asking the model after the first answer
+	AutoTitle bool `json:"autoTitle"`
+	// SendKey is the key combination that sends a message, one of sendKeyNames
+	SendKey string `json:"sendKey"`
+	// Shortcuts rebind actions by ID, an empty shortcut unbinds the action
+	Shortcuts map[string]string `json:"shortcuts,omitempty"`
+	// Retention limits the saved conversation history of every model
+	Retention RetentionPolicy `json:"retention"`
+
+	MCPServers []MCPServerConfig `json:"mcpServers,omitempty"`
+
+	// ModelProfiles override the LLM settings above for single models
+	ModelProfiles map[string]ParameterOverrides `json:"modelProfiles,omitempty"`
+	// Advanced holds the remaining Ollama options
+	Advanced AdvancedOptions `json:"advanced"`
 }
 
 type Settings struct {
 	Window         fyne.Window
 	App            fyne.App
 	ThemeSelect    *widget.Select
+	ThemeErrors    *widget.Label
 	FontSizeSelect *widget.Select
 	AutoScroll     *widget.Check
 	AnimationSpeed *widget.Slider
+	SendKeySelect  *widget.Select
 	ModelConfig    *widget.Button
 	// LLM Settings
 	TemperatureSlider   *widget.Slider
@@ -42,6 +69,46 @@ type Settings struct {
 	TopKSlider          *widget.Slider
 	ContextLengthSlider *widget.Slider
 	ModelSelect         *widget.Select
+	SummarizeContext    *widget.Check
+	AutoTitle           *widget.Check
+	// Tool Settings
+	ToolsEnabled   *widget.Check
+	ToolsDirectory *widget.Entry
+	// MCP Settings
+	MCPServerList *fyne.Container
+	// OnMCPServersChanged is called after MCP servers are added, removed or toggled
+	OnMCPServersChanged func([]MCPServerConfig)
+	// MCPStatus describes the state of a running MCP server
+	MCPStatus func(name string) string
+	// Keyboard shortcuts
+	ShortcutList *fyne.Container
+	// OnShortcutsChanged is called after a shortcut was rebound
+	OnShortcutsChanged func()
+	// Model Profiles
+	ProfileModel *widget.SelectEntry
+	ProfileList  *widget.Label
+	// Advanced Ollama options
+	OptionEntries  map[string]*widget.Entry
+	MirostatSelect *widget.Select
+	StopEntry      *widget.Entry
+	KeepAliveEntry *widget.Entry
+	JSONFormat     *widget.Check
+	// History retention limits, in the order of retentionLimits
+	RetentionEntries []*widget.Entry
+
+	mcpServers    []MCPServerConfig
+	shortcuts     map[string]string
+	retention     RetentionPolicy
+	modelProfiles map[string]ParameterOverrides
+	advanced      AdvancedOptions
+	// savedModel keeps the saved model until the installed ones are known
+	savedModel    string
+	profileEditor *parameterEditor
+	// loadProblems describes what had to be repaired when loading
+	loadProblems []string
+	// themes are the selectable themes by name
+	themes       map[string]*themeDefinition
+	themePreview *container.ThemeOverride
 }
 
 func NewSettings(w fyne.Window, a fyne.App) *Settings {
@@ -55,23 +122,27 @@ func NewSettings(w fyne.Window, a fyne.App) *Settings {
 
 	// Then load saved settings
 	s.loadSettings()
+	s.showLoadProblems()
 
 	return s
 }
 
 func (s *Settings) initializeUIElements() {
 	// Theme selection
-	s.ThemeSelect = widget.NewSelect([]string{"Light", "Dark"}, func(selected string) {
-		if selected == "Dark" {
-			s.App.Settings().SetTheme(theme.DarkTheme())
-		} else {
-			s.App.Settings().SetTheme(theme.LightTheme())
-		}
+	s.ThemeSelect = widget.NewSelect(nil, func(selected string) {
+		s.applyTheme()
 		s.saveSettings()
 	})
 
-	// Font size selection
-	s.FontSizeSelect = widget.NewSelect([]string{"Small", "Medium", "Large"}, func(selected string) {
+	// Problems with theme files
+	s.ThemeErrors = widget.NewLabel("")
+	s.ThemeErrors.Importance = widget.DangerImportance
+	s.ThemeErrors.Wrapping = fyne.TextWrapWord
+	s.reloadThemes()
+
+	// Font size selection, applied to all open tabs
+	s.FontSizeSelect = widget.NewSelect(fontSizeNames, func(selected string) {
+		s.applyTheme()
 		s.saveSettings()
 	})
 
@@ -81,7 +152,7 @@ func (s *Settings) initializeUIElements() {
 	})
 
 	// Animation speed slider
-	s.AnimationSpeed = widget.NewSlider(10, 100)
+	s.AnimationSpeed = widget.NewSlider(minAnimationSpeed, maxAnimationSpeed)
 	s.AnimationSpeed.OnChanged = func(value float64) {
 		s.saveSettings()
 	}
@@ -93,6 +164,7 @@ func (s *Settings) initializeUIElements() {
 
 	// Temperature slider
 	s.TemperatureSlider = widget.NewSlider(0, 2)
+	s.TemperatureSlider.Step = 0.05
 	s.TemperatureSlider.OnChanged = func(value float64) {
 		s.saveSettings()
 	}
@@ -105,6 +177,7 @@ func (s *Settings) initializeUIElements() {
 
 	// Top P slider
 	s.TopPSlider = widget.NewSlider(0, 1)
+	s.TopPSlider.Step = 0.01
 	s.TopPSlider.OnChanged = func(value float64) {
 		s.saveSettings()
 	}
@@ -120,18 +193,90 @@ func (s *Settings) initializeUIElements() {
 	s.ContextLengthSlider.OnChanged = func(value float64) {
 		s.saveSettings()
 	}
+
+	// Summarize instead of dropping messages that don't fit the context
+	s.SummarizeContext = widget.NewCheck("Summarize older messages when the context is full", func(checked bool) {
+		s.saveSettings()
+	})
+
+	// Let the model name new chats
+	s.AutoTitle = widget.NewCheck("Name new chats automatically after the first answer", func(checked bool) {
+		s.saveSettings()
+	})
+
+	// Key combination that sends a message
+	s.SendKeySelect = widget.NewSelect(sendKeyNames, func(selected string) {
+		s.saveSettings()
+	})
+
+	// Tool calling toggle
+	s.ToolsEnabled = widget.NewCheck("Allow models to call tools", func(checked bool) {
+		s.saveSettings()
+	})
+
+	// Folder the read_file tool may access
+	s.ToolsDirectory = widget.NewEntry()
+	s.ToolsDirectory.SetPlaceHolder("No folder shared")
+	s.ToolsDirectory.OnChanged = func(value string) {
+		s.saveSettings()
+	}
+
+	// MCP server list, filled once settings are loaded
+	s.MCPServerList = container.NewVBox()
+
+	// Model whose parameter profile is edited
+	s.ProfileModel = widget.NewSelectEntry(nil)
+	s.ProfileModel.SetPlaceHolder("Model name")
+	s.ProfileList = widget.NewLabel("")
+
+	// Options most users never need to change
+	s.initializeAdvancedOptions()
+
+	// How much conversation history is kept
+	s.initializeRetention()
+}
+
+// data collects the current values of the settings
+func (s *Settings) data() SettingsData {
+	return SettingsData{
+		Version:          settingsVersion,
+		Theme:            s.ThemeSelect.Selected,
+		FontSize:         s.FontSizeSelect.Selected,
+		AutoScroll:       s.AutoScroll.Checked,
+		AnimationSpeed:   sliderValue(s.AnimationSpeed),
+		Model:            s.GetModel(),
+		Temperature:      sliderValue(s.TemperatureSlider),
+		MaxTokens:        sliderValue(s.MaxTokensSlider),
+		TopP:             sliderValue(s.TopPSlider),
+		TopK:             sliderValue(s.TopKSlider),
+		ContextLength:    sliderValue(s.ContextLengthSlider),
+		SummarizeContext: s.SummarizeContext.Checked,
+		AutoTitle:        s.AutoTitle.Checked,
+		SendKey:          s.SendKeySelect.Selected,
+		ToolsEnabled:     s.ToolsEnabled.Checked,
+		ToolsDirectory:   s.ToolsDirectory.Text,
+		MCPServers:       s.mcpServers,
+		Shortcuts:        s.shortcuts,
+		Retention:        s.retention,
+		ModelProfiles:    s.modelProfiles,
+		Advanced:         s.advanced,
+	}
+}
+
+// sliderValue reads a slider without the rounding noise its steps add
+func sliderValue(slider *widget.Slider) float64 {
+	return math.Round(slider.Value*1e6) / 1e6
 }
 
 func (s *Settings) saveSettings() {
 	// Create config directory if it doesn't exist
-	configDir := filepath.Join(".", "config")
-	if err := os.MkdirAll(configDir, 0755); err != nil {
-		dialog.ShowError(fmt.Errorf("Failed to create config directory: %v", err), s.Window)
+	configPath := settingsFilePath()
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		ShowError(fmt.Errorf("Failed to create config directory: %v", err), s.Window)
 		return
 	}
 
 	// Read existing settings if any
-	configPath := filepath.Join(configDir, "settings.json")
 	var existingSettings SettingsData
 	if data, err := os.ReadFile(configPath); err == nil {
 		if err := json.Unmarshal(data, &existingSettings); err != nil {
@@ -141,82 +286,103 @@ func (s *Settings) saveSettings() {
 	}
 
 	// Update only the changed settings
-	settings := SettingsData{
-		Theme:          s.ThemeSelect.Selected,
-		FontSize:       s.FontSizeSelect.Selected,
-		AutoScroll:     s.AutoScroll.Checked,
-		AnimationSpeed: s.AnimationSpeed.Value,
-		Model:          s.ModelSelect.Selected,
-		Temperature:    s.TemperatureSlider.Value,
-		MaxTokens:      s.MaxTokensSlider.Value,
-		TopP:           s.TopPSlider.Value,
-		TopK:           s.TopKSlider.Value,
-		ContextLength:  s.ContextLengthSlider.Value,
+	settings := s.data()
+
+	// The profile editor shows global values where nothing is overridden
+	if s.profileEditor != nil {
+		s.profileEditor.refresh()
 	}
 
 	// Save settings to file with pretty formatting
 	data, err := json.MarshalIndent(settings, "", "  ")
 	if err != nil {
-		dialog.ShowError(fmt.Errorf("Failed to marshal settings: %v", err), s.Window)
+		ShowError(fmt.Errorf("Failed to marshal settings: %v", err), s.Window)
 		return
 	}
 
-	// Write to a temporary file first
+	// Write to a temporary file first, readable only by the user as MCP
+	// servers may be given tokens. A stale one would keep its old mode.
 	tempPath := configPath + ".tmp"
-	if err := os.WriteFile(tempPath, data, 0644); err != nil {
-		dialog.ShowError(fmt.Errorf("Failed to write temporary settings file: %v", err), s.Window)
+	os.Remove(tempPath)
+	if err := os.WriteFile(tempPath, data, 0600); err != nil {
+		ShowError(fmt.Errorf("Failed to write temporary settings file: %v", err), s.Window)
 		return
 	}
 
 	// Rename temporary file to actual file (atomic operation)
 	if err := os.Rename(tempPath, configPath); err != nil {
-		dialog.ShowError(fmt.Errorf("Failed to save settings: %v", err), s.Window)
+		ShowError(fmt.Errorf("Failed to save settings: %v", err), s.Window)
 		// Clean up temp file if it exists
 		os.Remove(tempPath)
 		return
 	}
 }
 
-func (s *Settings) loadSettings() {
-	// Default settings
-	defaultSettings := SettingsData{
-		Theme:          "Light",
-		FontSize:       "Medium",
-		AutoScroll:     true,
-		AnimationSpeed: 20,
-		Model:          "",
-		Temperature:    0.7,
-		MaxTokens:      2048,
-		TopP:           0.9,
-		TopK:           40,
-		ContextLength:  4096,
-	}
-
-	// Try to load saved settings
-	configPath := filepath.Join(".", "config", "settings.json")
-	data, err := os.ReadFile(configPath)
-	if err == nil {
-		var settings SettingsData
-		if err := json.Unmarshal(data, &settings); err == nil {
-			defaultSettings = settings
-		}
+// settingsFilePath is where the settings are saved
+func settingsFilePath() string {
+	return filepath.Join(ConfigDir(), "settings.json")
+}
+
+// defaultSettingsData returns the settings used before anything is saved
+func defaultSettingsData() SettingsData {
+	return SettingsData{
+		Version:          settingsVersion,
+		Theme:            "Light",
+		FontSize:         "Medium",
+		AutoScroll:       true,
+		AnimationSpeed:   20,
+		Model:            "",
+		Temperature:      0.7,
+		MaxTokens:        2048,
+		TopP:             0.9,
+		TopK:             40,
+		ContextLength:    4096,
+		SummarizeContext: true,
+		AutoTitle:        true,
+		SendKey:          SendKeyEnter,
+		ToolsEnabled:     true,
+		ToolsDirectory:   "",
 	}
+}
+
+// LoadSettingsData reads the saved settings without creating any widgets,
+// falling back to the defaults. problems describes the settings that had to
+// be repaired or couldn't be read.
+func LoadSettingsData() (SettingsData, []string) {
+	return readSettingsFile(settingsFilePath())
+}
+
+func (s *Settings) loadSettings() {
+	defaultSettings, problems := LoadSettingsData()
+	s.loadProblems = problems
+	s.applySettings(defaultSettings)
+}
+
+// applySettings shows the given settings in the widgets
+func (s *Settings) applySettings(defaultSettings SettingsData) {
+
+	// Keep non-widget settings first, applying the widgets below saves them
+	s.mcpServers = defaultSettings.MCPServers
+	s.shortcuts = defaultSettings.Shortcuts
+	s.retention = defaultSettings.Retention
+	s.modelProfiles = defaultSettings.ModelProfiles
+	s.savedModel = defaultSettings.Model
+	s.advanced = defaultSettings.Advanced
 
 	// Apply loaded settings to UI elements
 	if s.ThemeSelect != nil {
 		s.ThemeSelect.SetSelected(defaultSettings.Theme)
-		// Apply theme immediately
-		if defaultSettings.Theme == "Dark" {
-			s.App.Settings().SetTheme(theme.DarkTheme())
-		} else {
-			s.App.Settings().SetTheme(theme.LightTheme())
-		}
 	}
 
 	if s.FontSizeSelect != nil {
 		s.FontSizeSelect.SetSelected(defaultSettings.FontSize)
 	}
 
+	// Apply theme immediately, even if the selections didn't change
+	if s.ThemeSelect != nil && s.FontSizeSelect != nil {
+		s.applyTheme()
+	}
+
 	if s.AutoScroll != nil {
 		s.AutoScroll.SetChecked(defaultSettings.AutoScroll)
 	}
@@ -248,22 +414,99 @@ func (s *Settings) loadSettings() {
 	if s.ContextLengthSlider != nil {
 		s.ContextLengthSlider.SetValue(defaultSettings.ContextLength)
 	}
+
+	if s.SummarizeContext != nil {
+		s.SummarizeContext.SetChecked(defaultSettings.SummarizeContext)
+	}
+
+	if s.AutoTitle != nil {
+		s.AutoTitle.SetChecked(defaultSettings.AutoTitle)
+	}
+
+	if s.SendKeySelect != nil {
+		s.SendKeySelect.SetSelected(defaultSettings.SendKey)
+	}
+
+	if s.ToolsEnabled != nil {
+		s.ToolsEnabled.SetChecked(defaultSettings.ToolsEnabled)
+	}
+
+	if s.ToolsDirectory != nil {
+		s.ToolsDirectory.SetText(defaultSettings.ToolsDirectory)
+	}
+
+	if s.MCPServerList != nil {
+		s.RefreshMCPServers()
+	}
+
+	if s.ShortcutList != nil {
+		s.refreshShortcuts()
+	}
+
+	if s.OptionEntries != nil {
+		s.refreshAdvancedOptions()
+	}
+
+	if s.RetentionEntries != nil {
+		s.refreshRetention()
+	}
+}
+
+// showLoadProblems tells the user which settings couldn't be loaded as saved
+func (s *Settings) showLoadProblems() {
+	if len(s.loadProblems) == 0 || s.Window == nil {
+		return
+	}
+	message := widget.NewLabel("Some settings couldn't be loaded as saved and were repaired:\n\n• " + strings.Join(s.loadProblems, "\n• "))
+	message.Wrapping = fyne.TextWrapWord
+	d := dialog.NewCustom("Settings Repaired", "OK", container.NewVScroll(message), s.Window)
+	d.Resize(fyne.NewSize(500, 300))
+	d.Show()
 }
 
 func (s *Settings) GetContainer() *fyne.Container {
 	// Create labels for the settings
 	themeLabel := widget.NewLabel("Theme:")
 	fontLabel := widget.NewLabel("Font Size:")
-	llmSettingsLabel := widget.NewLabelWithStyle("LLM Settings", fyne.TextAlignCenter, fyne.TextStyle{Bold: true})
+	llmSettingsLabel := s.sectionHeader("LLM Settings")
 	modelLabel := widget.NewLabel("Model:")
+	toolsSettingsLabel := s.sectionHeader("Tools")
+	mcpSettingsLabel := s.sectionHeader("MCP Servers")
+	profilesSettingsLabel := s.sectionHeader("Model Profiles")
+	advancedSettingsLabel := s.sectionHeader("Advanced Options")
+	shortcutsSettingsLabel := s.sectionHeader("Keyboard Shortcuts")
+	historySettingsLabel := s.sectionHeader("History")
+	privacySettingsLabel := s.sectionHeader("Privacy")
+	appearanceSettingsLabel := s.sectionHeader("Appearance")
+	inputSettingsLabel := s.sectionHeader("Input")
+
+	// Share settings between installations
+	importButton := widget.NewButtonWithIcon("Import...", theme.FolderOpenIcon(), s.importSettings)
+	exportButton := widget.NewButtonWithIcon("Export...", theme.DocumentSaveIcon(), s.exportSettings)
+
+	// Let the user pick the shared folder instead of typing it
+	browseButton := widget.NewButton("Browse...", func() {
+		dialog.ShowFolderOpen(func(dir fyne.ListableURI, err error) {
+			if err != nil {
+				ShowError(err, s.Window)
+				return
+			}
+			if dir != nil {
+				s.ToolsDirectory.SetText(dir.Path())
+			}
+		}, s.Window)
+	})
 
 	// Create a container with the form and model config button
 	content := container.NewVBox(
-		widget.NewLabel("Settings"),
+		container.NewBorder(nil, nil, nil, container.NewHBox(importButton, exportButton), widget.NewLabel("Settings")),
+		widget.NewSeparator(),
+		appearanceSettingsLabel,
 		widget.NewSeparator(),
 		container.NewVBox(
 			widget.NewLabel("Theme"),
 			container.NewHBox(themeLabel, s.ThemeSelect),
+			s.newThemeTools(),
 			widget.NewLabel("Font Size"),
 			container.NewHBox(fontLabel, s.FontSizeSelect),
 			s.AutoScroll,
@@ -272,6 +515,14 @@ func (s *Settings) GetContainer() *fyne.Container {
 			widget.NewLabel("(slower) ← → (faster)"),
 		),
 		widget.NewSeparator(),
+		inputSettingsLabel,
+		widget.NewSeparator(),
+		container.NewVBox(
+			widget.NewLabel("Send Messages With"),
+			s.SendKeySelect,
+			widget.NewLabel("The other keys add a new line; Ctrl+Enter always sends"),
+		),
+		widget.NewSeparator(),
 		llmSettingsLabel,
 		widget.NewSeparator(),
 		container.NewVBox(
@@ -292,7 +543,46 @@ func (s *Settings) GetContainer() *fyne.Container {
 			widget.NewLabel("Context Length"),
 			s.ContextLengthSlider,
 			widget.NewLabel("(shorter) ← → (longer)"),
+			s.SummarizeContext,
+			s.AutoTitle,
 		),
+		widget.NewSeparator(),
+		profilesSettingsLabel,
+		widget.NewSeparator(),
+		s.newProfileEditor(),
+		widget.NewSeparator(),
+		advancedSettingsLabel,
+		widget.NewSeparator(),
+		s.newAdvancedOptionsForm(),
+		widget.NewSeparator(),
+		toolsSettingsLabel,
+		widget.NewSeparator(),
+		container.NewVBox(
+			s.ToolsEnabled,
+			widget.NewLabel("Shared Folder (read_file)"),
+			container.NewBorder(nil, nil, nil, browseButton, s.ToolsDirectory),
+			widget.NewLabel("Built-in tools: calculator, current_time, read_file"),
+		),
+		widget.NewSeparator(),
+		mcpSettingsLabel,
+		widget.NewSeparator(),
+		container.NewVBox(
+			widget.NewLabel("Tools of MCP servers ask for approval before they run"),
+			s.MCPServerList,
+			widget.NewButtonWithIcon("Add Server", theme.ContentAddIcon(), s.showAddMCPServerDialog),
+		),
+		widget.NewSeparator(),
+		shortcutsSettingsLabel,
+		widget.NewSeparator(),
+		s.newShortcutEditor(),
+		widget.NewSeparator(),
+		historySettingsLabel,
+		widget.NewSeparator(),
+		s.newRetentionForm(),
+		widget.NewSeparator(),
+		privacySettingsLabel,
+		widget.NewSeparator(),
+		s.newEncryptionEditor(),
 	)
 
 	// Wrap the content in a scroll container
@@ -330,6 +620,9 @@ func (s *Settings) GetAnimationSpeed() float64 {
 
 // LLM Settings getters
 func (s *Settings) GetModel() string {
+	if s.ModelSelect.Selected == "" {
+		return s.savedModel
+	}
 	return s.ModelSelect.Selected
 }
 
@@ -352,3 +645,42 @@ func (s *Settings) GetTopK() float64 {
 func (s *Settings) GetContextLength() float64 {
 	return s.ContextLengthSlider.Value
 }
+
+func (s *Settings) IsSummarizeContextEnabled() bool {
+	return s.SummarizeContext.Checked
+}
+
+// IsAutoTitleEnabled returns whether new chats are named by the model
+func (s *Settings) IsAutoTitleEnabled() bool {
+	return s.AutoTitle.Checked
+}
+
+// GetSendKey returns the key combination that sends a message
+func (s *Settings) GetSendKey() string {
+	return s.SendKeySelect.Selected
+}
+
+// Tool Settings getters
+func (s *Settings) AreToolsEnabled() bool {
+	return s.ToolsEnabled.Checked
+}
+
+func (s *Settings) GetToolsDirectory() string {
+	return s.ToolsDirectory.Text
+}
+
+// ResolveParameters returns the parameters used for a model and chat, and
+// where each of them comes from
+func (s *Settings) ResolveParameters(modelName string, chat *ParameterOverrides) (GenerationParameters, map[string]string) {
+	return s.data().ResolveParameters(modelName, chat)
+}
+
+// OptionsFor returns the request options for a model and, optionally, a chat
+func (s *Settings) OptionsFor(modelName string, chat *ParameterOverrides) map[string]any {
+	return s.data().OptionsFor(modelName, chat)
+}
+
+// NewChatRequest builds a chat request with all generation settings
+func (s *Settings) NewChatRequest(modelName string, chat *ParameterOverrides, messages []ChatMessage) *ChatRequest {
+	return s.data().NewChatRequest(modelName, chat, messages)
+}
@@ -0,0 +1,155 @@
+package internal
+
+import (
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// initializeAdvancedOptions creates the widgets of the advanced Ollama options
+func (s *Settings) initializeAdvancedOptions() {
+	s.OptionEntries = map[string]*widget.Entry{}
+	for _, spec := range ollamaOptionSpecs {
+		if spec.Key == "mirostat" {
+			continue
+		}
+		spec := spec
+		entry := widget.NewEntry()
+		entry.SetPlaceHolder("default")
+		entry.Validator = func(text string) error {
+			_, _, err := parseOptionValue(spec, text)
+			return err
+		}
+		entry.OnChanged = func(text string) {
+			value, set, err := parseOptionValue(spec, text)
+			if err != nil {
+				return
+			}
+			s.setAdvancedValue(spec.Key, value, set)
+		}
+		s.OptionEntries[spec.Key] = entry
+	}
+
+	// Mirostat replaces top-k/top-p sampling when turned on
+	s.MirostatSelect = widget.NewSelect(mirostatModes, func(selected string) {
+		for i, mode := range mirostatModes {
+			if mode == selected {
+				s.setAdvancedValue("mirostat", float64(i), i > 0)
+			}
+		}
+	})
+
+	// Stop sequences, one per line
+	s.StopEntry = widget.NewMultiLineEntry()
+	s.StopEntry.SetPlaceHolder("One stop sequence per line, \\n for a line break")
+	s.StopEntry.SetMinRowsVisible(2)
+	s.StopEntry.OnChanged = func(text string) {
+		s.advanced.Stop = parseStopSequences(text)
+		s.saveSettings()
+	}
+
+	// How long the model stays in memory after a request
+	s.KeepAliveEntry = widget.NewEntry()
+	s.KeepAliveEntry.SetPlaceHolder("default (5m), -1 keeps the model loaded")
+	s.KeepAliveEntry.Validator = func(text string) error {
+		_, err := parseKeepAlive(text)
+		return err
+	}
+	s.KeepAliveEntry.OnChanged = func(text string) {
+		if _, err := parseKeepAlive(text); err != nil {
+			return
+		}
+		s.advanced.KeepAlive = strings.TrimSpace(text)
+		s.saveSettings()
+	}
+
+	// JSON mode
+	s.JSONFormat = widget.NewCheck("Answer in JSON format", func(checked bool) {
+		s.advanced.JSONFormat = checked
+		s.saveSettings()
+	})
+}
+
+// setAdvancedValue sets a numeric option, or resets it to the model default
+func (s *Settings) setAdvancedValue(key string, value float64, set bool) {
+	values := make(map[string]float64, len(s.advanced.Values)+1)
+	for k, v := range s.advanced.Values {
+		values[k] = v
+	}
+	if set {
+		values[key] = value
+	} else {
+		delete(values, key)
+	}
+	if len(values) == 0 {
+		values = nil
+	}
+	s.advanced.Values = values
+	s.saveSettings()
+}
+
+// parseStopSequences reads one stop sequence per line
+func parseStopSequences(text string) []string {
+	var stop []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			stop = append(stop, strings.ReplaceAll(line, `\n`, "\n"))
+		}
+	}
+	return stop
+}
+
+// formatStopSequences shows stop sequences the way they are typed
+func formatStopSequences(stop []string) string {
+	lines := make([]string, len(stop))
+	for i, sequence := range stop {
+		lines[i] = strings.ReplaceAll(sequence, "\n", `\n`)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// refreshAdvancedOptions shows the loaded advanced options
+func (s *Settings) refreshAdvancedOptions() {
+	// Setting the widgets saves, so work on a copy of the loaded options
+	advanced := s.advanced
+
+	for key, entry := range s.OptionEntries {
+		text := ""
+		if value, ok := advanced.Values[key]; ok {
+			text = strconv.FormatFloat(value, 'f', -1, 64)
+		}
+		entry.SetText(text)
+	}
+
+	mode := int(advanced.Values["mirostat"])
+	if mode < 0 || mode >= len(mirostatModes) {
+		mode = 0
+	}
+	s.MirostatSelect.SetSelected(mirostatModes[mode])
+	s.StopEntry.SetText(formatStopSequences(advanced.Stop))
+	s.KeepAliveEntry.SetText(advanced.KeepAlive)
+	s.JSONFormat.SetChecked(advanced.JSONFormat)
+}
+
+// newAdvancedOptionsForm lays out the advanced options
+func (s *Settings) newAdvancedOptionsForm() fyne.CanvasObject {
+	form := widget.NewForm()
+	for _, spec := range ollamaOptionSpecs {
+		if spec.Key == "mirostat" {
+			form.Append(spec.Name, s.MirostatSelect)
+			continue
+		}
+		item := widget.NewFormItem(spec.Name, s.OptionEntries[spec.Key])
+		item.HintText = spec.Hint
+		form.AppendItem(item)
+	}
+	form.Append("Stop Sequences", s.StopEntry)
+	form.Append("Keep Alive", s.KeepAliveEntry)
+	form.Append("Format", s.JSONFormat)
+
+	help := widget.NewLabel("Leave a field empty to use the model's default.")
+	return container.NewVBox(help, form)
+}
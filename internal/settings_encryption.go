@@ -0,0 +1,158 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// minPassphraseLength is the shortest passphrase the history accepts
+const minPassphraseLength = 8
+
+// validatePassphrase checks a new passphrase
+func validatePassphrase(text string) error {
+	if utf8.RuneCountInString(text) < minPassphraseLength {
+		return fmt.Errorf("use at least %d characters", minPassphraseLength)
+	}
+	return nil
+}
+
+// newEncryptionEditor creates the controls that encrypt the history
+func (s *Settings) newEncryptionEditor() fyne.CanvasObject {
+	status := widget.NewLabel("")
+	status.Wrapping = fyne.TextWrapWord
+	enable := widget.NewButtonWithIcon("Encrypt History...", theme.VisibilityOffIcon(), nil)
+	change := widget.NewButton("Change Passphrase...", nil)
+	disable := widget.NewButton("Turn Off Encryption...", nil)
+
+	refresh := func() {
+		if HistoryEncrypted() {
+			status.SetText("Chats, history and images are encrypted with your passphrase, which is asked for on start. A forgotten passphrase can't be recovered.")
+			enable.Hide()
+			change.Show()
+			disable.Show()
+		} else {
+			status.SetText("Chats, history and images are stored unencrypted. Encrypting them asks for a passphrase on every start.")
+			enable.Show()
+			change.Hide()
+			disable.Hide()
+		}
+	}
+	refresh()
+
+	enable.OnTapped = func() {
+		s.showPassphraseForm("Encrypt History", "encrypt the history", false, true, func(_ string, passphrase string) error {
+			return EnableHistoryEncryption(passphrase)
+		}, refresh)
+	}
+	change.OnTapped = func() {
+		s.showPassphraseForm("Change Passphrase", "change the passphrase", true, true, ChangeHistoryPassphrase, refresh)
+	}
+	disable.OnTapped = func() {
+		s.showPassphraseForm("Turn Off Encryption", "decrypt the history", true, false, func(current string, _ string) error {
+			return DisableHistoryEncryption(current)
+		}, refresh)
+	}
+
+	return container.NewVBox(status, container.NewHBox(enable, change, disable))
+}
+
+// showPassphraseForm asks for the current passphrase, a new one or both and
+// runs apply with them in the background. action completes "Failed to".
+func (s *Settings) showPassphraseForm(title string, action string, askCurrent bool, askNew bool, apply func(current string, passphrase string) error, done func()) {
+	current := widget.NewPasswordEntry()
+	passphrase := widget.NewPasswordEntry()
+	passphrase.Validator = validatePassphrase
+	confirm := widget.NewPasswordEntry()
+	confirm.Validator = func(text string) error {
+		if text != passphrase.Text {
+			return errors.New("the passphrases don't match")
+		}
+		return nil
+	}
+
+	var items []*widget.FormItem
+	if askCurrent {
+		items = append(items, widget.NewFormItem("Passphrase", current))
+	}
+	if askNew {
+		items = append(items,
+			widget.NewFormItem("New Passphrase", passphrase),
+			widget.NewFormItem("Repeat", confirm),
+		)
+	}
+
+	form := dialog.NewForm(title, "OK", "Cancel", items, func(ok bool) {
+		if !ok {
+			return
+		}
+		progress := dialog.NewCustomWithoutButtons(title, widget.NewProgressBarInfinite(), s.Window)
+		progress.Show()
+		go func() {
+			err := apply(current.Text, passphrase.Text)
+			progress.Hide()
+			if err != nil {
+				ShowError(fmt.Errorf("Failed to %s: %v", action, err), s.Window)
+			}
+			done()
+		}()
+	}, s.Window)
+	form.Resize(fyne.NewSize(400, 0))
+	form.Show()
+}
+
+// ShowUnlock fills the window with a prompt for the passphrase of the
+// encrypted history and calls onUnlocked once it is right
+func ShowUnlock(w fyne.Window, onUnlocked func()) {
+	message := widget.NewLabelWithStyle("Your conversation history is encrypted", fyne.TextAlignCenter, fyne.TextStyle{Bold: true})
+	problem := widget.NewLabel("")
+	problem.Importance = widget.DangerImportance
+	problem.Alignment = fyne.TextAlignCenter
+	problem.Hide()
+
+	passphrase := widget.NewPasswordEntry()
+	passphrase.SetPlaceHolder("Passphrase")
+
+	var unlock *widget.Button
+	submit := func() {
+		unlock.Disable()
+		passphrase.Disable()
+		go func() {
+			err := UnlockHistory(passphrase.Text)
+			unlock.Enable()
+			passphrase.Enable()
+			if err != nil {
+				problem.SetText(fmt.Sprintf("Failed to unlock: %v", err))
+				problem.Show()
+				passphrase.SetText("")
+				w.Canvas().Focus(passphrase)
+				return
+			}
+			onUnlocked()
+		}()
+	}
+	unlock = widget.NewButtonWithIcon("Unlock", theme.ConfirmIcon(), submit)
+	unlock.Importance = widget.HighImportance
+	passphrase.OnSubmitted = func(string) {
+		submit()
+	}
+	quit := widget.NewButton("Quit", func() {
+		fyne.CurrentApp().Quit()
+	})
+
+	form := container.NewVBox(
+		message,
+		widget.NewLabelWithStyle("Enter your passphrase to open NeuraTalk", fyne.TextAlignCenter, fyne.TextStyle{}),
+		container.NewGridWrap(fyne.NewSize(360, passphrase.MinSize().Height), passphrase),
+		problem,
+		container.NewGridWithColumns(2, quit, unlock),
+	)
+	w.SetContent(container.NewCenter(form))
+	w.Canvas().Focus(passphrase)
+}
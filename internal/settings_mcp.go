@@ -0,0 +1,141 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// GetMCPServers returns the configured MCP servers
+func (s *Settings) GetMCPServers() []MCPServerConfig {
+	servers := make([]MCPServerConfig, len(s.mcpServers))
+	copy(servers, s.mcpServers)
+	return servers
+}
+
+// setMCPServers stores a new server list and notifies listeners
+func (s *Settings) setMCPServers(servers []MCPServerConfig) {
+	s.mcpServers = servers
+	s.saveSettings()
+	s.RefreshMCPServers()
+	if s.OnMCPServersChanged != nil {
+		s.OnMCPServersChanged(s.GetMCPServers())
+	}
+}
+
+// RefreshMCPServers redraws the MCP server list with the current status
+func (s *Settings) RefreshMCPServers() {
+	s.MCPServerList.RemoveAll()
+	if len(s.mcpServers) == 0 {
+		s.MCPServerList.Add(widget.NewLabel("No servers configured"))
+	}
+
+	for i, server := range s.mcpServers {
+		index := i
+		enabled := widget.NewCheck(server.Name, func(checked bool) {
+			servers := s.GetMCPServers()
+			if servers[index].Enabled == checked {
+				return
+			}
+			servers[index].Enabled = checked
+			s.setMCPServers(servers)
+		})
+		enabled.SetChecked(server.Enabled)
+
+		removeButton := widget.NewButtonWithIcon("", theme.DeleteIcon(), func() {
+			servers := s.GetMCPServers()
+			servers = append(servers[:index], servers[index+1:]...)
+			s.setMCPServers(servers)
+		})
+
+		status := "disabled"
+		if server.Enabled && s.MCPStatus != nil {
+			status = s.MCPStatus(server.Name)
+		}
+		details := widget.NewLabel(strings.TrimSpace(server.Command+" "+strings.Join(server.Args, " ")) + "\n" + status)
+		details.Wrapping = fyne.TextWrapWord
+
+		s.MCPServerList.Add(container.NewBorder(nil, nil, enabled, removeButton, details))
+	}
+	s.MCPServerList.Refresh()
+}
+
+// showAddMCPServerDialog asks for the details of a new stdio MCP server
+func (s *Settings) showAddMCPServerDialog() {
+	nameEntry := widget.NewEntry()
+	nameEntry.SetPlaceHolder("tickets")
+	commandEntry := widget.NewEntry()
+	commandEntry.SetPlaceHolder("npx")
+	argsEntry := widget.NewMultiLineEntry()
+	argsEntry.SetPlaceHolder("One argument per line")
+	envEntry := widget.NewMultiLineEntry()
+	envEntry.SetPlaceHolder("KEY=value, one per line")
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Name", nameEntry),
+		widget.NewFormItem("Command", commandEntry),
+		widget.NewFormItem("Arguments", argsEntry),
+		widget.NewFormItem("Environment", envEntry),
+	}
+
+	form := dialog.NewForm("Add MCP Server", "Add", "Cancel", items, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+
+		server, err := parseMCPServerForm(nameEntry.Text, commandEntry.Text, argsEntry.Text, envEntry.Text)
+		if err != nil {
+			ShowError(err, s.Window)
+			return
+		}
+		for _, existing := range s.mcpServers {
+			if existing.Name == server.Name {
+				ShowError(fmt.Errorf("A server named %s already exists", server.Name), s.Window)
+				return
+			}
+		}
+
+		s.setMCPServers(append(s.GetMCPServers(), server))
+	}, s.Window)
+	form.Resize(fyne.NewSize(500, 400))
+	form.Show()
+}
+
+// parseMCPServerForm builds a server configuration from the add dialog
+func parseMCPServerForm(name, command, args, env string) (MCPServerConfig, error) {
+	server := MCPServerConfig{
+		Name:    strings.TrimSpace(name),
+		Command: strings.TrimSpace(command),
+		Enabled: true,
+	}
+	if server.Name == "" || server.Command == "" {
+		return server, fmt.Errorf("Name and command are required")
+	}
+
+	for _, line := range strings.Split(args, "\n") {
+		if arg := strings.TrimSpace(line); arg != "" {
+			server.Args = append(server.Args, arg)
+		}
+	}
+
+	for _, line := range strings.Split(env, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return server, fmt.Errorf("Invalid environment variable %q, expected KEY=value", line)
+		}
+		if server.Env == nil {
+			server.Env = map[string]string{}
+		}
+		server.Env[strings.TrimSpace(key)] = value
+	}
+	return server, nil
+}
@@ -0,0 +1,216 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// parameterRow edits one parameter of a parameterEditor
+type parameterRow struct {
+	spec     parameterSpec
+	override *widget.Check
+	slider   *widget.Slider
+	value    *widget.Label
+	source   *widget.Label
+}
+
+// parameterEditor edits one layer of parameter overrides and shows the
+// effective value of every parameter together with the layer it comes from
+type parameterEditor struct {
+	rows      []*parameterRow
+	overrides ParameterOverrides
+	// resolve computes the effective parameters with the given overrides
+	resolve func(ParameterOverrides) (GenerationParameters, map[string]string)
+	// onChange is called after the user changed the overrides
+	onChange func(ParameterOverrides)
+	updating bool
+	content  *fyne.Container
+}
+
+// newParameterEditor creates an editor for a layer of overrides
+func newParameterEditor(resolve func(ParameterOverrides) (GenerationParameters, map[string]string), onChange func(ParameterOverrides)) *parameterEditor {
+	e := &parameterEditor{
+		resolve:  resolve,
+		onChange: onChange,
+		content:  container.NewVBox(),
+	}
+
+	for _, spec := range parameterSpecs {
+		row := &parameterRow{
+			spec:   spec,
+			slider: widget.NewSlider(spec.Min, spec.Max),
+			value:  widget.NewLabel(""),
+			source: widget.NewLabel(""),
+		}
+		row.slider.Step = spec.Step
+		row.source.Importance = widget.LowImportance
+		row.override = widget.NewCheck(spec.Name, func(checked bool) {
+			e.setOverride(row, checked)
+		})
+		row.slider.OnChanged = func(value float64) {
+			e.setValue(row, value)
+		}
+
+		e.rows = append(e.rows, row)
+		e.content.Add(container.NewBorder(nil, nil, row.override, container.NewHBox(row.value, row.source), row.slider))
+	}
+
+	e.refresh()
+	return e
+}
+
+// SetOverrides shows another set of overrides without reporting a change
+func (e *parameterEditor) SetOverrides(overrides ParameterOverrides) {
+	e.overrides = overrides
+	e.refresh()
+}
+
+// setOverride starts overriding a parameter with its current value, or
+// falls back to the layers below
+func (e *parameterEditor) setOverride(row *parameterRow, checked bool) {
+	if e.updating {
+		return
+	}
+	field := row.spec.override(&e.overrides)
+	if checked {
+		params, _ := e.resolve(e.overrides)
+		value := *row.spec.value(&params)
+		*field = &value
+	} else {
+		*field = nil
+	}
+	e.changed()
+}
+
+// setValue changes an overridden parameter
+func (e *parameterEditor) setValue(row *parameterRow, value float64) {
+	if e.updating {
+		return
+	}
+	field := row.spec.override(&e.overrides)
+	if *field == nil {
+		return
+	}
+	*field = &value
+	e.changed()
+}
+
+// changed reports the new overrides and updates the shown values
+func (e *parameterEditor) changed() {
+	// Hand out a copy so later edits don't change what was saved
+	overrides := ParameterOverrides{}
+	for _, spec := range parameterSpecs {
+		if value := *spec.override(&e.overrides); value != nil {
+			v := *value
+			*spec.override(&overrides) = &v
+		}
+	}
+	if e.onChange != nil {
+		e.onChange(overrides)
+	}
+	e.refresh()
+}
+
+// refresh shows the effective values and their sources
+func (e *parameterEditor) refresh() {
+	e.updating = true
+	defer func() { e.updating = false }()
+
+	params, sources := e.resolve(e.overrides)
+	for _, row := range e.rows {
+		value := *row.spec.value(&params)
+		overridden := *row.spec.override(&e.overrides) != nil
+
+		row.override.SetChecked(overridden)
+		row.slider.SetValue(value)
+		if overridden {
+			row.slider.Enable()
+		} else {
+			row.slider.Disable()
+		}
+		row.value.SetText(fmt.Sprintf(row.spec.Format, value))
+		row.source.SetText("(" + sources[row.spec.Name] + ")")
+	}
+}
+
+// SetAvailableModels offers the installed models in the model pickers
+func (s *Settings) SetAvailableModels(models []string) {
+	s.ModelSelect.Options = models
+	s.ModelSelect.SetSelected(s.savedModel)
+	s.ModelSelect.Refresh()
+	s.ProfileModel.SetOptions(models)
+}
+
+// ModelProfile returns the parameter overrides of a model
+func (s *Settings) ModelProfile(modelName string) ParameterOverrides {
+	return s.modelProfiles[modelName]
+}
+
+// setModelProfile stores the overrides of a model, dropping empty profiles
+func (s *Settings) setModelProfile(modelName string, profile ParameterOverrides) {
+	profiles := make(map[string]ParameterOverrides, len(s.modelProfiles)+1)
+	for name, p := range s.modelProfiles {
+		profiles[name] = p
+	}
+	if profile.IsEmpty() {
+		delete(profiles, modelName)
+	} else {
+		profiles[modelName] = profile
+	}
+	s.modelProfiles = profiles
+	s.saveSettings()
+	s.refreshProfileList()
+}
+
+// refreshProfileList names the models that have a profile
+func (s *Settings) refreshProfileList() {
+	if s.ProfileList == nil {
+		return
+	}
+	names := make([]string, 0, len(s.modelProfiles))
+	for name := range s.modelProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		s.ProfileList.SetText("No model profiles yet")
+		return
+	}
+	s.ProfileList.SetText("Profiles: " + strings.Join(names, ", "))
+}
+
+// newProfileEditor creates the editor of the model profiles
+func (s *Settings) newProfileEditor() fyne.CanvasObject {
+	s.profileEditor = newParameterEditor(
+		func(o ParameterOverrides) (GenerationParameters, map[string]string) {
+			data := s.data()
+			data.ModelProfiles = map[string]ParameterOverrides{s.ProfileModel.Text: o}
+			return data.ResolveParameters(s.ProfileModel.Text, nil)
+		},
+		func(o ParameterOverrides) {
+			if name := strings.TrimSpace(s.ProfileModel.Text); name != "" {
+				s.setModelProfile(name, o)
+			}
+		},
+	)
+
+	s.ProfileModel.OnChanged = func(name string) {
+		s.profileEditor.SetOverrides(s.ModelProfile(strings.TrimSpace(name)))
+	}
+	s.refreshProfileList()
+
+	help := widget.NewLabel("Check a parameter to override the global value for this model. Chats can override it again from their Parameters button.")
+	help.Wrapping = fyne.TextWrapWord
+
+	return container.NewVBox(
+		help,
+		container.NewBorder(nil, nil, widget.NewLabel("Model:"), nil, s.ProfileModel),
+		s.profileEditor.content,
+		s.ProfileList,
+	)
+}
@@ -0,0 +1,72 @@
+package internal
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// GetRetention returns how much history is kept for every model
+func (s *Settings) GetRetention() RetentionPolicy {
+	return s.retention
+}
+
+// initializeRetention creates an entry for every limit of the retention
+// policy
+func (s *Settings) initializeRetention() {
+	s.RetentionEntries = make([]*widget.Entry, len(retentionLimits))
+	for i, l := range retentionLimits {
+		limit := l
+		entry := widget.NewEntry()
+		entry.SetPlaceHolder("no limit")
+		entry.Validator = func(text string) error {
+			_, err := parseRetentionLimit(limit, text)
+			return err
+		}
+		entry.OnChanged = func(text string) {
+			value, err := parseRetentionLimit(limit, text)
+			if err != nil {
+				return
+			}
+			*limit.value(&s.retention) = value
+			s.saveSettings()
+		}
+		s.RetentionEntries[i] = entry
+	}
+}
+
+// refreshRetention shows the retention policy in its entries
+func (s *Settings) refreshRetention() {
+	// Setting the entries saves, so work on a copy of the loaded policy
+	retention := s.retention
+	for i, limit := range retentionLimits {
+		s.RetentionEntries[i].SetText(formatRetentionLimit(*limit.value(&retention)))
+	}
+}
+
+// newRetentionForm creates the form of the retention policy
+func (s *Settings) newRetentionForm() fyne.CanvasObject {
+	form := widget.NewForm()
+	for i, limit := range retentionLimits {
+		form.Append(fmt.Sprintf("%s (%s)", limit.Name, limit.Unit), s.RetentionEntries[i])
+	}
+
+	cleanUp := widget.NewButtonWithIcon("Clean Up Now", theme.DeleteIcon(), func() {
+		go func() {
+			report, err := CleanHistory(s.GetRetention())
+			if err != nil {
+				ShowError(fmt.Errorf("Failed to clean up history: %v", err), s.Window)
+				return
+			}
+			dialog.ShowInformation("Clean Up History", report.String(), s.Window)
+		}()
+	})
+
+	help := widget.NewLabel("Limits apply to the saved history of each model, checked on start and every hour. Snapshots another snapshot contains in full are always removed.")
+	help.Wrapping = fyne.TextWrapWord
+	return container.NewVBox(help, form, container.NewHBox(cleanUp))
+}
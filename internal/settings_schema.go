@@ -0,0 +1,304 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// settingsVersion is the version of the settings file written by this build.
+// Bump it and add a migration whenever the meaning of a field changes.
+const settingsVersion = 2
+
+// settingsMigrations upgrade the raw settings of a version to the next one.
+// Files without a version are version 1.
+var settingsMigrations = map[int]func(map[string]any){
+	1: migrateSettingsV1,
+}
+
+// Choices offered by the select widgets of the settings
+var (
+	fontSizeNames = []string{"Small", "Medium", "Large"}
+)
+
+// Ranges of the sliders that aren't generation parameters
+const (
+	minAnimationSpeed = 10
+	maxAnimationSpeed = 100
+)
+
+// migrateSettingsV1 cleans up files written before settings were
+// versioned. They stored empty strings for selections that were never made
+// and saved the theme in whatever case it was typed.
+func migrateSettingsV1(raw map[string]any) {
+	for _, key := range []string{"theme", "fontSize"} {
+		if value, ok := raw[key].(string); ok && strings.TrimSpace(value) == "" {
+			delete(raw, key)
+		}
+	}
+	if value, ok := raw["theme"].(string); ok {
+		for _, name := range []string{ThemeLight, ThemeDark} {
+			if strings.EqualFold(value, name) {
+				raw["theme"] = name
+			}
+		}
+	}
+}
+
+// decodeSettings reads a settings file, migrating it to the current version
+// and repairing what it can. Fields that can't be read keep their defaults;
+// problems lists what was changed. An error means the file isn't a JSON
+// object at all.
+func decodeSettings(data []byte) (SettingsData, []string, error) {
+	settings := defaultSettingsData()
+	var problems []string
+
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return settings, nil, fmt.Errorf("settings file is not valid JSON: %v", err)
+	}
+	if raw == nil {
+		return settings, nil, fmt.Errorf("settings file is empty")
+	}
+
+	version := 1
+	if value, ok := raw["version"].(float64); ok && value >= 1 {
+		version = int(value)
+	}
+	if version > settingsVersion {
+		problems = append(problems, fmt.Sprintf("The settings were written by a newer version of NeuraTalk (settings version %d). Unknown settings will be lost when saving.", version))
+	}
+	for ; version < settingsVersion; version++ {
+		if migrate, ok := settingsMigrations[version]; ok {
+			migrate(raw)
+		}
+	}
+
+	// Decode one field at a time so a single bad value doesn't discard the rest
+	keys := make([]string, 0, len(raw))
+	for key := range raw {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		field, err := json.Marshal(map[string]any{key: raw[key]})
+		if err != nil {
+			continue
+		}
+		if err := json.Unmarshal(field, &settings); err != nil {
+			problems = append(problems, fmt.Sprintf("Ignored the invalid value of %q", key))
+		}
+	}
+
+	settings.Version = settingsVersion
+	problems = append(problems, settings.validate()...)
+	return settings, problems, nil
+}
+
+// clampSetting keeps a value within its range and reports a change
+func clampSetting(problems *[]string, name string, value *float64, lo, hi float64) {
+	clamped := min(max(*value, lo), hi)
+	if clamped != *value {
+		*problems = append(*problems, fmt.Sprintf("%s %g is out of range, using %g", name, *value, clamped))
+		*value = clamped
+	}
+}
+
+// oneOf resets a selection the UI can't show to its default
+func oneOf(problems *[]string, name string, value *string, choices []string, fallback string) {
+	for _, choice := range choices {
+		if *value == choice {
+			return
+		}
+	}
+	*problems = append(*problems, fmt.Sprintf("%s %q is not available, using %s", name, *value, fallback))
+	*value = fallback
+}
+
+// validate repairs values the settings UI can't represent and returns what
+// it changed
+func (d *SettingsData) validate() []string {
+	var problems []string
+	defaults := defaultSettingsData()
+
+	oneOf(&problems, "Theme", &d.Theme, availableThemeNames(), defaults.Theme)
+	oneOf(&problems, "Font size", &d.FontSize, fontSizeNames, defaults.FontSize)
+	oneOf(&problems, "Send key", &d.SendKey, sendKeyNames, defaults.SendKey)
+	clampSetting(&problems, "Animation speed", &d.AnimationSpeed, minAnimationSpeed, maxAnimationSpeed)
+
+	// Generation parameters share their ranges with the profile editors
+	global := d.Parameters()
+	for _, spec := range parameterSpecs {
+		clampSetting(&problems, spec.Name, spec.value(&global), spec.Min, spec.Max)
+	}
+	d.Temperature = global.Temperature
+	d.MaxTokens = global.MaxTokens
+	d.TopP = global.TopP
+	d.TopK = global.TopK
+	d.ContextLength = global.ContextLength
+
+	for model, profile := range d.ModelProfiles {
+		for _, spec := range parameterSpecs {
+			if value := *spec.override(&profile); value != nil {
+				v := *value
+				clampSetting(&problems, fmt.Sprintf("%s of the %s profile", spec.Name, model), &v, spec.Min, spec.Max)
+				*spec.override(&profile) = &v
+			}
+		}
+		if profile.IsEmpty() {
+			delete(d.ModelProfiles, model)
+		} else {
+			d.ModelProfiles[model] = profile
+		}
+	}
+
+	for key, value := range d.Advanced.Values {
+		spec, ok := findOptionSpec(key)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("Removed the unknown option %q", key))
+			delete(d.Advanced.Values, key)
+			continue
+		}
+		clampSetting(&problems, spec.Name, &value, spec.Min, spec.Max)
+		if spec.Integer {
+			value = float64(int64(value))
+		}
+		d.Advanced.Values[key] = value
+	}
+	if _, err := parseKeepAlive(d.Advanced.KeepAlive); err != nil {
+		problems = append(problems, fmt.Sprintf("Keep alive %q is not valid, using the default", d.Advanced.KeepAlive))
+		d.Advanced.KeepAlive = ""
+	}
+
+	servers := make([]MCPServerConfig, 0, len(d.MCPServers))
+	seen := map[string]bool{}
+	for _, server := range d.MCPServers {
+		switch {
+		case server.Name == "" || server.Command == "":
+			problems = append(problems, "Removed an MCP server without a name or command")
+		case seen[server.Name]:
+			problems = append(problems, fmt.Sprintf("Removed the duplicate MCP server %q", server.Name))
+		default:
+			seen[server.Name] = true
+			servers = append(servers, server)
+		}
+	}
+	if len(servers) == 0 {
+		servers = nil
+	}
+	d.MCPServers = servers
+
+	for _, limit := range retentionLimits {
+		value := float64(*limit.value(&d.Retention))
+		clampSetting(&problems, fmt.Sprintf("%s of the history", limit.Name), &value, 0, float64(limit.Max))
+		*limit.value(&d.Retention) = int(value)
+	}
+
+	for id, binding := range d.Shortcuts {
+		action, ok := findShortcutAction(id)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("Removed the shortcut of the unknown action %q", id))
+			delete(d.Shortcuts, id)
+			continue
+		}
+		if binding == "" {
+			continue
+		}
+		shortcut, err := parseShortcut(binding)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("Removed the shortcut of %s: %v", action.Name, err))
+			delete(d.Shortcuts, id)
+			continue
+		}
+		d.Shortcuts[id] = formatShortcut(shortcut.KeyName, shortcut.Modifier)
+	}
+
+	// A shortcut runs one action, later actions lose it
+	used := map[string]string{}
+	resolved := resolveShortcuts(d.Shortcuts)
+	for _, action := range shortcutActions {
+		binding := resolved[action.ID]
+		if binding == "" {
+			continue
+		}
+		if other, ok := used[binding]; ok {
+			problems = append(problems, fmt.Sprintf("%s is used by %s and %s, %s has no shortcut now", binding, other, action.Name, action.Name))
+			if d.Shortcuts == nil {
+				d.Shortcuts = map[string]string{}
+			}
+			d.Shortcuts[action.ID] = ""
+			continue
+		}
+		used[binding] = action.Name
+	}
+	if len(d.Shortcuts) == 0 {
+		d.Shortcuts = nil
+	}
+
+	return problems
+}
+
+// backupSettingsFile keeps a copy of a settings file before it gets
+// replaced. Like the settings, it is readable only by the user.
+func backupSettingsFile(path string) (string, error) {
+	backup := fmt.Sprintf("%s.bak-%s", path, time.Now().Format("20060102-150405"))
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(backup, data, 0600); err != nil {
+		return "", err
+	}
+	return backup, nil
+}
+
+// readSettingsFile loads the saved settings. Files that can't be read, or
+// that were written by a newer version, are backed up before anything
+// overwrites them. problems describes everything that didn't load as saved.
+func readSettingsFile(path string) (SettingsData, []string) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return defaultSettingsData(), nil
+	}
+	if err != nil {
+		return defaultSettingsData(), []string{fmt.Sprintf("Could not read the settings: %v. Using the defaults.", err)}
+	}
+
+	settings, problems, err := decodeSettings(data)
+	if err == nil && versionOf(data) <= settingsVersion {
+		logSettingsProblems(problems)
+		return settings, problems
+	}
+
+	backup, backupErr := backupSettingsFile(path)
+	switch {
+	case backupErr != nil:
+		problems = append(problems, fmt.Sprintf("Could not back up the settings file: %v", backupErr))
+	case err != nil:
+		problems = append(problems, fmt.Sprintf("Could not read the settings (%v). Using the defaults; the old file was saved as %s.", err, backup))
+	default:
+		problems = append(problems, fmt.Sprintf("A copy of the original settings was saved as %s.", backup))
+	}
+	logSettingsProblems(problems)
+	return settings, problems
+}
+
+// logSettingsProblems keeps the load problems in the log
+func logSettingsProblems(problems []string) {
+	for _, problem := range problems {
+		slog.Warn("Settings problem", "problem", problem)
+	}
+}
+
+// versionOf returns the version stored in a settings file, 0 if unknown
+func versionOf(data []byte) int {
+	var header struct {
+		Version int `json:"version"`
+	}
+	json.Unmarshal(data, &header)
+	return header.Version
+}
@@ -0,0 +1,175 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMigrateSettingsV1(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  map[string]any
+		want map[string]any
+	}{
+		{"empty selections", map[string]any{"theme": "", "fontSize": "  ", "model": ""}, map[string]any{"model": ""}},
+		{"theme case", map[string]any{"theme": "dark", "fontSize": "Large"}, map[string]any{"theme": "Dark", "fontSize": "Large"}},
+		{"upper case theme", map[string]any{"theme": "LIGHT"}, map[string]any{"theme": "Light"}},
+		{"unknown theme", map[string]any{"theme": "Solarized"}, map[string]any{"theme": "Solarized"}},
+		{"wrong type", map[string]any{"theme": 3.0}, map[string]any{"theme": 3.0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrateSettingsV1(tt.raw)
+			if !reflect.DeepEqual(tt.raw, tt.want) {
+				t.Errorf("migrated to %v, want %v", tt.raw, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeSettings(t *testing.T) {
+	usePaths(t)
+	defaults := defaultSettingsData()
+
+	tests := []struct {
+		name     string
+		data     string
+		check    func(SettingsData) bool
+		problems []string
+	}{
+		{
+			"v1 file",
+			`{"theme": "dark", "fontSize": "", "autoScroll": false, "temperature": 1}`,
+			func(s SettingsData) bool {
+				return s.Theme == ThemeDark && s.FontSize == defaults.FontSize && !s.AutoScroll && s.Temperature == 1
+			},
+			nil,
+		},
+		{
+			"current file",
+			`{"version": 2, "theme": "Dark", "fontSize": "Large", "animationSpeed": 50}`,
+			func(s SettingsData) bool {
+				return s.Theme == ThemeDark && s.FontSize == "Large" && s.AnimationSpeed == 50
+			},
+			nil,
+		},
+		{
+			"out of range",
+			`{"version": 2, "animationSpeed": 500, "temperature": -1, "topK": 1000}`,
+			func(s SettingsData) bool {
+				return s.AnimationSpeed == maxAnimationSpeed && s.Temperature == 0 && s.TopK == 100
+			},
+			[]string{"Animation speed 500 is out of range", "Temperature -1 is out of range", "Top K 1000 is out of range"},
+		},
+		{
+			"unknown choices",
+			`{"version": 2, "theme": "Solarized", "fontSize": "Huge"}`,
+			func(s SettingsData) bool {
+				return s.Theme == defaults.Theme && s.FontSize == defaults.FontSize
+			},
+			[]string{`Theme "Solarized" is not available`, `Font size "Huge" is not available`},
+		},
+		{
+			"wrong types",
+			`{"version": 2, "theme": "Dark", "temperature": "hot", "autoScroll": "yes", "maxTokens": 1024}`,
+			func(s SettingsData) bool {
+				return s.Theme == ThemeDark && s.Temperature == defaults.Temperature && s.AutoScroll == defaults.AutoScroll && s.MaxTokens == 1024
+			},
+			[]string{`Ignored the invalid value of "autoScroll"`, `Ignored the invalid value of "temperature"`},
+		},
+		{
+			"newer version",
+			`{"version": 3, "theme": "Dark", "newSetting": true}`,
+			func(s SettingsData) bool {
+				return s.Theme == ThemeDark
+			},
+			[]string{"written by a newer version of NeuraTalk (settings version 3)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings, problems, err := decodeSettings([]byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if settings.Version != settingsVersion {
+				t.Errorf("version = %d, want %d", settings.Version, settingsVersion)
+			}
+			if !tt.check(settings) {
+				t.Errorf("settings = %+v", settings)
+			}
+			if len(problems) != len(tt.problems) {
+				t.Fatalf("problems = %q, want %q", problems, tt.problems)
+			}
+			for i, want := range tt.problems {
+				if !strings.Contains(problems[i], want) {
+					t.Errorf("problem %d = %q, want %q", i, problems[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeSettingsErrors(t *testing.T) {
+	for _, data := range []string{"", "{", "null", `["theme"]`, `"Dark"`} {
+		settings, _, err := decodeSettings([]byte(data))
+		if err == nil {
+			t.Errorf("decodeSettings(%q) succeeded, want an error", data)
+		}
+		if !reflect.DeepEqual(settings, defaultSettingsData()) {
+			t.Errorf("decodeSettings(%q) did not fall back to the defaults", data)
+		}
+	}
+}
+
+func TestReadSettingsFileBacksUpNewerFiles(t *testing.T) {
+	usePaths(t)
+	path := filepath.Join(t.TempDir(), "settings.json")
+	writeTestFile(t, path, `{"version": 3, "theme": "Dark"}`)
+
+	settings, problems := readSettingsFile(path)
+	if settings.Theme != ThemeDark {
+		t.Errorf("theme = %q, want %q", settings.Theme, ThemeDark)
+	}
+	backups, _ := filepath.Glob(path + ".bak-*")
+	if len(backups) != 1 {
+		t.Fatalf("backups = %v, want one", backups)
+	}
+	data, err := os.ReadFile(backups[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"version": 3, "theme": "Dark"}` {
+		t.Errorf("backup = %q", data)
+	}
+	info, err := os.Stat(backups[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("backup has mode %o, want 600", mode)
+	}
+	if !strings.Contains(problems[len(problems)-1], backups[0]) {
+		t.Errorf("problems %q don't name the backup", problems)
+	}
+}
+
+func TestSavedSettingsAreOwnerOnly(t *testing.T) {
+	s := newTestSettings(t)
+	// An earlier version wrote the settings readable by everyone
+	writeTestFile(t, settingsFilePath(), `{"version": 2}`)
+	writeTestFile(t, settingsFilePath()+".tmp", "")
+
+	s.saveSettings()
+
+	info, err := os.Stat(settingsFilePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("settings have mode %o, want 600", mode)
+	}
+}
@@ -0,0 +1,144 @@
+package internal
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// GetShortcuts returns the shortcut of every action, "" for none
+func (s *Settings) GetShortcuts() map[string]string {
+	return resolveShortcuts(s.shortcuts)
+}
+
+// setShortcut binds an action to a shortcut, or unbinds it with "", and
+// notifies listeners
+func (s *Settings) setShortcut(action ShortcutAction, binding string) error {
+	for id, other := range s.GetShortcuts() {
+		if binding != "" && id != action.ID && other == binding {
+			owner, _ := findShortcutAction(id)
+			return fmt.Errorf("%s is already used by %s", binding, owner.Name)
+		}
+	}
+
+	shortcuts := make(map[string]string, len(s.shortcuts)+1)
+	for id, b := range s.shortcuts {
+		shortcuts[id] = b
+	}
+	if binding == action.Default {
+		delete(shortcuts, action.ID)
+	} else {
+		shortcuts[action.ID] = binding
+	}
+	if len(shortcuts) == 0 {
+		shortcuts = nil
+	}
+	s.shortcuts = shortcuts
+
+	s.saveSettings()
+	s.refreshShortcuts()
+	if s.OnShortcutsChanged != nil {
+		s.OnShortcutsChanged()
+	}
+	return nil
+}
+
+// refreshShortcuts redraws the list of shortcuts
+func (s *Settings) refreshShortcuts() {
+	s.ShortcutList.RemoveAll()
+	shortcuts := s.GetShortcuts()
+	for _, a := range shortcutActions {
+		action := a
+		binding := shortcuts[action.ID]
+		label := binding
+		if label == "" {
+			label = "None"
+		}
+		change := widget.NewButton(label, func() {
+			s.showShortcutRecorder(action)
+		})
+		clear := widget.NewButtonWithIcon("", theme.ContentClearIcon(), func() {
+			if err := s.setShortcut(action, ""); err != nil {
+				ShowError(err, s.Window)
+			}
+		})
+		if binding == "" {
+			clear.Disable()
+		}
+		s.ShortcutList.Add(container.NewBorder(nil, nil, nil,
+			container.NewHBox(change, clear), widget.NewLabel(action.Name)))
+	}
+	s.ShortcutList.Refresh()
+}
+
+// newShortcutEditor creates the list of rebindable shortcuts
+func (s *Settings) newShortcutEditor() fyne.CanvasObject {
+	s.ShortcutList = container.NewVBox()
+	s.refreshShortcuts()
+	help := widget.NewLabel("Click a shortcut and press the new keys. Ctrl+K lists every action.")
+	help.Wrapping = fyne.TextWrapWord
+	return container.NewVBox(help, s.ShortcutList)
+}
+
+// showShortcutRecorder waits for the user to press a new shortcut for an
+// action
+func (s *Settings) showShortcutRecorder(action ShortcutAction) {
+	recorder := newShortcutRecorder()
+	d := dialog.NewCustomConfirm("Shortcut for "+action.Name, "Save", "Cancel", recorder, func(save bool) {
+		if !save || recorder.binding == "" {
+			return
+		}
+		if err := s.setShortcut(action, recorder.binding); err != nil {
+			ShowError(fmt.Errorf("Failed to change shortcut: %v", err), s.Window)
+		}
+	}, s.Window)
+	d.Resize(fyne.NewSize(360, 0))
+	d.Show()
+	s.Window.Canvas().Focus(recorder)
+}
+
+// shortcutRecorder shows the shortcut the user presses while it has focus
+type shortcutRecorder struct {
+	widget.Label
+	binding string
+}
+
+func newShortcutRecorder() *shortcutRecorder {
+	r := &shortcutRecorder{}
+	r.Text = "Press the new shortcut..."
+	r.Alignment = fyne.TextAlignCenter
+	r.ExtendBaseWidget(r)
+	return r
+}
+
+// TypedShortcut records the pressed shortcut if it can be used
+func (r *shortcutRecorder) TypedShortcut(shortcut fyne.Shortcut) {
+	pressed, ok := shortcut.(fyne.KeyboardShortcut)
+	if !ok {
+		return
+	}
+	binding := formatShortcut(pressed.Key(), pressed.Mod())
+	if _, err := parseShortcut(binding); err != nil {
+		r.binding = ""
+		r.SetText(fmt.Sprintf("%s can't be used, try another", binding))
+		return
+	}
+	r.binding = binding
+	r.SetText(binding)
+}
+
+// TypedKey explains that a shortcut needs a modifier
+func (r *shortcutRecorder) TypedKey(*fyne.KeyEvent) {
+	r.binding = ""
+	r.SetText("Hold Ctrl, Alt or Super with the key")
+}
+
+func (r *shortcutRecorder) TypedRune(rune) {}
+
+func (r *shortcutRecorder) FocusGained() {}
+
+func (r *shortcutRecorder) FocusLost() {}
@@ -0,0 +1,84 @@
+package internal
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// codePreview is the sample code of the theme preview, one word per color
+// of the code palette
+var codePreview = []struct {
+	Text  string
+	Color fyne.ThemeColorName
+}{
+	{"func ", ColorNameCodeKeyword},
+	{"greet", ColorNameCodeFunction},
+	{"(n ", ColorNameCodeType},
+	{"int) ", ColorNameCodeType},
+	{"\"hi\" ", ColorNameCodeString},
+	{"42 ", ColorNameCodeNumber},
+	{"// note", ColorNameCodeComment},
+}
+
+// newThemePreviewContent draws sample chat widgets with the colors of a theme
+func newThemePreviewContent(th fyne.Theme) fyne.CanvasObject {
+	variant := fyne.CurrentApp().Settings().ThemeVariant()
+
+	message := newMessageLabel("You: How do I greet someone in Go?")
+
+	code := container.NewHBox()
+	for _, word := range codePreview {
+		text := canvas.NewText(word.Text, th.Color(word.Color, variant))
+		text.TextStyle = fyne.TextStyle{Monospace: true}
+		text.TextSize = th.Size(theme.SizeNameText)
+		code.Add(text)
+	}
+	codeBackground := canvas.NewRectangle(th.Color(theme.ColorNameInputBackground, variant))
+
+	input := widget.NewEntry()
+	input.SetPlaceHolder("Type your message...")
+	send := widget.NewButton("Send", nil)
+	send.Importance = widget.HighImportance
+
+	background := canvas.NewRectangle(th.Color(theme.ColorNameBackground, variant))
+	return container.NewStack(background, container.NewPadded(container.NewVBox(
+		message,
+		container.NewStack(codeBackground, container.NewPadded(code)),
+		container.NewBorder(nil, nil, nil, send, input),
+	)))
+}
+
+// refreshThemePreview shows the selected theme in the preview
+func (s *Settings) refreshThemePreview() {
+	if s.themePreview == nil {
+		return
+	}
+	th := s.selectedTheme()
+	s.themePreview.Theme = th
+	s.themePreview.Content = newThemePreviewContent(th)
+	s.themePreview.Refresh()
+}
+
+// newThemeTools creates the theme preview and the reload of theme files
+func (s *Settings) newThemeTools() fyne.CanvasObject {
+	th := s.selectedTheme()
+	s.themePreview = container.NewThemeOverride(newThemePreviewContent(th), th)
+
+	reload := widget.NewButtonWithIcon("Reload Themes", theme.ViewRefreshIcon(), func() {
+		s.reloadThemes()
+		s.applyTheme()
+	})
+	help := widget.NewLabel("Theme files (.json) are read from " + themesDirectory())
+	help.Wrapping = fyne.TextWrapWord
+	help.Importance = widget.LowImportance
+
+	return container.NewVBox(
+		widget.NewLabel("Preview"),
+		s.themePreview,
+		container.NewBorder(nil, nil, nil, reload, help),
+		s.ThemeErrors,
+	)
+}
@@ -0,0 +1,262 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// settingsSection is a part of the settings panel that can be reset on its own
+type settingsSection struct {
+	Name string
+	// reset copies the defaults of the section into the settings
+	reset func(d *SettingsData, defaults SettingsData)
+}
+
+// settingsSections are the resettable sections, in the order of the panel
+var settingsSections = []settingsSection{
+	{"Appearance", func(d *SettingsData, defaults SettingsData) {
+		d.Theme = defaults.Theme
+		d.FontSize = defaults.FontSize
+		d.AutoScroll = defaults.AutoScroll
+		d.AnimationSpeed = defaults.AnimationSpeed
+	}},
+	{"Input", func(d *SettingsData, defaults SettingsData) {
+		d.SendKey = defaults.SendKey
+	}},
+	// The chosen model stays, it depends on what is installed
+	{"LLM Settings", func(d *SettingsData, defaults SettingsData) {
+		d.Temperature = defaults.Temperature
+		d.MaxTokens = defaults.MaxTokens
+		d.TopP = defaults.TopP
+		d.TopK = defaults.TopK
+		d.ContextLength = defaults.ContextLength
+		d.SummarizeContext = defaults.SummarizeContext
+		d.AutoTitle = defaults.AutoTitle
+	}},
+	{"Model Profiles", func(d *SettingsData, defaults SettingsData) {
+		d.ModelProfiles = defaults.ModelProfiles
+	}},
+	{"Advanced Options", func(d *SettingsData, defaults SettingsData) {
+		d.Advanced = defaults.Advanced
+	}},
+	{"Tools", func(d *SettingsData, defaults SettingsData) {
+		d.ToolsEnabled = defaults.ToolsEnabled
+		d.ToolsDirectory = defaults.ToolsDirectory
+	}},
+	{"MCP Servers", func(d *SettingsData, defaults SettingsData) {
+		d.MCPServers = defaults.MCPServers
+	}},
+	{"Keyboard Shortcuts", func(d *SettingsData, defaults SettingsData) {
+		d.Shortcuts = defaults.Shortcuts
+	}},
+	{"History", func(d *SettingsData, defaults SettingsData) {
+		d.Retention = defaults.Retention
+	}},
+}
+
+// writeSettings writes settings in the format of the settings file
+func writeSettings(w io.Writer, settings SettingsData) error {
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal settings: %v", err)
+	}
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write settings: %v", err)
+	}
+	return nil
+}
+
+// flattenSettings lists every value of the settings by its JSON path,
+// e.g. "advanced.values.seed"
+func flattenSettings(settings SettingsData) map[string]string {
+	data, _ := json.Marshal(settings)
+	var raw any
+	json.Unmarshal(data, &raw)
+
+	values := map[string]string{}
+	var walk func(prefix string, value any)
+	walk = func(prefix string, value any) {
+		if object, ok := value.(map[string]any); ok {
+			for key, v := range object {
+				if prefix != "" {
+					key = prefix + "." + key
+				}
+				walk(key, v)
+			}
+			return
+		}
+		text, _ := json.Marshal(value)
+		values[prefix] = string(text)
+	}
+	walk("", raw)
+	delete(values, "version")
+	return values
+}
+
+// diffSettings describes what changes when the settings current are
+// replaced by next, one line per changed value
+func diffSettings(current, next SettingsData) []string {
+	before := flattenSettings(current)
+	after := flattenSettings(next)
+
+	keys := map[string]bool{}
+	for key := range before {
+		keys[key] = true
+	}
+	for key := range after {
+		keys[key] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	var changes []string
+	for _, key := range sorted {
+		old, hadOld := before[key]
+		value, hasNew := after[key]
+		switch {
+		case !hadOld || old == "null":
+			if hasNew && value != "null" {
+				changes = append(changes, fmt.Sprintf("+ %s: %s", key, value))
+			}
+		case !hasNew || value == "null":
+			changes = append(changes, fmt.Sprintf("- %s: %s", key, old))
+		case old != value:
+			changes = append(changes, fmt.Sprintf("~ %s: %s → %s", key, old, value))
+		}
+	}
+	return changes
+}
+
+// replaceSettings shows and saves a complete set of settings
+func (s *Settings) replaceSettings(settings SettingsData) {
+	s.applySettings(settings)
+	s.saveSettings()
+	s.refreshProfileList()
+	if s.profileEditor != nil {
+		s.profileEditor.SetOverrides(s.ModelProfile(strings.TrimSpace(s.ProfileModel.Text)))
+	}
+	if s.OnMCPServersChanged != nil {
+		s.OnMCPServersChanged(s.GetMCPServers())
+	}
+	if s.OnShortcutsChanged != nil {
+		s.OnShortcutsChanged()
+	}
+}
+
+// exportSettings saves all settings to a file chosen by the user
+func (s *Settings) exportSettings() {
+	save := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			ShowError(fmt.Errorf("Failed to export settings: %v", err), s.Window)
+			return
+		}
+		if writer == nil {
+			return
+		}
+		defer writer.Close()
+
+		if err := writeSettings(writer, s.data()); err != nil {
+			ShowError(fmt.Errorf("Failed to export settings: %v", err), s.Window)
+		}
+	}, s.Window)
+	save.SetFileName("neuratalk-settings.json")
+	save.Show()
+}
+
+// importSettings reads a settings file chosen by the user and shows what
+// would change before applying it
+func (s *Settings) importSettings() {
+	dialog.ShowFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			ShowError(fmt.Errorf("Failed to open settings: %v", err), s.Window)
+			return
+		}
+		if reader == nil {
+			return
+		}
+		defer reader.Close()
+
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			ShowError(fmt.Errorf("Failed to read settings: %v", err), s.Window)
+			return
+		}
+		imported, problems, err := decodeSettings(data)
+		if err != nil {
+			ShowError(fmt.Errorf("Failed to import settings: %v", err), s.Window)
+			return
+		}
+		if versionOf(data) > settingsVersion {
+			ShowError(fmt.Errorf("Failed to import settings: they were written by a newer version of NeuraTalk"), s.Window)
+			return
+		}
+		s.showImportPreview(imported, problems)
+	}, s.Window)
+}
+
+// showImportPreview lists the changes of an import and applies them when
+// the user confirms
+func (s *Settings) showImportPreview(imported SettingsData, problems []string) {
+	changes := diffSettings(s.data(), imported)
+	if len(changes) == 0 {
+		dialog.ShowInformation("Import Settings", "The imported settings are the same as the current ones.", s.Window)
+		return
+	}
+
+	text := strings.Join(changes, "\n")
+	if len(problems) > 0 {
+		text += "\n\nRepaired while importing:\n• " + strings.Join(problems, "\n• ")
+	}
+	preview := widget.NewLabel(text)
+	preview.Wrapping = fyne.TextWrapWord
+
+	heading := widget.NewLabel(fmt.Sprintf("%d settings will change:", len(changes)))
+	content := container.NewBorder(heading, nil, nil, nil, container.NewVScroll(preview))
+
+	confirm := dialog.NewCustomConfirm("Import Settings", "Apply", "Cancel", content, func(apply bool) {
+		if apply {
+			s.replaceSettings(imported)
+		}
+	}, s.Window)
+	confirm.Resize(fyne.NewSize(600, 400))
+	confirm.Show()
+}
+
+// resetSection asks before restoring the defaults of a section
+func (s *Settings) resetSection(section settingsSection) {
+	dialog.ShowConfirm("Reset "+section.Name, fmt.Sprintf("Restore the default %s?", section.Name), func(reset bool) {
+		if !reset {
+			return
+		}
+		settings := s.data()
+		section.reset(&settings, defaultSettingsData())
+		s.replaceSettings(settings)
+	}, s.Window)
+}
+
+// sectionHeader creates the title of a section with its reset button
+func (s *Settings) sectionHeader(name string) fyne.CanvasObject {
+	title := widget.NewLabelWithStyle(name, fyne.TextAlignCenter, fyne.TextStyle{Bold: true})
+	for _, section := range settingsSections {
+		if section.Name == name {
+			section := section
+			reset := widget.NewButtonWithIcon("", theme.ViewRefreshIcon(), func() {
+				s.resetSection(section)
+			})
+			return container.NewBorder(nil, nil, nil, reset, title)
+		}
+	}
+	return title
+}
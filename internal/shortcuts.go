@@ -0,0 +1,290 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Actions that can be run from the keyboard and the command palette
+const (
+	ActionNewChat        = "newChat"
+	ActionCloseTab       = "closeTab"
+	ActionNextTab        = "nextTab"
+	ActionPreviousTab    = "previousTab"
+	ActionFocusInput     = "focusInput"
+	ActionStopGeneration = "stopGeneration"
+	ActionSearch         = "search"
+	ActionOpenSettings   = "openSettings"
+	ActionCommandPalette = "commandPalette"
+	ActionLastChat       = "lastChat"
+	ActionHome           = "home"
+	ActionCompare        = "compare"
+	ActionArena          = "arena"
+	ActionBatch          = "batch"
+	ActionStatistics     = "statistics"
+	ActionDashboard      = "dashboard"
+	ActionDiagnostics    = "diagnostics"
+)
+
+// ShortcutAction is something the user can do from the keyboard
+type ShortcutAction struct {
+	ID   string
+	Name string
+	// Default is the shortcut used until the user picks another, empty for none
+	Default string
+}
+
+// shortcutActions are all actions, in the order the settings and the
+// command palette list them
+var shortcutActions = []ShortcutAction{
+	{ActionCommandPalette, "Command Palette", "Ctrl+K"},
+	{ActionNewChat, "New Chat", "Ctrl+N"},
+	{ActionCloseTab, "Close Tab", "Ctrl+W"},
+	{ActionNextTab, "Next Tab", "Ctrl+Tab"},
+	{ActionPreviousTab, "Previous Tab", "Ctrl+Shift+Tab"},
+	{ActionFocusInput, "Focus Message Input", "Ctrl+L"},
+	{ActionStopGeneration, "Stop Generating", "Ctrl+."},
+	{ActionSearch, "Search Conversations", "Ctrl+F"},
+	{ActionOpenSettings, "Open Settings", "Ctrl+,"},
+	{ActionLastChat, "Last Chat", ""},
+	{ActionHome, "Home", ""},
+	{ActionCompare, "Compare Models", ""},
+	{ActionArena, "Arena", ""},
+	{ActionBatch, "Batch Run", ""},
+	{ActionStatistics, "Statistics", ""},
+	{ActionDashboard, "Dashboard", ""},
+	{ActionDiagnostics, "Diagnostics", ""},
+}
+
+// findShortcutAction returns the action with the given ID
+func findShortcutAction(id string) (ShortcutAction, bool) {
+	for _, action := range shortcutActions {
+		if action.ID == id {
+			return action, true
+		}
+	}
+	return ShortcutAction{}, false
+}
+
+// shortcutModifiers are the modifier names, in the order they are written
+var shortcutModifiers = []struct {
+	Name     string
+	Modifier fyne.KeyModifier
+}{
+	{"Ctrl", fyne.KeyModifierControl},
+	{"Alt", fyne.KeyModifierAlt},
+	{"Shift", fyne.KeyModifierShift},
+	{"Super", fyne.KeyModifierSuper},
+}
+
+// editingKeys are the Ctrl shortcuts text fields use for editing. The
+// window never sees them as shortcuts of its own.
+var editingKeys = []fyne.KeyName{fyne.KeyZ, fyne.KeyY, fyne.KeyV, fyne.KeyC, fyne.KeyX, fyne.KeyA}
+
+// formatShortcut writes a shortcut the way the settings store it
+func formatShortcut(key fyne.KeyName, modifier fyne.KeyModifier) string {
+	var parts []string
+	for _, m := range shortcutModifiers {
+		if modifier&m.Modifier != 0 {
+			parts = append(parts, m.Name)
+		}
+	}
+	return strings.Join(append(parts, string(key)), "+")
+}
+
+// parseShortcut reads a shortcut such as "Ctrl+Shift+N". Shortcuts need
+// Ctrl, Alt or Super and can't take over the editing shortcuts.
+func parseShortcut(text string) (*desktop.CustomShortcut, error) {
+	parts := strings.Split(strings.TrimSpace(text), "+")
+	key := strings.TrimSpace(parts[len(parts)-1])
+	if key == "" {
+		return nil, fmt.Errorf("shortcut %q has no key", text)
+	}
+	// Letters are upper case, named keys such as Tab start with a capital
+	if len([]rune(key)) == 1 {
+		key = strings.ToUpper(key)
+	} else {
+		key = strings.ToUpper(key[:1]) + key[1:]
+	}
+
+	var modifier fyne.KeyModifier
+	for _, part := range parts[:len(parts)-1] {
+		found := false
+		for _, m := range shortcutModifiers {
+			if strings.EqualFold(strings.TrimSpace(part), m.Name) {
+				modifier |= m.Modifier
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("shortcut %q has the unknown modifier %q", text, part)
+		}
+	}
+
+	if modifier&(fyne.KeyModifierControl|fyne.KeyModifierAlt|fyne.KeyModifierSuper) == 0 {
+		return nil, fmt.Errorf("shortcut %q needs Ctrl, Alt or Super", text)
+	}
+	if modifier == fyne.KeyModifierControl {
+		for _, editing := range editingKeys {
+			if fyne.KeyName(key) == editing {
+				return nil, fmt.Errorf("shortcut %q is used for editing text", text)
+			}
+		}
+	}
+	return &desktop.CustomShortcut{KeyName: fyne.KeyName(key), Modifier: modifier}, nil
+}
+
+// resolveShortcuts returns the shortcut of every action, taking the user's
+// choices over the defaults. Actions without a shortcut map to "".
+func resolveShortcuts(overrides map[string]string) map[string]string {
+	resolved := make(map[string]string, len(shortcutActions))
+	for _, action := range shortcutActions {
+		resolved[action.ID] = action.Default
+		if binding, ok := overrides[action.ID]; ok {
+			resolved[action.ID] = binding
+		}
+	}
+	return resolved
+}
+
+// ShortcutManager runs actions from window shortcuts and the command
+// palette. Actions do nothing until a handler is registered for them.
+type ShortcutManager struct {
+	Window   fyne.Window
+	Settings *Settings
+
+	handlers map[string]func()
+	// bound maps the name of each registered shortcut to its action
+	bound      map[string]string
+	registered []fyne.Shortcut
+}
+
+// NewShortcutManager creates a manager for the shortcuts of a window
+func NewShortcutManager(w fyne.Window, settings *Settings) *ShortcutManager {
+	return &ShortcutManager{
+		Window:   w,
+		Settings: settings,
+		handlers: map[string]func(){},
+		bound:    map[string]string{},
+	}
+}
+
+// Handle sets what an action does
+func (m *ShortcutManager) Handle(action string, run func()) {
+	m.handlers[action] = run
+}
+
+// Run runs an action
+func (m *ShortcutManager) Run(action string) {
+	if run, ok := m.handlers[action]; ok {
+		run()
+	}
+}
+
+// Apply registers the shortcuts chosen in the settings on the window,
+// replacing the ones registered before
+func (m *ShortcutManager) Apply() {
+	canvas := m.Window.Canvas()
+	for _, shortcut := range m.registered {
+		canvas.RemoveShortcut(shortcut)
+	}
+	m.registered = nil
+	m.bound = map[string]string{}
+
+	for _, action := range shortcutActions {
+		shortcut, err := parseShortcut(m.Settings.GetShortcuts()[action.ID])
+		if err != nil {
+			continue
+		}
+		id := action.ID
+		canvas.AddShortcut(shortcut, func(fyne.Shortcut) {
+			m.Run(id)
+		})
+		m.registered = append(m.registered, shortcut)
+		m.bound[shortcut.ShortcutName()] = id
+	}
+}
+
+// Trigger runs the action bound to a shortcut. Widgets that keep the
+// shortcuts they receive while focused hand them on with it. It reports
+// whether an action was bound.
+func (m *ShortcutManager) Trigger(shortcut fyne.Shortcut) bool {
+	action, ok := m.bound[shortcut.ShortcutName()]
+	if !ok {
+		return false
+	}
+	m.Run(action)
+	return true
+}
+
+// ShowPalette lists every action to search and run
+func (m *ShortcutManager) ShowPalette() {
+	var matches []ShortcutAction
+	filter := func(query string) {
+		matches = matches[:0]
+		query = strings.ToLower(strings.TrimSpace(query))
+		for _, action := range shortcutActions {
+			if action.ID == ActionCommandPalette {
+				continue
+			}
+			if strings.Contains(strings.ToLower(action.Name), query) {
+				matches = append(matches, action)
+			}
+		}
+	}
+	filter("")
+
+	var palette dialog.Dialog
+	run := func(action ShortcutAction) {
+		palette.Hide()
+		m.Run(action.ID)
+	}
+
+	list := widget.NewList(
+		func() int {
+			return len(matches)
+		},
+		func() fyne.CanvasObject {
+			shortcut := widget.NewLabel("")
+			shortcut.Importance = widget.LowImportance
+			return container.NewBorder(nil, nil, nil, shortcut, widget.NewLabel(""))
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			if id >= len(matches) {
+				return
+			}
+			row := item.(*fyne.Container)
+			row.Objects[0].(*widget.Label).SetText(matches[id].Name)
+			row.Objects[1].(*widget.Label).SetText(m.Settings.GetShortcuts()[matches[id].ID])
+		},
+	)
+	list.OnSelected = func(id widget.ListItemID) {
+		if id < len(matches) {
+			run(matches[id])
+		}
+	}
+
+	search := widget.NewEntry()
+	search.SetPlaceHolder("Type a command...")
+	search.OnChanged = func(query string) {
+		filter(query)
+		list.UnselectAll()
+		list.Refresh()
+	}
+	search.OnSubmitted = func(string) {
+		if len(matches) > 0 {
+			run(matches[0])
+		}
+	}
+
+	palette = dialog.NewCustom("Command Palette", "Close", container.NewBorder(search, nil, nil, nil, list), m.Window)
+	palette.Resize(fyne.NewSize(420, 420))
+	palette.Show()
+	m.Window.Canvas().Focus(search)
+}
@@ -1,7 +1,13 @@
 package internal
 
 import (
+	"fmt"
+	"image/color"
+	"log/slog"
+	"strings"
+
 	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/theme"
@@ -9,12 +15,35 @@ import (
 )
 
 type Sidebar struct {
-	NewChatButton  *widget.Button
-	LastChatButton *widget.Button
-	OptionsButton  *widget.Button
-	HomeButton     *widget.Button
-	TabContainer   *container.DocTabs
-	MainContent    *fyne.Container
+	NewChatButton     *widget.Button
+	CompareButton     *widget.Button
+	ArenaButton       *widget.Button
+	BatchButton       *widget.Button
+	LastChatButton    *widget.Button
+	OptionsButton     *widget.Button
+	StatsButton       *widget.Button
+	DashboardButton   *widget.Button
+	DiagnosticsButton *widget.Button
+	HomeButton        *widget.Button
+	TabContainer      *container.DocTabs
+	MainContent       *fyne.Container
+	// HistoryTree lists the projects and the saved chats
+	HistoryTree  *widget.Tree
+	ShowArchived *widget.Check
+	// OnOpenChat opens the chat of a model picked from the history
+	OnOpenChat func(model string)
+	// Chats returns the open chats, which are relabelled in place when they
+	// move between projects
+	Chats    func() []*InputOutput
+	Settings *Settings
+	Window   fyne.Window
+
+	history  []chatSummary
+	projects []Project
+	// seenProjects are opened in the tree the first time they are shown
+	seenProjects map[string]bool
+	historyRows  []*historyRow
+	dropTarget   *historyRow
 }
 
 func (s *Sidebar) Sidebar(cont *fyne.Container, settings *fyne.Container) *container.Split {
@@ -91,17 +120,83 @@ func (s *Sidebar) Sidebar(cont *fyne.Container, settings *fyne.Container) *conta
 		s.TabContainer.Select(optionsTab)
 	})
 
+	// Create statistics button
+	s.StatsButton = widget.NewButtonWithIcon("Statistics", theme.InfoIcon(), func() {
+		// Check if statistics tab already exists
+		for _, tab := range s.TabContainer.Items {
+			if tab.Text == "Statistics" {
+				s.TabContainer.Select(tab)
+				return
+			}
+		}
+		// Create new statistics tab
+		statsTab := container.NewTabItemWithIcon("Statistics", theme.InfoIcon(), NewStatsView())
+		s.TabContainer.Append(statsTab)
+		s.TabContainer.Select(statsTab)
+	})
+
+	// Create dashboard button
+	s.DashboardButton = widget.NewButtonWithIcon("Dashboard", theme.GridIcon(), func() {
+		// Check if dashboard tab already exists
+		for _, tab := range s.TabContainer.Items {
+			if tab.Text == "Dashboard" {
+				s.TabContainer.Select(tab)
+				return
+			}
+		}
+		// Create new dashboard tab
+		dashboardTab := container.NewTabItemWithIcon("Dashboard", theme.GridIcon(), NewDashboardView().GetContainer())
+		s.TabContainer.Append(dashboardTab)
+		s.TabContainer.Select(dashboardTab)
+	})
+
+	// Create diagnostics button
+	s.DiagnosticsButton = widget.NewButtonWithIcon("Diagnostics", theme.ComputerIcon(), func() {
+		// Check if diagnostics tab already exists
+		for _, tab := range s.TabContainer.Items {
+			if tab.Text == "Diagnostics" {
+				s.TabContainer.Select(tab)
+				return
+			}
+		}
+		// Create new diagnostics tab
+		diagnosticsTab := container.NewTabItemWithIcon("Diagnostics", theme.ComputerIcon(), NewDiagnosticsView(s.Settings, s.Window).GetContainer())
+		s.TabContainer.Append(diagnosticsTab)
+		s.TabContainer.Select(diagnosticsTab)
+	})
+
 	// Create sidebar content
 	topContent := container.NewVBox(
 		s.HomeButton,
 		widget.NewSeparator(),
 		s.NewChatButton,
+		s.CompareButton,
+		s.ArenaButton,
+		s.BatchButton,
 		widget.NewSeparator(),
 		s.OptionsButton,
+		s.StatsButton,
+		s.DashboardButton,
+		s.DiagnosticsButton,
+	)
+
+	// The projects and saved chats fill the rest of the sidebar
+	newProject := widget.NewButtonWithIcon("", theme.FolderNewIcon(), func() {
+		s.showProjectEditor(nil)
+	})
+	newProject.Importance = widget.LowImportance
+	historyHeader := container.NewBorder(nil, nil,
+		widget.NewLabelWithStyle("History", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		container.NewHBox(s.newShowArchived(), newProject))
+	history := container.NewBorder(
+		container.NewVBox(topContent, widget.NewSeparator(), historyHeader),
+		nil, nil, nil,
+		s.newHistoryTree(),
 	)
+	s.RefreshHistory()
 
 	// Add padding around the buttons
-	paddedContent := container.NewPadded(topContent)
+	paddedContent := container.NewPadded(history)
 
 	// Create a split container with resizable sidebar
 	split := container.NewHSplit(
@@ -115,3 +210,346 @@ func (s *Sidebar) Sidebar(cont *fyne.Container, settings *fyne.Container) *conta
 
 	return split
 }
+
+// newShowArchived creates the toggle revealing archived chats
+func (s *Sidebar) newShowArchived() *widget.Check {
+	s.ShowArchived = widget.NewCheck("Archived", func(bool) {
+		s.RefreshHistory()
+	})
+	return s.ShowArchived
+}
+
+// newHistoryTree creates the tree of projects and saved chats. Projects
+// are branches holding their chats, chats without a project follow them.
+func (s *Sidebar) newHistoryTree() *widget.Tree {
+	s.HistoryTree = widget.NewTree(
+		func(id widget.TreeNodeID) []widget.TreeNodeID {
+			var children []widget.TreeNodeID
+			if id == "" {
+				for _, p := range s.projects {
+					children = append(children, projectNodeID(p.Name))
+				}
+			}
+			project, isProject := projectOfNode(id)
+			if id != "" && !isProject {
+				return nil
+			}
+			for _, chat := range s.history {
+				if s.projectOfChat(chat) == project {
+					children = append(children, chatNodeID(chat.Model))
+				}
+			}
+			return children
+		},
+		func(id widget.TreeNodeID) bool {
+			_, isProject := projectOfNode(id)
+			return id == "" || isProject
+		},
+		func(bool) fyne.CanvasObject {
+			row := newHistoryRow(s)
+			s.historyRows = append(s.historyRows, row)
+			return row
+		},
+		func(id widget.TreeNodeID, _ bool, item fyne.CanvasObject) {
+			item.(*historyRow).show(id)
+		},
+	)
+	return s.HistoryTree
+}
+
+// RefreshHistory reads the projects and saved chats again
+func (s *Sidebar) RefreshHistory() {
+	if s.HistoryTree == nil {
+		return
+	}
+	projects, err := loadProjects()
+	if err != nil {
+		slog.Error("Failed to load projects", "err", err)
+	}
+	s.projects = projects
+	s.history = listChats(s.ShowArchived != nil && s.ShowArchived.Checked)
+
+	// New projects start out open
+	if s.seenProjects == nil {
+		s.seenProjects = map[string]bool{}
+	}
+	for _, p := range s.projects {
+		if !s.seenProjects[p.Name] {
+			s.seenProjects[p.Name] = true
+			s.HistoryTree.OpenBranch(projectNodeID(p.Name))
+		}
+	}
+	s.HistoryTree.Refresh()
+}
+
+// projectOfChat returns the project a chat is listed under, empty when it
+// has none or its project no longer exists
+func (s *Sidebar) projectOfChat(chat chatSummary) string {
+	if _, ok := findProject(s.projects, chat.Info.Project); ok {
+		return chat.Info.Project
+	}
+	return ""
+}
+
+// chatOfModel returns the listed chat of a model
+func (s *Sidebar) chatOfModel(model string) (chatSummary, bool) {
+	for _, chat := range s.history {
+		if chat.Model == model {
+			return chat, true
+		}
+	}
+	return chatSummary{}, false
+}
+
+// moveChat moves the chat of a model into a project, or out of any project
+// with an empty name. Open chats are relabelled so they don't save the old
+// project again.
+func (s *Sidebar) moveChat(model string, project string) {
+	moved := false
+	if s.Chats != nil {
+		for _, io := range s.Chats() {
+			if io.SelectedModel == model {
+				info := io.Info
+				info.Project = project
+				io.setInfo(info)
+				moved = true
+			}
+		}
+	}
+	if !moved {
+		if err := setStoredProject(model, project); err != nil {
+			ShowError(fmt.Errorf("Failed to move conversation: %v", err), s.Window)
+		}
+	}
+	s.RefreshHistory()
+}
+
+// dragChat marks the row a dragged chat would be dropped on
+func (s *Sidebar) dragChat(position fyne.Position) {
+	target, _, _ := s.dropTargetAt(position)
+	if target == s.dropTarget {
+		return
+	}
+	if s.dropTarget != nil {
+		s.dropTarget.setHighlighted(false)
+	}
+	s.dropTarget = target
+	if target != nil {
+		target.setHighlighted(true)
+	}
+}
+
+// dropChat moves a dragged chat into the project it was dropped on.
+// Dropping it on the tree outside any project takes it out of its project.
+func (s *Sidebar) dropChat(model string, position fyne.Position) {
+	if s.dropTarget != nil {
+		s.dropTarget.setHighlighted(false)
+		s.dropTarget = nil
+	}
+	_, project, ok := s.dropTargetAt(position)
+	if !ok {
+		return
+	}
+	chat, found := s.chatOfModel(model)
+	if !found || s.projectOfChat(chat) == project {
+		return
+	}
+	s.moveChat(model, project)
+}
+
+// dropTargetAt finds the row under a position of the window and the project
+// a chat dropped there would join. It reports false outside the tree.
+func (s *Sidebar) dropTargetAt(position fyne.Position) (*historyRow, string, bool) {
+	driver := fyne.CurrentApp().Driver()
+	if !containsPosition(driver.AbsolutePositionForObject(s.HistoryTree), s.HistoryTree.Size(), position) {
+		return nil, "", false
+	}
+	for _, row := range s.historyRows {
+		if row.id == "" || !row.Visible() {
+			continue
+		}
+		if !containsPosition(driver.AbsolutePositionForObject(row), row.Size(), position) {
+			continue
+		}
+		if project, isProject := projectOfNode(row.id); isProject {
+			return row, project, true
+		}
+		if chat, ok := s.chatOfModel(strings.TrimPrefix(row.id, chatNodePrefix)); ok {
+			return row, s.projectOfChat(chat), true
+		}
+	}
+	return nil, "", true
+}
+
+// containsPosition reports whether a position lies within an area
+func containsPosition(origin fyne.Position, size fyne.Size, position fyne.Position) bool {
+	return position.X >= origin.X && position.X < origin.X+size.Width &&
+		position.Y >= origin.Y && position.Y < origin.Y+size.Height
+}
+
+// Node IDs of the history tree
+const (
+	projectNodePrefix = "project:"
+	chatNodePrefix    = "chat:"
+)
+
+func projectNodeID(name string) widget.TreeNodeID {
+	return projectNodePrefix + name
+}
+
+func chatNodeID(model string) widget.TreeNodeID {
+	return chatNodePrefix + model
+}
+
+// projectOfNode returns the project a node of the tree stands for
+func projectOfNode(id widget.TreeNodeID) (string, bool) {
+	if !strings.HasPrefix(id, projectNodePrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(id, projectNodePrefix), true
+}
+
+// historyRow shows a project or a chat in the history tree. Chats can be
+// dragged onto a project to move them there.
+type historyRow struct {
+	widget.BaseWidget
+	sidebar *Sidebar
+	id      widget.TreeNodeID
+
+	background *canvas.Rectangle
+	dot        *canvas.Circle
+	dotBox     *fyne.Container
+	icon       *widget.Icon
+	title      *widget.Label
+	details    *widget.Label
+	// dragPosition is where a dragged chat currently is in the window
+	dragPosition fyne.Position
+}
+
+func newHistoryRow(s *Sidebar) *historyRow {
+	row := &historyRow{
+		sidebar:    s,
+		background: canvas.NewRectangle(color.Transparent),
+		dot:        canvas.NewCircle(color.Transparent),
+		icon:       widget.NewIcon(theme.FolderIcon()),
+		title:      widget.NewLabel(""),
+		details:    widget.NewLabel(""),
+	}
+	row.dotBox = container.NewCenter(container.NewGridWrap(fyne.NewSize(10, 10), row.dot))
+	row.title.Truncation = fyne.TextTruncateEllipsis
+	row.details.Truncation = fyne.TextTruncateEllipsis
+	row.details.Importance = widget.LowImportance
+	row.ExtendBaseWidget(row)
+	return row
+}
+
+func (r *historyRow) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(container.NewStack(
+		r.background,
+		container.NewBorder(nil, nil, container.NewStack(r.dotBox, r.icon), nil,
+			container.NewVBox(r.title, r.details)),
+	))
+}
+
+// show fills the row with the project or chat of a node
+func (r *historyRow) show(id widget.TreeNodeID) {
+	r.id = id
+	s := r.sidebar
+
+	if project, isProject := projectOfNode(id); isProject {
+		r.icon.Show()
+		r.dotBox.Hide()
+		r.title.TextStyle = fyne.TextStyle{Bold: true}
+		r.title.SetText(project)
+
+		chats := 0
+		for _, chat := range s.history {
+			if s.projectOfChat(chat) == project {
+				chats++
+			}
+		}
+		details := []string{fmt.Sprintf("%d chats", chats)}
+		if p, ok := findProject(s.projects, project); ok && p.Model != "" {
+			details = append(details, p.Model)
+		}
+		r.details.SetText(strings.Join(details, " · "))
+		return
+	}
+
+	r.icon.Hide()
+	r.dotBox.Show()
+	chat, ok := s.chatOfModel(strings.TrimPrefix(id, chatNodePrefix))
+	if !ok {
+		return
+	}
+	r.dot.FillColor = colorTagColor(chat.Info.Color)
+	r.dot.Refresh()
+
+	r.title.TextStyle = fyne.TextStyle{Bold: chat.Info.Pinned, Italic: chat.Info.Archived}
+	r.title.SetText(chat.Label())
+
+	var details []string
+	if chat.Info.Pinned {
+		details = append(details, "Pinned")
+	}
+	if chat.Info.Archived {
+		details = append(details, "Archived")
+	}
+	if chat.Info.Title != "" {
+		details = append(details, chat.Model)
+	}
+	r.details.SetText(strings.Join(details, " · "))
+}
+
+// setHighlighted marks the row as the target of a dragged chat
+func (r *historyRow) setHighlighted(highlighted bool) {
+	r.background.FillColor = color.Transparent
+	if highlighted {
+		r.background.FillColor = theme.Color(theme.ColorNameSelection)
+	}
+	r.background.Refresh()
+}
+
+// Tapped opens a chat, or opens and closes a project
+func (r *historyRow) Tapped(*fyne.PointEvent) {
+	s := r.sidebar
+	if _, isProject := projectOfNode(r.id); isProject {
+		s.HistoryTree.ToggleBranch(r.id)
+		return
+	}
+	if s.OnOpenChat != nil && r.id != "" {
+		s.OnOpenChat(strings.TrimPrefix(r.id, chatNodePrefix))
+	}
+}
+
+// TappedSecondary shows what can be done with a project or chat
+func (r *historyRow) TappedSecondary(e *fyne.PointEvent) {
+	s := r.sidebar
+	var menu *fyne.Menu
+	if project, isProject := projectOfNode(r.id); isProject {
+		menu = s.projectMenu(project)
+	} else if chat, ok := s.chatOfModel(strings.TrimPrefix(r.id, chatNodePrefix)); ok {
+		menu = s.chatMenu(chat)
+	}
+	if menu == nil || s.Window == nil {
+		return
+	}
+	widget.ShowPopUpMenuAtPosition(menu, s.Window.Canvas(), e.AbsolutePosition)
+}
+
+// Dragged follows a chat being dragged to a project
+func (r *historyRow) Dragged(e *fyne.DragEvent) {
+	if !strings.HasPrefix(r.id, chatNodePrefix) {
+		return
+	}
+	r.dragPosition = e.AbsolutePosition
+	r.sidebar.dragChat(e.AbsolutePosition)
+}
+
+// DragEnd drops a dragged chat
+func (r *historyRow) DragEnd() {
+	if !strings.HasPrefix(r.id, chatNodePrefix) {
+		return
+	}
+	r.sidebar.dropChat(strings.TrimPrefix(r.id, chatNodePrefix), r.dragPosition)
+}
@@ -0,0 +1,329 @@
+package internal
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// noProject is the choice for conversations outside any project
+const noProject = "None"
+
+// defaultProjectModel is the model choice of projects that use the model
+// picked in the settings
+const defaultProjectModel = "Default"
+
+// projectMenu lists what can be done with a project
+func (s *Sidebar) projectMenu(name string) *fyne.Menu {
+	project, ok := findProject(s.projects, name)
+	if !ok {
+		return nil
+	}
+	return fyne.NewMenu("",
+		fyne.NewMenuItem("New Chat", func() {
+			s.newProjectChat(project)
+		}),
+		fyne.NewMenuItem("Edit Project...", func() {
+			s.showProjectEditor(&project)
+		}),
+		fyne.NewMenuItemSeparator(),
+		fyne.NewMenuItem("Delete Project", func() {
+			s.deleteProject(project)
+		}),
+	)
+}
+
+// chatMenu lists what can be done with a chat of the history
+func (s *Sidebar) chatMenu(chat chatSummary) *fyne.Menu {
+	moveTo := fyne.NewMenuItem("Move to Project", nil)
+	moveTo.ChildMenu = fyne.NewMenu("")
+	current := s.projectOfChat(chat)
+	for _, p := range s.projects {
+		name := p.Name
+		item := fyne.NewMenuItem(name, func() {
+			s.moveChat(chat.Model, name)
+		})
+		item.Checked = name == current
+		moveTo.ChildMenu.Items = append(moveTo.ChildMenu.Items, item)
+	}
+	none := fyne.NewMenuItem(noProject, func() {
+		s.moveChat(chat.Model, "")
+	})
+	none.Checked = current == ""
+	moveTo.ChildMenu.Items = append(moveTo.ChildMenu.Items, fyne.NewMenuItemSeparator(), none)
+
+	return fyne.NewMenu("",
+		fyne.NewMenuItem("Open", func() {
+			if s.OnOpenChat != nil {
+				s.OnOpenChat(chat.Model)
+			}
+		}),
+		moveTo,
+		fyne.NewMenuItemSeparator(),
+		fyne.NewMenuItem("Delete Everything for This Model...", func() {
+			s.deleteModelHistory(chat.Model)
+		}),
+	)
+}
+
+// deleteModelHistory removes the chat, history and images of a model after
+// asking. Open chats of the model start over.
+func (s *Sidebar) deleteModelHistory(model string) {
+	message := fmt.Sprintf("Delete the chat, every saved conversation and the attached images of %s? This can't be undone.", model)
+	dialog.ShowConfirm("Delete Everything", message, func(ok bool) {
+		if !ok {
+			return
+		}
+		if s.Chats != nil {
+			for _, io := range s.Chats() {
+				if io.SelectedModel == model {
+					io.forgetConversation()
+				}
+			}
+		}
+		if err := DeleteModelHistory(model); err != nil {
+			ShowError(fmt.Errorf("Failed to delete history: %v", err), s.Window)
+		}
+		s.RefreshHistory()
+	}, s.Window)
+}
+
+// newProjectChat opens the chat of the project's model and starts a new
+// conversation in the project
+func (s *Sidebar) newProjectChat(project Project) {
+	model := project.Model
+	if model == "" && s.Settings != nil {
+		model = s.Settings.GetModel()
+	}
+	if model == "" {
+		dialog.ShowInformation("New Chat", "Choose a model for the project or in Options first.", s.Window)
+		return
+	}
+	if s.OnOpenChat == nil || s.Chats == nil {
+		return
+	}
+	s.OnOpenChat(model)
+
+	var chat *InputOutput
+	for _, io := range s.Chats() {
+		if io.SelectedModel == model {
+			chat = io
+		}
+	}
+	if chat == nil {
+		ShowError(fmt.Errorf("Failed to open a chat: model %s is not installed", model), s.Window)
+		return
+	}
+	if len(chat.Messages) == 0 {
+		chat.StartProjectChat(project.Name)
+		return
+	}
+	dialog.ShowConfirm("New Chat",
+		fmt.Sprintf("The running chat with %s is kept in the history and a new one is started in %s. Continue?", model, project.Name),
+		func(ok bool) {
+			if ok {
+				chat.StartProjectChat(project.Name)
+			}
+		}, s.Window)
+}
+
+// moveProjectChats moves every chat of a project to another one, or out of
+// any project with an empty name
+func (s *Sidebar) moveProjectChats(from string, to string) {
+	models := map[string]bool{}
+	for _, chat := range listChats(true) {
+		if chat.Info.Project == from {
+			models[chat.Model] = true
+		}
+	}
+	if s.Chats != nil {
+		for _, io := range s.Chats() {
+			if io.Info.Project == from && io.SelectedModel != "" {
+				models[io.SelectedModel] = true
+			}
+		}
+	}
+	for model := range models {
+		s.moveChat(model, to)
+	}
+}
+
+// deleteProject removes a project after asking. Its chats are kept outside
+// any project.
+func (s *Sidebar) deleteProject(project Project) {
+	message := fmt.Sprintf("Delete the project %s? Its chats are kept without a project.", project.Name)
+	dialog.ShowConfirm("Delete Project", message, func(ok bool) {
+		if !ok {
+			return
+		}
+		projects, err := loadProjects()
+		if err != nil {
+			ShowError(fmt.Errorf("Failed to delete project: %v", err), s.Window)
+			return
+		}
+		kept := projects[:0]
+		for _, p := range projects {
+			if p.Name != project.Name {
+				kept = append(kept, p)
+			}
+		}
+		if err := saveProjects(kept); err != nil {
+			ShowError(fmt.Errorf("Failed to delete project: %v", err), s.Window)
+			return
+		}
+		s.moveProjectChats(project.Name, "")
+		delete(s.seenProjects, project.Name)
+		s.RefreshHistory()
+	}, s.Window)
+}
+
+// showProjectEditor creates a project, or edits it when one is given
+func (s *Sidebar) showProjectEditor(original *Project) {
+	var project Project
+	if original != nil {
+		project = *original
+	}
+
+	nameEntry := widget.NewEntry()
+	nameEntry.SetText(project.Name)
+	nameEntry.SetPlaceHolder("Project name")
+
+	models := []string{defaultProjectModel}
+	if s.Settings != nil {
+		models = append(models, s.Settings.ModelSelect.Options...)
+	}
+	modelSelect := widget.NewSelect(models, nil)
+	modelSelect.SetSelected(defaultProjectModel)
+	if project.Model != "" {
+		modelSelect.SetSelected(project.Model)
+	}
+
+	promptEntry := widget.NewMultiLineEntry()
+	promptEntry.Wrapping = fyne.TextWrapWord
+	promptEntry.SetMinRowsVisible(5)
+	promptEntry.SetPlaceHolder("Instructions sent at the start of every chat of the project")
+	promptEntry.SetText(project.SystemPrompt)
+
+	// Knowledge files are listed with a button to remove each
+	files := append([]string(nil), project.Files...)
+	fileList := container.NewVBox()
+	var refreshFiles func()
+	refreshFiles = func() {
+		fileList.RemoveAll()
+		if len(files) == 0 {
+			empty := widget.NewLabel("No files")
+			empty.Importance = widget.LowImportance
+			fileList.Add(empty)
+		}
+		for i, path := range files {
+			label := widget.NewLabel(filepath.Base(path))
+			label.Truncation = fyne.TextTruncateEllipsis
+			index := i
+			remove := widget.NewButtonWithIcon("", theme.DeleteIcon(), func() {
+				files = append(files[:index], files[index+1:]...)
+				refreshFiles()
+			})
+			fileList.Add(container.NewBorder(nil, nil, nil, remove, label))
+		}
+	}
+	refreshFiles()
+
+	addFile := widget.NewButtonWithIcon("Add File...", theme.ContentAddIcon(), func() {
+		dialog.ShowFileOpen(func(reader fyne.URIReadCloser, err error) {
+			if err != nil {
+				ShowError(err, s.Window)
+				return
+			}
+			if reader == nil {
+				return
+			}
+			defer reader.Close()
+			path := reader.URI().Path()
+			if _, err := readKnowledgeFile(path); err != nil {
+				ShowError(fmt.Errorf("Failed to add knowledge file: %v", err), s.Window)
+				return
+			}
+			files = append(files, path)
+			refreshFiles()
+		}, s.Window)
+	})
+
+	form := widget.NewForm(
+		widget.NewFormItem("Name", nameEntry),
+		widget.NewFormItem("Model", modelSelect),
+		widget.NewFormItem("System Prompt", promptEntry),
+		widget.NewFormItem("Knowledge", container.NewVBox(fileList, container.NewHBox(addFile))),
+	)
+
+	title := "New Project"
+	if original != nil {
+		title = "Edit Project"
+	}
+	d := dialog.NewCustomConfirm(title, "Save", "Cancel", form, func(save bool) {
+		if !save {
+			return
+		}
+		edited := Project{
+			Name:         strings.TrimSpace(nameEntry.Text),
+			SystemPrompt: strings.TrimSpace(promptEntry.Text),
+			Files:        files,
+		}
+		if modelSelect.Selected != defaultProjectModel {
+			edited.Model = modelSelect.Selected
+		}
+		if err := s.saveProject(original, edited); err != nil {
+			ShowError(fmt.Errorf("Failed to save project: %v", err), s.Window)
+		}
+	}, s.Window)
+	d.Resize(fyne.NewSize(520, 0))
+	d.Show()
+}
+
+// saveProject stores a new or edited project. Chats follow a renamed project.
+func (s *Sidebar) saveProject(original *Project, edited Project) error {
+	if edited.Name == "" {
+		return fmt.Errorf("the project needs a name")
+	}
+	projects, err := loadProjects()
+	if err != nil {
+		return err
+	}
+
+	replaced := false
+	for i, p := range projects {
+		if original != nil && p.Name == original.Name {
+			projects[i] = edited
+			replaced = true
+		} else if p.Name == edited.Name {
+			return fmt.Errorf("a project named %s already exists", edited.Name)
+		}
+	}
+	if !replaced {
+		projects = append(projects, edited)
+	}
+	if err := saveProjects(projects); err != nil {
+		return err
+	}
+
+	if original != nil && original.Name != edited.Name {
+		delete(s.seenProjects, original.Name)
+		s.seenProjects[edited.Name] = true
+		s.HistoryTree.OpenBranch(projectNodeID(edited.Name))
+		s.moveProjectChats(original.Name, edited.Name)
+	}
+	s.RefreshHistory()
+
+	// The system prompt and files count towards the context of open chats
+	if s.Chats != nil {
+		for _, io := range s.Chats() {
+			io.updateContextMeter()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// GenerationStats describes how the backend generated a reply
+type GenerationStats struct {
+	PromptTokens     int           `json:"promptTokens"`
+	CompletionTokens int           `json:"completionTokens"`
+	TimeToFirstToken time.Duration `json:"timeToFirstToken"`
+	TotalDuration    time.Duration `json:"totalDuration"`
+	LoadDuration     time.Duration `json:"loadDuration,omitempty"`
+	PromptDuration   time.Duration `json:"promptDuration,omitempty"`
+	EvalDuration     time.Duration `json:"evalDuration"`
+}
+
+// newGenerationStats takes the statistics from the final chunk of a reply
+func newGenerationStats(reply *ChatResponse) *GenerationStats {
+	return &GenerationStats{
+		PromptTokens:     reply.PromptEvalCount,
+		CompletionTokens: reply.EvalCount,
+		TimeToFirstToken: reply.TimeToFirstToken,
+		TotalDuration:    time.Duration(reply.TotalDuration),
+		LoadDuration:     time.Duration(reply.LoadDuration),
+		PromptDuration:   time.Duration(reply.PromptEvalDuration),
+		EvalDuration:     time.Duration(reply.EvalDuration),
+	}
+}
+
+// TokensPerSecond is the generation speed of the reply
+func (s GenerationStats) TokensPerSecond() float64 {
+	if s.EvalDuration <= 0 {
+		return 0
+	}
+	return float64(s.CompletionTokens) / s.EvalDuration.Seconds()
+}
+
+// String formats the statistics for the footer of a message
+func (s GenerationStats) String() string {
+	return fmt.Sprintf("%d prompt + %d completion tokens · %.1f tok/s · first token %s · total %s",
+		s.PromptTokens, s.CompletionTokens, s.TokensPerSecond(),
+		formatStatDuration(s.TimeToFirstToken), formatStatDuration(s.TotalDuration))
+}
+
+// formatStatDuration rounds a duration so it stays readable
+func formatStatDuration(d time.Duration) string {
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(10 * time.Millisecond).String()
+}
+
+// newStatsFooter creates the small line shown under a reply
+func newStatsFooter(stats GenerationStats) fyne.CanvasObject {
+	footer := widget.NewLabelWithStyle(stats.String(), fyne.TextAlignTrailing, fyne.TextStyle{Italic: true})
+	footer.Importance = widget.LowImportance
+	footer.Truncation = fyne.TextTruncateEllipsis
+	return footer
+}
+
+// ModelStats sums up the replies of a model
+type ModelStats struct {
+	Model            string
+	Replies          int
+	PromptTokens     int
+	CompletionTokens int
+	TimeToFirstToken time.Duration
+	TotalDuration    time.Duration
+	EvalDuration     time.Duration
+}
+
+// AverageTimeToFirstToken is the mean wait until the model starts answering
+func (s ModelStats) AverageTimeToFirstToken() time.Duration {
+	if s.Replies == 0 {
+		return 0
+	}
+	return s.TimeToFirstToken / time.Duration(s.Replies)
+}
+
+// AverageDuration is the mean time a reply took
+func (s ModelStats) AverageDuration() time.Duration {
+	if s.Replies == 0 {
+		return 0
+	}
+	return s.TotalDuration / time.Duration(s.Replies)
+}
+
+// TokensPerSecond is the generation speed over all replies
+func (s ModelStats) TokensPerSecond() float64 {
+	if s.EvalDuration <= 0 {
+		return 0
+	}
+	return float64(s.CompletionTokens) / s.EvalDuration.Seconds()
+}
+
+// collectModelStats adds up the statistics stored with the replies. The
+// history keeps a copy of a chat after every reply, so replies are counted
+// once per model and time.
+func collectModelStats(conversations []storedConversation) []ModelStats {
+	byModel := map[string]*ModelStats{}
+	seen := map[string]bool{}
+	for _, stored := range conversations {
+		for _, m := range stored.Conversation.Messages {
+			if m.Stats == nil {
+				continue
+			}
+			key := stored.Model + "\x00" + m.Time.UTC().Format(time.RFC3339Nano)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			stats, ok := byModel[stored.Model]
+			if !ok {
+				stats = &ModelStats{Model: stored.Model}
+				byModel[stored.Model] = stats
+			}
+			stats.Replies++
+			stats.PromptTokens += m.Stats.PromptTokens
+			stats.CompletionTokens += m.Stats.CompletionTokens
+			stats.TimeToFirstToken += m.Stats.TimeToFirstToken
+			stats.TotalDuration += m.Stats.TotalDuration
+			stats.EvalDuration += m.Stats.EvalDuration
+		}
+	}
+
+	result := make([]ModelStats, 0, len(byModel))
+	for _, stats := range byModel {
+		result = append(result, *stats)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Model < result[j].Model })
+	return result
+}
+
+// statsColumns are the headers of the model statistics table
+var statsColumns = []string{"Model", "Replies", "Tokens/s", "First Token", "Avg. Duration", "Prompt Tokens", "Completion Tokens"}
+
+// statsCell formats a column of the model statistics table
+func statsCell(stats ModelStats, column int) string {
+	switch column {
+	case 0:
+		return stats.Model
+	case 1:
+		return fmt.Sprint(stats.Replies)
+	case 2:
+		return fmt.Sprintf("%.1f", stats.TokensPerSecond())
+	case 3:
+		return formatStatDuration(stats.AverageTimeToFirstToken())
+	case 4:
+		return formatStatDuration(stats.AverageDuration())
+	case 5:
+		return fmt.Sprint(stats.PromptTokens)
+	case 6:
+		return fmt.Sprint(stats.CompletionTokens)
+	}
+	return ""
+}
+
+// NewStatsView creates the view comparing the speed of the models
+func NewStatsView() fyne.CanvasObject {
+	var rows []ModelStats
+
+	table := widget.NewTableWithHeaders(
+		func() (int, int) { return len(rows), len(statsColumns) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(id widget.TableCellID, cell fyne.CanvasObject) {
+			cell.(*widget.Label).SetText(statsCell(rows[id.Row], id.Col))
+		},
+	)
+	table.ShowHeaderColumn = false
+	table.CreateHeader = func() fyne.CanvasObject {
+		return widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	}
+	table.UpdateHeader = func(id widget.TableCellID, header fyne.CanvasObject) {
+		if id.Col >= 0 {
+			header.(*widget.Label).SetText(statsColumns[id.Col])
+		}
+	}
+	table.SetColumnWidth(0, 220)
+	for col := 1; col < len(statsColumns); col++ {
+		table.SetColumnWidth(col, 130)
+	}
+
+	summary := widget.NewLabel("")
+	refresh := func() {
+		rows = collectModelStats(loadStoredConversations())
+		if len(rows) == 0 {
+			summary.SetText("No statistics yet. They are recorded with every new reply.")
+		} else {
+			replies := 0
+			for _, stats := range rows {
+				replies += stats.Replies
+			}
+			summary.SetText(fmt.Sprintf("%d replies from %d models", replies, len(rows)))
+		}
+		table.Refresh()
+	}
+	refresh()
+
+	refreshButton := widget.NewButtonWithIcon("Refresh", theme.ViewRefreshIcon(), refresh)
+	title := widget.NewLabelWithStyle("Model Statistics", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	header := container.NewBorder(nil, nil, title, refreshButton, container.NewHBox(summary))
+
+	return container.NewBorder(header, nil, nil, nil, table)
+}
@@ -0,0 +1,350 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+func isFileEmpty(filePath string) (bool, error) {
+	fileInfo, err := os.Stat(filePath)
+	if err != nil {
+		return false, err
+	}
+
+	return fileInfo.Size() == 0, nil
+}
+
+// chatsDirectory holds the running chat of every model
+func chatsDirectory() string {
+	return filepath.Join(DataDir(), "chats")
+}
+
+// conversationsDirectory holds the conversation history, a folder per model
+func conversationsDirectory() string {
+	return filepath.Join(DataDir(), "conversations")
+}
+
+func ensureChatsDirectoryExists() {
+	// Create chats directory if it doesn't exist
+	if err := os.MkdirAll(chatsDirectory(), 0700); err != nil {
+		slog.Error("Failed to create chats directory", "dir", chatsDirectory(), "err", err)
+	}
+}
+
+// Add this function to create the conversations directory structure
+func ensureConversationsDirectoryExists() {
+	// Create conversations directory if it doesn't exist
+	if err := os.MkdirAll(conversationsDirectory(), 0700); err != nil {
+		slog.Error("Failed to create conversations directory", "dir", conversationsDirectory(), "err", err)
+	}
+}
+
+// conversationFilePath returns the file holding the running chat of a model
+func conversationFilePath(modelName string) string {
+	return filepath.Join(chatsDirectory(), modelName+".json")
+}
+
+// legacyConversationFilePath returns the plain text file used by older versions
+func legacyConversationFilePath(modelName string) string {
+	return filepath.Join(chatsDirectory(), modelName+".txt")
+}
+
+// parseConversation decodes a stored conversation. Early versions stored
+// a bare list of messages.
+func parseConversation(data []byte) (Conversation, error) {
+	var conversation Conversation
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return conversation, nil
+	}
+
+	if trimmed[0] == '[' {
+		err := json.Unmarshal(trimmed, &conversation.Messages)
+		return conversation, err
+	}
+	err := json.Unmarshal(trimmed, &conversation)
+	return conversation, err
+}
+
+// loadConversation reads the running chat of a model. Chats stored in the
+// old plain text format are converted on the fly.
+func loadConversation(modelName string) (Conversation, error) {
+	data, err := readStoreFile(conversationFilePath(modelName))
+	if err == nil {
+		conversation, err := parseConversation(data)
+		if err != nil {
+			return Conversation{}, fmt.Errorf("failed to parse conversation file: %v", err)
+		}
+		return conversation, nil
+	}
+	if !os.IsNotExist(err) {
+		return Conversation{}, fmt.Errorf("failed to read conversation file: %v", err)
+	}
+
+	legacy, err := readStoreFile(legacyConversationFilePath(modelName))
+	if os.IsNotExist(err) {
+		return Conversation{}, nil
+	}
+	if err != nil {
+		return Conversation{}, fmt.Errorf("failed to read conversation file: %v", err)
+	}
+	return Conversation{Messages: parseLegacyConversation(string(legacy))}, nil
+}
+
+// saveConversation replaces the running chat of a model
+func saveConversation(modelName string, conversation Conversation) error {
+	ensureChatsDirectoryExists()
+
+	if conversation.Messages == nil {
+		conversation.Messages = []Message{}
+	}
+	data, err := json.MarshalIndent(conversation, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal conversation: %v", err)
+	}
+
+	if err := writeStoreFile(conversationFilePath(modelName), data); err != nil {
+		return fmt.Errorf("failed to write conversation file: %v", err)
+	}
+	return nil
+}
+
+// Add this function to save a conversation to the conversations folder. The
+// snapshot replaces the previous one when it continues it.
+func saveConversationToHistory(modelName string, conversation Conversation) error {
+	historyFiles.Lock()
+	defer historyFiles.Unlock()
+
+	// Ensure conversations directory exists
+	ensureConversationsDirectoryExists()
+
+	// Create model directory if it doesn't exist
+	modelDir := filepath.Join(conversationsDirectory(), modelName)
+	if _, err := os.Stat(modelDir); os.IsNotExist(err) {
+		err := os.Mkdir(modelDir, 0700)
+		if err != nil {
+			return fmt.Errorf("failed to create model directory: %v", err)
+		}
+	}
+
+	// Create a timestamped file for this conversation
+	timestamp := time.Now().Format("20060102_150405")
+	fileName := fmt.Sprintf("%s_%s.json", modelName, timestamp)
+	filePath := filepath.Join(modelDir, fileName)
+
+	content, err := json.MarshalIndent(conversation, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal conversation: %v", err)
+	}
+
+	// Write to file
+	err = writeStoreFile(filePath, content)
+	if err != nil {
+		return fmt.Errorf("failed to write conversation file: %v", err)
+	}
+
+	replacePreviousSnapshot(modelDir, filePath, conversation)
+	return nil
+}
+
+// imagesDirectory holds every image attached to a conversation
+func imagesDirectory() string {
+	return filepath.Join(DataDir(), "images")
+}
+
+// imagePath returns the location of a stored image
+func imagePath(name string) string {
+	return filepath.Join(imagesDirectory(), name)
+}
+
+// storeImage copies an image into the image store and returns its name.
+// Images are named after their content so attaching the same file twice
+// only keeps one copy.
+func storeImage(srcPath string) (string, error) {
+	data, err := os.ReadFile(srcPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read image: %v", err)
+	}
+
+	if err := os.MkdirAll(imagesDirectory(), 0700); err != nil {
+		return "", fmt.Errorf("failed to create images directory: %v", err)
+	}
+
+	sum := sha256.Sum256(data)
+	name := hex.EncodeToString(sum[:16]) + strings.ToLower(filepath.Ext(srcPath))
+	dstPath := imagePath(name)
+	if _, err := os.Stat(dstPath); err == nil {
+		return name, nil
+	}
+
+	if err := writeStoreFile(dstPath, data); err != nil {
+		return "", fmt.Errorf("failed to store image: %v", err)
+	}
+	return name, nil
+}
+
+// loadImage reads a stored image
+func loadImage(name string) ([]byte, error) {
+	return readStoreFile(imagePath(name))
+}
+
+// storedConversation is a conversation read back from the store together
+// with the model it belongs to
+type storedConversation struct {
+	Model        string
+	Path         string
+	Conversation Conversation
+}
+
+// loadStoredConversations reads every running chat and every conversation
+// saved to the history. Files that can't be read are skipped.
+func loadStoredConversations() []storedConversation {
+	stored := readStoredConversations(chatsDirectory())
+	return append(stored, readStoredConversations(conversationsDirectory())...)
+}
+
+// readStoredConversations reads the conversations below one directory of
+// the store
+func readStoredConversations(root string) []storedConversation {
+	var stored []storedConversation
+	filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		ext := filepath.Ext(path)
+		if ext != ".json" && ext != ".txt" {
+			return nil
+		}
+		data, err := readStoreFile(path)
+		if err != nil {
+			return nil
+		}
+
+		var conversation Conversation
+		if ext == ".txt" {
+			conversation.Messages = parseLegacyConversation(string(data))
+		} else if conversation, err = parseConversation(data); err != nil {
+			return nil
+		}
+
+		// Running chats are named after the model, history files sit in
+		// a directory named after it
+		rel, _ := filepath.Rel(root, path)
+		model := strings.TrimSuffix(rel, ext)
+		if root == conversationsDirectory() {
+			model = filepath.Dir(rel)
+		}
+		stored = append(stored, storedConversation{
+			Model:        filepath.ToSlash(model),
+			Path:         path,
+			Conversation: conversation,
+		})
+		return nil
+	})
+	return stored
+}
+
+// comparisonsFilePath holds the votes of the comparison tab, one per line
+func comparisonsFilePath() string {
+	return filepath.Join(DataDir(), "comparisons.jsonl")
+}
+
+// jsonLineFiles serializes appends, which rewrite the whole file
+var jsonLineFiles sync.Mutex
+
+// appendJSONLine adds a record to a file holding one JSON value per line.
+// The records hold prompts and answers, so the file is rewritten through
+// the store and encrypted along with the history.
+func appendJSONLine(filePath string, value any) error {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0700); err != nil {
+		return fmt.Errorf("failed to create %s: %v", filepath.Dir(filePath), err)
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %v", err)
+	}
+
+	jsonLineFiles.Lock()
+	defer jsonLineFiles.Unlock()
+
+	existing, err := readStoreFile(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %v", filePath, err)
+	}
+	if len(existing) > 0 && existing[len(existing)-1] != '\n' {
+		existing = append(existing, '\n')
+	}
+	return writeStoreFile(filePath, append(append(existing, data...), '\n'))
+}
+
+// readJSONLines hands every non-empty line of a file to fn. A missing file
+// has no lines.
+func readJSONLines(filePath string, fn func(line []byte)) error {
+	data, err := readStoreFile(filePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", filePath, err)
+	}
+
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) > 0 {
+			fn(line)
+		}
+	}
+	return nil
+}
+
+// appendComparison records a finished comparison
+func appendComparison(comparison Comparison) error {
+	return appendJSONLine(comparisonsFilePath(), comparison)
+}
+
+// loadComparisons reads every recorded comparison. Damaged lines are skipped.
+func loadComparisons() ([]Comparison, error) {
+	var comparisons []Comparison
+	err := readJSONLines(comparisonsFilePath(), func(line []byte) {
+		var comparison Comparison
+		if json.Unmarshal(line, &comparison) == nil {
+			comparisons = append(comparisons, comparison)
+		}
+	})
+	return comparisons, err
+}
+
+// arenaFilePath holds the blind votes of the arena, one per line
+func arenaFilePath() string {
+	return filepath.Join(DataDir(), "arena.jsonl")
+}
+
+// appendArenaMatch records a judged arena match
+func appendArenaMatch(match ArenaMatch) error {
+	return appendJSONLine(arenaFilePath(), match)
+}
+
+// loadArenaMatches reads every arena match. Damaged lines are skipped.
+func loadArenaMatches() ([]ArenaMatch, error) {
+	var matches []ArenaMatch
+	err := readJSONLines(arenaFilePath(), func(line []byte) {
+		var match ArenaMatch
+		if json.Unmarshal(line, &match) == nil {
+			matches = append(matches, match)
+		}
+	})
+	return matches, err
+}
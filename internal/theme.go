@@ -0,0 +1,448 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/theme"
+)
+
+// Names of the themes that are always available
+const (
+	ThemeSystem = "System"
+	ThemeLight  = "Light"
+	ThemeDark   = "Dark"
+)
+
+// Colors of the code highlight palette. Theme files set them in "code".
+const (
+	ColorNameCodeKeyword  fyne.ThemeColorName = "codeKeyword"
+	ColorNameCodeString   fyne.ThemeColorName = "codeString"
+	ColorNameCodeComment  fyne.ThemeColorName = "codeComment"
+	ColorNameCodeNumber   fyne.ThemeColorName = "codeNumber"
+	ColorNameCodeFunction fyne.ThemeColorName = "codeFunction"
+	ColorNameCodeType     fyne.ThemeColorName = "codeType"
+)
+
+// codeColorNames lists the code palette in the order it is previewed
+var codeColorNames = []fyne.ThemeColorName{
+	ColorNameCodeKeyword,
+	ColorNameCodeString,
+	ColorNameCodeComment,
+	ColorNameCodeNumber,
+	ColorNameCodeFunction,
+	ColorNameCodeType,
+}
+
+// fontScales maps the font size setting to a factor for all text sizes
+var fontScales = map[string]float32{
+	"Small":  0.85,
+	"Medium": 1,
+	"Large":  1.25,
+}
+
+// textSizeNames are the theme sizes that belong to text
+var textSizeNames = []fyne.ThemeSizeName{
+	theme.SizeNameText,
+	theme.SizeNameCaptionText,
+	theme.SizeNameHeadingText,
+	theme.SizeNameSubHeadingText,
+	theme.SizeNameInlineIcon,
+}
+
+// ThemeFile is the format of a theme. User themes are JSON files in the
+// themes directory of the config directory.
+type ThemeFile struct {
+	Name string `json:"name"`
+	// Variant is "light" or "dark" and provides everything the file doesn't set
+	Variant string `json:"variant"`
+	// Colors by Fyne color name, e.g. "primary": "#268bd2" for the accent
+	Colors map[string]string `json:"colors,omitempty"`
+	// Sizes by Fyne size name, e.g. "text": 15 or "padding": 6
+	Sizes map[string]float32 `json:"sizes,omitempty"`
+	// Fonts are paths to TTF files, relative to the theme file
+	Fonts ThemeFonts `json:"fonts,omitempty"`
+	// Code is the palette of code blocks: keyword, string, comment, number,
+	// function and type
+	Code map[string]string `json:"code,omitempty"`
+}
+
+// ThemeFonts are the font files of a theme, empty for the default font
+type ThemeFonts struct {
+	Regular    string `json:"regular,omitempty"`
+	Bold       string `json:"bold,omitempty"`
+	Italic     string `json:"italic,omitempty"`
+	BoldItalic string `json:"boldItalic,omitempty"`
+	Monospace  string `json:"monospace,omitempty"`
+}
+
+// themeDefinition is a parsed theme ready to be drawn
+type themeDefinition struct {
+	Name    string
+	variant fyne.ThemeVariant
+	// system follows the light or dark preference of the OS
+	system bool
+	colors map[fyne.ThemeColorName]color.Color
+	sizes  map[fyne.ThemeSizeName]float32
+	fonts  map[fyne.TextStyle]fyne.Resource
+}
+
+// bundledThemes ship with NeuraTalk next to Light and Dark
+var bundledThemes = []ThemeFile{
+	{
+		Name:    "High Contrast",
+		Variant: "dark",
+		Colors: map[string]string{
+			"background":          "#000000",
+			"foreground":          "#ffffff",
+			"primary":             "#ffd700",
+			"foregroundOnPrimary": "#000000",
+			"button":              "#1a1a1a",
+			"disabledButton":      "#0d0d0d",
+			"disabled":            "#9a9a9a",
+			"focus":               "#ffd700",
+			"hover":               "#333333",
+			"pressed":             "#555555",
+			"inputBackground":     "#000000",
+			"inputBorder":         "#ffffff",
+			"menuBackground":      "#000000",
+			"overlayBackground":   "#000000",
+			"headerBackground":    "#1a1a1a",
+			"placeholder":         "#c8c8c8",
+			"selection":           "#0050ff",
+			"separator":           "#ffffff",
+			"scrollBar":           "#ffffff",
+			"hyperlink":           "#00ffff",
+		},
+		Sizes: map[string]float32{"inputBorder": 2},
+		Code: map[string]string{
+			"keyword":  "#ffd700",
+			"string":   "#00ff7f",
+			"comment":  "#c8c8c8",
+			"number":   "#ff80ff",
+			"function": "#00ffff",
+			"type":     "#ffa500",
+		},
+	},
+	{
+		Name:    "Solarized Light",
+		Variant: "light",
+		Colors: map[string]string{
+			"background":        "#fdf6e3",
+			"foreground":        "#586e75",
+			"primary":           "#268bd2",
+			"button":            "#eee8d5",
+			"disabledButton":    "#f5efdc",
+			"disabled":          "#93a1a1",
+			"hover":             "#e4ddc8",
+			"inputBackground":   "#eee8d5",
+			"inputBorder":       "#93a1a1",
+			"menuBackground":    "#fdf6e3",
+			"overlayBackground": "#fdf6e3",
+			"headerBackground":  "#eee8d5",
+			"placeholder":       "#93a1a1",
+			"separator":         "#eee8d5",
+			"hyperlink":         "#2aa198",
+		},
+		Code: map[string]string{
+			"keyword":  "#859900",
+			"string":   "#2aa198",
+			"comment":  "#93a1a1",
+			"number":   "#d33682",
+			"function": "#268bd2",
+			"type":     "#b58900",
+		},
+	},
+	{
+		Name:    "Solarized Dark",
+		Variant: "dark",
+		Colors: map[string]string{
+			"background":        "#002b36",
+			"foreground":        "#93a1a1",
+			"primary":           "#268bd2",
+			"button":            "#073642",
+			"disabledButton":    "#03303b",
+			"disabled":          "#586e75",
+			"hover":             "#0a4452",
+			"inputBackground":   "#073642",
+			"inputBorder":       "#586e75",
+			"menuBackground":    "#002b36",
+			"overlayBackground": "#002b36",
+			"headerBackground":  "#073642",
+			"placeholder":       "#586e75",
+			"separator":         "#073642",
+			"hyperlink":         "#2aa198",
+		},
+		Code: map[string]string{
+			"keyword":  "#859900",
+			"string":   "#2aa198",
+			"comment":  "#586e75",
+			"number":   "#d33682",
+			"function": "#268bd2",
+			"type":     "#b58900",
+		},
+	},
+}
+
+// themesDirectory holds the theme files of the user
+func themesDirectory() string {
+	return filepath.Join(ConfigDir(), "themes")
+}
+
+// parseHexColor reads colors written as #rgb, #rrggbb or #rrggbbaa
+func parseHexColor(text string) (color.Color, error) {
+	hex := strings.TrimPrefix(strings.TrimSpace(text), "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	if len(hex) != 8 {
+		return nil, fmt.Errorf("invalid color %q", text)
+	}
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid color %q", text)
+	}
+	return color.NRGBA{R: uint8(value >> 24), G: uint8(value >> 16), B: uint8(value >> 8), A: uint8(value)}, nil
+}
+
+// parseThemeFile checks a theme and loads its fonts. dir is where font
+// paths are relative to.
+func parseThemeFile(file ThemeFile, dir string) (*themeDefinition, error) {
+	def := &themeDefinition{
+		Name:   strings.TrimSpace(file.Name),
+		colors: map[fyne.ThemeColorName]color.Color{},
+		sizes:  map[fyne.ThemeSizeName]float32{},
+		fonts:  map[fyne.TextStyle]fyne.Resource{},
+	}
+	if def.Name == "" {
+		return nil, fmt.Errorf("theme has no name")
+	}
+
+	switch strings.ToLower(file.Variant) {
+	case "light":
+		def.variant = theme.VariantLight
+	case "dark":
+		def.variant = theme.VariantDark
+	default:
+		return nil, fmt.Errorf("theme %q: variant must be light or dark", def.Name)
+	}
+
+	for name, value := range file.Colors {
+		c, err := parseHexColor(value)
+		if err != nil {
+			return nil, fmt.Errorf("theme %q: color %s: %v", def.Name, name, err)
+		}
+		def.colors[fyne.ThemeColorName(name)] = c
+	}
+	for name, value := range file.Code {
+		if name == "" {
+			continue
+		}
+		c, err := parseHexColor(value)
+		if err != nil {
+			return nil, fmt.Errorf("theme %q: code color %s: %v", def.Name, name, err)
+		}
+		def.colors[fyne.ThemeColorName("code"+strings.ToUpper(name[:1])+name[1:])] = c
+	}
+	for name, value := range file.Sizes {
+		if value < 0 || value > 100 {
+			return nil, fmt.Errorf("theme %q: size %s must be between 0 and 100", def.Name, name)
+		}
+		def.sizes[fyne.ThemeSizeName(name)] = value
+	}
+
+	fonts := map[fyne.TextStyle]string{
+		{}:                         file.Fonts.Regular,
+		{Bold: true}:               file.Fonts.Bold,
+		{Italic: true}:             file.Fonts.Italic,
+		{Bold: true, Italic: true}: file.Fonts.BoldItalic,
+		{Monospace: true}:          file.Fonts.Monospace,
+	}
+	for style, path := range fonts {
+		if path == "" {
+			continue
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		font, err := fyne.LoadResourceFromPath(path)
+		if err != nil {
+			return nil, fmt.Errorf("theme %q: failed to load font: %v", def.Name, err)
+		}
+		def.fonts[style] = font
+	}
+	return def, nil
+}
+
+// loadThemes returns every available theme by name: the built-in ones,
+// the bundled ones and the files in the themes directory. Theme files that
+// can't be used are skipped and reported.
+func loadThemes() (map[string]*themeDefinition, []error) {
+	themes := map[string]*themeDefinition{
+		ThemeSystem: {Name: ThemeSystem, system: true},
+		ThemeLight:  {Name: ThemeLight, variant: theme.VariantLight},
+		ThemeDark:   {Name: ThemeDark, variant: theme.VariantDark},
+	}
+	var errs []error
+
+	for _, file := range bundledThemes {
+		def, err := parseThemeFile(file, "")
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		themes[def.Name] = def
+	}
+
+	paths, _ := filepath.Glob(filepath.Join(themesDirectory(), "*.json"))
+	sort.Strings(paths)
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to read %s: %v", filepath.Base(path), err))
+			continue
+		}
+		var file ThemeFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			errs = append(errs, fmt.Errorf("failed to parse %s: %v", filepath.Base(path), err))
+			continue
+		}
+		def, err := parseThemeFile(file, filepath.Dir(path))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", filepath.Base(path), err))
+			continue
+		}
+		if def.Name == ThemeSystem || def.Name == ThemeLight || def.Name == ThemeDark {
+			errs = append(errs, fmt.Errorf("%s: the name %q is taken by a built-in theme", filepath.Base(path), def.Name))
+			continue
+		}
+		themes[def.Name] = def
+	}
+	return themes, errs
+}
+
+// themeNamesOf lists the built-in themes first, then the others by name
+func themeNamesOf(themes map[string]*themeDefinition) []string {
+	names := []string{ThemeSystem, ThemeLight, ThemeDark}
+	var others []string
+	for name := range themes {
+		if name != ThemeSystem && name != ThemeLight && name != ThemeDark {
+			others = append(others, name)
+		}
+	}
+	sort.Strings(others)
+	return append(names, others...)
+}
+
+// availableThemeNames lists the names of all themes that can be selected
+func availableThemeNames() []string {
+	themes, _ := loadThemes()
+	return themeNamesOf(themes)
+}
+
+// appTheme draws a theme definition over Fyne's default theme, with the
+// text scaled by the chosen font size
+type appTheme struct {
+	definition *themeDefinition
+	fontScale  float32
+}
+
+// newAppTheme creates the theme for a theme definition and font size
+func newAppTheme(definition *themeDefinition, fontSize string) fyne.Theme {
+	if definition == nil {
+		definition = &themeDefinition{Name: ThemeSystem, system: true}
+	}
+	scale, ok := fontScales[fontSize]
+	if !ok {
+		scale = 1
+	}
+	return &appTheme{definition: definition, fontScale: scale}
+}
+
+func (t *appTheme) Color(name fyne.ThemeColorName, variant fyne.ThemeVariant) color.Color {
+	if !t.definition.system {
+		variant = t.definition.variant
+	}
+	if c, ok := t.definition.colors[name]; ok {
+		return c
+	}
+	if strings.HasPrefix(string(name), "code") {
+		// Code without a palette uses the text colors
+		if name == ColorNameCodeComment {
+			return theme.DefaultTheme().Color(theme.ColorNamePlaceHolder, variant)
+		}
+		return theme.DefaultTheme().Color(theme.ColorNameForeground, variant)
+	}
+	return theme.DefaultTheme().Color(name, variant)
+}
+
+func (t *appTheme) Font(style fyne.TextStyle) fyne.Resource {
+	if font, ok := t.definition.fonts[fyne.TextStyle{Bold: style.Bold, Italic: style.Italic, Monospace: style.Monospace}]; ok {
+		return font
+	}
+	return theme.DefaultTheme().Font(style)
+}
+
+func (t *appTheme) Icon(name fyne.ThemeIconName) fyne.Resource {
+	return theme.DefaultTheme().Icon(name)
+}
+
+func (t *appTheme) Size(name fyne.ThemeSizeName) float32 {
+	size, ok := t.definition.sizes[name]
+	if !ok {
+		size = theme.DefaultTheme().Size(name)
+	}
+	for _, textSize := range textSizeNames {
+		if name == textSize {
+			return size * t.fontScale
+		}
+	}
+	return size
+}
+
+// selectedTheme creates the theme of the current theme and font size
+// selections
+func (s *Settings) selectedTheme() fyne.Theme {
+	return newAppTheme(s.themes[s.ThemeSelect.Selected], s.FontSizeSelect.Selected)
+}
+
+// applyTheme switches the whole app, including open tabs, to the selected
+// theme and font size
+func (s *Settings) applyTheme() {
+	if s.App == nil {
+		return
+	}
+	s.App.Settings().SetTheme(s.selectedTheme())
+	s.refreshThemePreview()
+}
+
+// reloadThemes reads the theme files again and offers them in the select
+func (s *Settings) reloadThemes() {
+	themes, errs := loadThemes()
+	s.themes = themes
+	s.ThemeSelect.Options = themeNamesOf(themes)
+	s.ThemeSelect.Refresh()
+
+	if s.ThemeErrors != nil {
+		messages := make([]string, len(errs))
+		for i, err := range errs {
+			messages[i] = err.Error()
+		}
+		s.ThemeErrors.SetText(strings.Join(messages, "\n"))
+		if len(errs) == 0 {
+			s.ThemeErrors.Hide()
+		} else {
+			s.ThemeErrors.Show()
+		}
+	}
+}
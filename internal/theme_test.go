@@ -0,0 +1,114 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/test"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// newTestSettings creates settings saved below a temporary directory
+func newTestSettings(t *testing.T) *Settings {
+	t.Helper()
+	usePaths(t)
+	a := test.NewApp()
+	t.Cleanup(a.Quit)
+	return NewSettings(test.NewWindow(nil), a)
+}
+
+func TestFontSizeScalesTheme(t *testing.T) {
+	s := newTestSettings(t)
+	base := theme.DefaultTheme().Size(theme.SizeNameText)
+
+	tests := []struct {
+		fontSize string
+		want     float32
+	}{
+		{"Small", base * 0.85},
+		{"Medium", base},
+		{"Large", base * 1.25},
+	}
+	for _, tt := range tests {
+		s.FontSizeSelect.SetSelected(tt.fontSize)
+		current := s.App.Settings().Theme()
+		if got := current.Size(theme.SizeNameText); got != tt.want {
+			t.Errorf("%s: text size = %v, want %v", tt.fontSize, got, tt.want)
+		}
+		if got, want := current.Size(theme.SizeNamePadding), theme.DefaultTheme().Size(theme.SizeNamePadding); got != want {
+			t.Errorf("%s: padding = %v, want %v unscaled", tt.fontSize, got, want)
+		}
+	}
+}
+
+func TestFontSizeResizesOpenWidgets(t *testing.T) {
+	s := newTestSettings(t)
+	label := widget.NewLabel("Hello")
+	w := test.NewWindow(label)
+	defer w.Close()
+
+	// The test app applies a new theme in the background, so wait for it
+	sizeAfter := func(fontSize string, changed func(fyne.Size) bool) fyne.Size {
+		s.FontSizeSelect.SetSelected(fontSize)
+		deadline := time.Now().Add(2 * time.Second)
+		size := label.MinSize()
+		for !changed(size) && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+			size = label.MinSize()
+		}
+		return size
+	}
+
+	medium := label.MinSize()
+	small := sizeAfter("Small", func(size fyne.Size) bool { return size.Height < medium.Height })
+	large := sizeAfter("Large", func(size fyne.Size) bool { return size.Height > small.Height })
+	if large.Height <= small.Height || large.Width <= small.Width {
+		t.Errorf("label did not grow with the font size: small %v, large %v", small, large)
+	}
+}
+
+func TestAnimationBatchSize(t *testing.T) {
+	tests := []struct {
+		speed float64
+		want  int
+	}{
+		{0, 1},
+		{1, 1},
+		{10, 2},
+		{20, 3},
+		{40, 6},
+		{100, 15},
+	}
+	for _, tt := range tests {
+		if got := animationBatchSize(tt.speed); got != tt.want {
+			t.Errorf("animationBatchSize(%v) = %d, want %d", tt.speed, got, tt.want)
+		}
+	}
+}
+
+func TestAutoScrollFollowsSettings(t *testing.T) {
+	s := newTestSettings(t)
+	io := &InputOutput{Settings: s}
+
+	s.AutoScroll.SetChecked(true)
+	if !io.autoScroll() {
+		t.Error("chat does not scroll with auto-scroll on")
+	}
+	s.AutoScroll.SetChecked(false)
+	if io.autoScroll() {
+		t.Error("chat scrolls with auto-scroll off")
+	}
+
+	// The choice is saved
+	reloaded := NewSettings(s.Window, s.App)
+	if reloaded.IsAutoScrollEnabled() {
+		t.Error("auto-scroll was not saved")
+	}
+
+	// Chats without settings keep following new messages
+	if !(&InputOutput{}).autoScroll() {
+		t.Error("chat without settings does not scroll")
+	}
+}
@@ -0,0 +1,148 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// maxToolRounds limits how often a model may call tools before it has to answer
+const maxToolRounds = 8
+
+// ToolHandler runs a tool with the arguments chosen by the model and returns
+// the text handed back to it
+type ToolHandler func(ctx context.Context, args map[string]any) (string, error)
+
+// Tool is a function models can call during a chat
+type Tool struct {
+	Name        string
+	Description string
+	// Parameters is the JSON schema of the arguments
+	Parameters json.RawMessage
+	Handler    ToolHandler
+	// Source tells where the tool comes from, e.g. "builtin"
+	Source string
+	// RequiresApproval makes the user confirm each call of the tool
+	RequiresApproval bool
+}
+
+// ToolRegistry keeps the tools offered to models
+type ToolRegistry struct {
+	mu       sync.RWMutex
+	tools    map[string]*Tool
+	approved map[string]bool
+}
+
+// NewToolRegistry creates an empty registry
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{
+		tools:    map[string]*Tool{},
+		approved: map[string]bool{},
+	}
+}
+
+// Approve lets a tool run without asking again until the app restarts
+func (r *ToolRegistry) Approve(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.approved[name] = true
+}
+
+// NeedsApproval reports whether the user has to confirm a call of the tool
+func (r *ToolRegistry) NeedsApproval(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tool, ok := r.tools[name]
+	return ok && tool.RequiresApproval && !r.approved[name]
+}
+
+// Register adds a tool. Tool names must be unique.
+func (r *ToolRegistry) Register(tool Tool) error {
+	if tool.Name == "" {
+		return fmt.Errorf("tool name is required")
+	}
+	if tool.Handler == nil {
+		return fmt.Errorf("tool %s has no handler", tool.Name)
+	}
+	if len(tool.Parameters) == 0 {
+		tool.Parameters = json.RawMessage(`{"type":"object","properties":{}}`)
+	}
+	if !json.Valid(tool.Parameters) {
+		return fmt.Errorf("tool %s has an invalid parameter schema", tool.Name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.tools[tool.Name]; exists {
+		return fmt.Errorf("tool %s is already registered", tool.Name)
+	}
+	r.tools[tool.Name] = &tool
+	return nil
+}
+
+// Unregister removes a tool
+func (r *ToolRegistry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.tools, name)
+}
+
+// Get looks up a tool by name
+func (r *ToolRegistry) Get(name string) (*Tool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tool, ok := r.tools[name]
+	return tool, ok
+}
+
+// Tools returns all registered tools sorted by name
+func (r *ToolRegistry) Tools() []*Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tools := make([]*Tool, 0, len(r.tools))
+	for _, tool := range r.tools {
+		tools = append(tools, tool)
+	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name < tools[j].Name })
+	return tools
+}
+
+// Definitions describes every registered tool in the format sent to the model
+func (r *ToolRegistry) Definitions() []ToolDefinition {
+	tools := r.Tools()
+	definitions := make([]ToolDefinition, 0, len(tools))
+	for _, tool := range tools {
+		definitions = append(definitions, ToolDefinition{
+			Type: "function",
+			Function: ToolDefinitionFunc{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+	return definitions
+}
+
+// Call runs the tool requested by the model
+func (r *ToolRegistry) Call(ctx context.Context, call ToolCall) (string, error) {
+	tool, ok := r.Get(call.Function.Name)
+	if !ok {
+		return "", fmt.Errorf("unknown tool %s", call.Function.Name)
+	}
+	args := call.Function.Arguments
+	if args == nil {
+		args = map[string]any{}
+	}
+	return tool.Handler(ctx, args)
+}
+
+// stringArg reads an optional string argument of a tool call
+func stringArg(args map[string]any, name string) string {
+	if value, ok := args[name].(string); ok {
+		return value
+	}
+	return ""
+}
@@ -0,0 +1,81 @@
+package internal
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// imageExtensions lists the image formats that can be attached to a message
+var imageExtensions = []string{".png", ".jpg", ".jpeg", ".gif", ".webp"}
+
+// imageMIMETypes maps image extensions to their MIME type
+var imageMIMETypes = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+}
+
+// isImageFile reports whether the path points to an existing image
+func isImageFile(path string) bool {
+	if _, ok := imageMIMETypes[strings.ToLower(filepath.Ext(path))]; !ok {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// pastedImagePath returns the image path contained in pasted text, if any.
+// File managers paste either a plain path or a file:// URI.
+func pastedImagePath(text string) (string, bool) {
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "file://") {
+		u, err := url.Parse(text)
+		if err != nil {
+			return "", false
+		}
+		text = u.Path
+	}
+	if text == "" || strings.ContainsAny(text, "\n") {
+		return "", false
+	}
+	return text, isImageFile(text)
+}
+
+// newThumbnail creates a fixed size preview of a stored image. Images are
+// read through the store as they may be encrypted.
+func newThumbnail(name string, size float32) *canvas.Image {
+	var img *canvas.Image
+	if data, err := loadImage(name); err == nil {
+		img = canvas.NewImageFromResource(fyne.NewStaticResource(name, data))
+	} else {
+		img = canvas.NewImageFromResource(theme.BrokenImageIcon())
+	}
+	img.FillMode = canvas.ImageFillContain
+	img.SetMinSize(fyne.NewSize(size, size))
+	return img
+}
+
+// newThumbnailRow lays out previews of the given images side by side
+func newThumbnailRow(names []string) *fyne.Container {
+	row := container.NewHBox()
+	for _, name := range names {
+		row.Add(newThumbnail(name, 120))
+	}
+	return row
+}
+
+// newAttachmentPreview shows a pending attachment with a button to remove it
+func newAttachmentPreview(name string, onRemove func()) fyne.CanvasObject {
+	removeButton := widget.NewButton("Remove", onRemove)
+	return container.NewVBox(newThumbnail(name, 64), removeButton)
+}
@@ -1,13 +1,15 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"log/slog"
 	"os"
+	"sort"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
 	"fyne.io/fyne/v2/container"
-	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 	"github.com/temidaradev/NeuraTalk/internal"
@@ -19,95 +21,458 @@ type ChatManager struct {
 	Window    fyne.Window
 	Sidebar   *internal.Sidebar
 	Settings  *internal.Settings
+	Tools     *internal.ToolRegistry
+	MCP       *internal.MCPManager
+	Shortcuts *internal.ShortcutManager
 	LastChat  *internal.InputOutput
+	Compare   *internal.CompareView
+	Arena     *internal.ArenaView
+	Batch     *internal.BatchView
+	models    []string
+	// tabTitles label chats that have no title of their own
+	tabTitles map[*internal.InputOutput]string
 }
 
-func NewChatManager(w fyne.Window, settings *internal.Settings) *ChatManager {
+func NewChatManager(w fyne.Window, settings *internal.Settings, tools *internal.ToolRegistry, mcp *internal.MCPManager, shortcuts *internal.ShortcutManager) *ChatManager {
 	// Get available models
 	models, err := internal.GetAvailableModels()
 	if err != nil {
-		dialog.ShowError(fmt.Errorf("Failed to get available models: %v", err), w)
+		internal.ShowError(fmt.Errorf("Failed to get available models: %v", err), w)
 		return nil
 	}
 
-	// Create first chat instance
-	io := internal.NewInputOutput(models, w, settings)
-
 	manager := &ChatManager{
-		Instances: []*internal.InputOutput{io},
-		Current:   0,
+		Current:   -1,
 		Window:    w,
 		Settings:  settings,
-		LastChat:  io,
+		Tools:     tools,
+		MCP:       mcp,
+		Shortcuts: shortcuts,
+		models:    models,
+		tabTitles: map[*internal.InputOutput]string{},
 	}
 
-	// Create sidebar
-	manager.Sidebar = &internal.Sidebar{}
+	// Create first chat instance, shown once the window is set up
+	manager.LastChat = manager.createChat()
+
+	// Create sidebar, opening chats picked from its history and moving
+	// them between projects
+	manager.Sidebar = &internal.Sidebar{Settings: settings, Window: w}
+	manager.Sidebar.OnOpenChat = manager.openChat
+	manager.Sidebar.Chats = func() []*internal.InputOutput {
+		return manager.Instances
+	}
 
 	// Set up new chat functionality
 	newChatFunc := func() {
-		// Create a new chat instance
-		newIO := internal.NewInputOutput(models, w, settings)
-		manager.Instances = append(manager.Instances, newIO)
-		manager.Current = len(manager.Instances) - 1
-		manager.LastChat = newIO
-
-		// Add new chat tab and switch to it
-		chatTab := container.NewTabItemWithIcon(
-			fmt.Sprintf("Chat %d", len(manager.Instances)),
-			theme.DocumentIcon(),
-			newIO.GetContainer(),
-		)
-		manager.Sidebar.TabContainer.Append(chatTab)
-		manager.Sidebar.TabContainer.Select(chatTab)
-	}
-
-	// Set up last chat functionality
+		manager.newChat("")
+	}
+
+	// Set up last chat functionality, reopening it if its tab was closed
 	lastChatFunc := func() {
 		if manager.LastChat != nil {
-			// Check if the last chat tab already exists
+			manager.SetLastChat(manager.LastChat)
+		}
+	}
+
+	// Set up model comparison, sharing one tab
+	compareFunc := func() {
+		if manager.Compare != nil {
+			for _, tab := range manager.Sidebar.TabContainer.Items {
+				if tab.Content == manager.Compare.GetContainer() {
+					manager.Sidebar.TabContainer.Select(tab)
+					return
+				}
+			}
+		} else {
+			manager.Compare = internal.NewCompareView(models, w, settings)
+		}
+
+		compareTab := container.NewTabItemWithIcon("Compare", theme.ViewRestoreIcon(), manager.Compare.GetContainer())
+		manager.Sidebar.TabContainer.Append(compareTab)
+		manager.Sidebar.TabContainer.Select(compareTab)
+	}
+
+	// Set up the blind arena, sharing one tab
+	arenaFunc := func() {
+		if manager.Arena != nil {
 			for _, tab := range manager.Sidebar.TabContainer.Items {
-				if tab.Content == manager.LastChat.GetContainer() {
+				if tab.Content == manager.Arena.GetContainer() {
 					manager.Sidebar.TabContainer.Select(tab)
 					return
 				}
 			}
+		} else {
+			manager.Arena = internal.NewArenaView(models, w, settings)
+		}
 
-			// Create new tab for last chat
-			chatTab := container.NewTabItemWithIcon(
-				"Last Chat",
-				theme.DocumentIcon(),
-				manager.LastChat.GetContainer(),
-			)
-			manager.Sidebar.TabContainer.Append(chatTab)
-			manager.Sidebar.TabContainer.Select(chatTab)
+		arenaTab := container.NewTabItemWithIcon("Arena", theme.QuestionIcon(), manager.Arena.GetContainer())
+		manager.Sidebar.TabContainer.Append(arenaTab)
+		manager.Sidebar.TabContainer.Select(arenaTab)
+	}
+
+	// Set up batch runs, sharing one tab
+	batchFunc := func() {
+		if manager.Batch != nil {
+			for _, tab := range manager.Sidebar.TabContainer.Items {
+				if tab.Content == manager.Batch.GetContainer() {
+					manager.Sidebar.TabContainer.Select(tab)
+					return
+				}
+			}
+		} else {
+			manager.Batch = internal.NewBatchView(models, w, settings)
 		}
+
+		batchTab := container.NewTabItemWithIcon("Batch", theme.ListIcon(), manager.Batch.GetContainer())
+		manager.Sidebar.TabContainer.Append(batchTab)
+		manager.Sidebar.TabContainer.Select(batchTab)
 	}
 
 	// Create the New Chat button with the functionality
 	manager.Sidebar.NewChatButton = widget.NewButtonWithIcon("New Chat", theme.ContentAddIcon(), newChatFunc)
 	manager.Sidebar.LastChatButton = widget.NewButtonWithIcon("Last Chat", theme.DocumentIcon(), lastChatFunc)
+	manager.Sidebar.CompareButton = widget.NewButtonWithIcon("Compare Models", theme.ViewRestoreIcon(), compareFunc)
+	manager.Sidebar.ArenaButton = widget.NewButtonWithIcon("Arena", theme.QuestionIcon(), arenaFunc)
+	manager.Sidebar.BatchButton = widget.NewButtonWithIcon("Batch Run", theme.ListIcon(), batchFunc)
 
 	return manager
 }
 
-// SetLastChat updates the last chat instance
-func (m *ChatManager) SetLastChat(io *internal.InputOutput) {
-	m.LastChat = io
+// createChat creates a chat instance that reports back to the manager
+func (m *ChatManager) createChat() *internal.InputOutput {
+	io := internal.NewInputOutput(m.models, m.Window, m.Settings, m.Tools)
+	io.MCP = m.MCP
+	io.Shortcuts = m.Shortcuts
+	io.OnCleared = m.SetLastChat
+	return io
+}
+
+// newChat opens a chat in a new tab and switches to it. An empty title
+// numbers the chat.
+func (m *ChatManager) newChat(title string) *internal.InputOutput {
+	newIO := m.createChat()
+	if title == "" {
+		title = fmt.Sprintf("Chat %d", len(m.Instances)+1)
+	}
+	m.addChat(newIO, title)
+	m.LastChat = newIO
+	return newIO
+}
+
+// addChat shows a chat in a new tab and switches to it
+func (m *ChatManager) addChat(io *internal.InputOutput, title string) {
 	m.Instances = append(m.Instances, io)
 	m.Current = len(m.Instances) - 1
+	m.watchChat(io, title)
 
-	// Add new chat tab and switch to it
-	chatTab := container.NewTabItemWithIcon(
-		"Last Chat",
-		theme.DocumentIcon(),
-		io.GetContainer(),
-	)
+	chatTab := container.NewTabItemWithIcon(title, internal.ConversationIcon(io.Info), io.GetContainer())
 	m.Sidebar.TabContainer.Append(chatTab)
 	m.Sidebar.TabContainer.Select(chatTab)
+
+	// A chat that is shown again is no longer archived
+	io.SetArchived(false)
+	m.refreshChatTab(io)
+}
+
+// closeChat saves and archives the chat of a closed tab and lets go of it.
+// The last chat is remembered so the Last Chat button can reopen it.
+func (m *ChatManager) closeChat(io *internal.InputOutput) {
+	m.removeChat(io)
+	io.Close()
+	m.Sidebar.RefreshHistory()
+}
+
+// handleClosedTabs closes the chat of every tab that is closed
+func (m *ChatManager) handleClosedTabs() {
+	m.Sidebar.TabContainer.OnClosed = func(tab *container.TabItem) {
+		if io := m.chatOf(tab); io != nil {
+			m.closeChat(io)
+		}
+	}
+}
+
+// removeChat forgets a chat that no longer has a tab
+func (m *ChatManager) removeChat(io *internal.InputOutput) {
+	for i, instance := range m.Instances {
+		if instance == io {
+			m.Instances = append(m.Instances[:i], m.Instances[i+1:]...)
+			break
+		}
+	}
+	delete(m.tabTitles, io)
+	io.OnConversationChanged = nil
+	m.Current = len(m.Instances) - 1
+}
+
+// Session describes the open tabs and the window for the next start
+func (m *ChatManager) Session() internal.Session {
+	size := m.Window.Canvas().Size()
+	session := internal.Session{Width: size.Width, Height: size.Height}
+
+	tabs := m.Sidebar.TabContainer
+	for _, tab := range tabs.Items {
+		if tab == tabs.Selected() {
+			session.Selected = len(session.Tabs)
+		}
+
+		var saved internal.SessionTab
+		switch {
+		case m.chatOf(tab) != nil:
+			io := m.chatOf(tab)
+			saved = io.SessionTab(m.tabTitles[io])
+		case m.Compare != nil && tab.Content == m.Compare.GetContainer():
+			saved = internal.SessionTab{Kind: internal.TabCompare}
+		case m.Arena != nil && tab.Content == m.Arena.GetContainer():
+			saved = internal.SessionTab{Kind: internal.TabArena}
+		case m.Batch != nil && tab.Content == m.Batch.GetContainer():
+			saved = internal.SessionTab{Kind: internal.TabBatch}
+		case tab.Text == "Home":
+			saved = internal.SessionTab{Kind: internal.TabHome}
+		case tab.Text == "Options":
+			saved = internal.SessionTab{Kind: internal.TabOptions}
+		case tab.Text == "Statistics":
+			saved = internal.SessionTab{Kind: internal.TabStatistics}
+		case tab.Text == "Dashboard":
+			saved = internal.SessionTab{Kind: internal.TabDashboard}
+		case tab.Text == "Diagnostics":
+			saved = internal.SessionTab{Kind: internal.TabDiagnostics}
+		default:
+			continue
+		}
+		session.Tabs = append(session.Tabs, saved)
+	}
+	return session
+}
+
+// RestoreSession reopens the tabs of a saved session in their order and
+// reports whether there was anything to reopen
+func (m *ChatManager) RestoreSession(session internal.Session) bool {
+	if len(session.Tabs) == 0 {
+		return false
+	}
+
+	// The restored tabs replace the default home tab
+	tabs := m.Sidebar.TabContainer
+	tabs.SetItems(nil)
+
+	for _, saved := range session.Tabs {
+		switch saved.Kind {
+		case internal.TabChat:
+			m.newChat(saved.Title).RestoreSessionTab(saved)
+		case internal.TabHome:
+			m.Sidebar.HomeButton.OnTapped()
+		case internal.TabOptions:
+			m.Sidebar.OptionsButton.OnTapped()
+		case internal.TabStatistics:
+			m.Sidebar.StatsButton.OnTapped()
+		case internal.TabDashboard:
+			m.Sidebar.DashboardButton.OnTapped()
+		case internal.TabDiagnostics:
+			m.Sidebar.DiagnosticsButton.OnTapped()
+		case internal.TabCompare:
+			m.Sidebar.CompareButton.OnTapped()
+		case internal.TabArena:
+			m.Sidebar.ArenaButton.OnTapped()
+		case internal.TabBatch:
+			m.Sidebar.BatchButton.OnTapped()
+		}
+	}
+
+	if len(tabs.Items) == 0 {
+		m.Sidebar.HomeButton.OnTapped()
+		return false
+	}
+	if session.Selected >= 0 && session.Selected < len(tabs.Items) {
+		tabs.SelectIndex(session.Selected)
+	}
+	if io := m.chatOf(tabs.Selected()); io != nil {
+		io.ApplyPendingScroll()
+	}
+	return true
+}
+
+// watchChat keeps the tab of a chat and the history in step with it. The
+// title labels the tab until the chat gets a title of its own.
+func (m *ChatManager) watchChat(io *internal.InputOutput, title string) {
+	m.tabTitles[io] = title
+	io.OnConversationChanged = func() {
+		m.refreshChatTab(io)
+	}
+}
+
+// refreshChatTab shows the title and color tag of a chat in its tab, keeps
+// pinned chats in front and updates the history
+func (m *ChatManager) refreshChatTab(io *internal.InputOutput) {
+	tabs := m.Sidebar.TabContainer
+	for _, tab := range tabs.Items {
+		if tab.Content != io.GetContainer() {
+			continue
+		}
+		tab.Text = io.Info.Title
+		if tab.Text == "" {
+			tab.Text = m.tabTitles[io]
+		}
+		tab.Icon = internal.ConversationIcon(io.Info)
+	}
+
+	// Pinned chats move to the front, the others keep their order
+	pinned := func(tab *container.TabItem) bool {
+		chat := m.chatOf(tab)
+		return chat != nil && chat.Info.Pinned
+	}
+	items := append([]*container.TabItem(nil), tabs.Items...)
+	sort.SliceStable(items, func(i, j int) bool {
+		return pinned(items[i]) && !pinned(items[j])
+	})
+	for i := range items {
+		if items[i] != tabs.Items[i] {
+			selected := tabs.Selected()
+			tabs.SetItems(items)
+			tabs.Select(selected)
+			break
+		}
+	}
+	tabs.Refresh()
+
+	m.Sidebar.RefreshHistory()
+}
+
+// openChat shows the chat of a model, opening a tab for it if needed
+func (m *ChatManager) openChat(model string) {
+	tabs := m.Sidebar.TabContainer
+	for _, tab := range tabs.Items {
+		if io := m.chatOf(tab); io != nil && io.SelectedModel == model {
+			tabs.Select(tab)
+			return
+		}
+	}
+	m.newChat("").ModelSelect.SetSelected(model)
+}
+
+// chatOf returns the chat shown in a tab, if any
+func (m *ChatManager) chatOf(tab *container.TabItem) *internal.InputOutput {
+	if tab == nil {
+		return nil
+	}
+	for _, io := range m.Instances {
+		if io.GetContainer() == tab.Content {
+			return io
+		}
+	}
+	return nil
+}
+
+// SetLastChat makes a chat the last chat and shows it, opening a tab for it
+// if it has none
+func (m *ChatManager) SetLastChat(io *internal.InputOutput) {
+	m.LastChat = io
+	for _, tab := range m.Sidebar.TabContainer.Items {
+		if tab.Content == io.GetContainer() {
+			m.Sidebar.TabContainer.Select(tab)
+			return
+		}
+	}
+	m.addChat(io, "Last Chat")
+}
+
+// SelectedChat returns the chat shown in the selected tab, if any
+func (m *ChatManager) SelectedChat() *internal.InputOutput {
+	return m.chatOf(m.Sidebar.TabContainer.Selected())
+}
+
+// closeSelectedTab closes the selected tab as its close button would
+func (m *ChatManager) closeSelectedTab() {
+	tabs := m.Sidebar.TabContainer
+	tab := tabs.Selected()
+	if tab == nil {
+		return
+	}
+	tabs.Remove(tab)
+	if tabs.OnClosed != nil {
+		tabs.OnClosed(tab)
+	}
+}
+
+// selectTab moves the selection by step tabs, wrapping around at the ends
+func (m *ChatManager) selectTab(step int) {
+	tabs := m.Sidebar.TabContainer
+	count := len(tabs.Items)
+	if count == 0 {
+		return
+	}
+	tabs.SelectIndex(((tabs.SelectedIndex()+step)%count + count) % count)
+}
+
+// handleShortcuts sets what each keyboard action does
+func (m *ChatManager) handleShortcuts() {
+	s := m.Shortcuts
+	tapped := func(button *widget.Button) func() {
+		return func() {
+			if button != nil && button.OnTapped != nil {
+				button.OnTapped()
+			}
+		}
+	}
+	s.Handle(internal.ActionCommandPalette, s.ShowPalette)
+	s.Handle(internal.ActionNewChat, tapped(m.Sidebar.NewChatButton))
+	s.Handle(internal.ActionCloseTab, m.closeSelectedTab)
+	s.Handle(internal.ActionNextTab, func() {
+		m.selectTab(1)
+	})
+	s.Handle(internal.ActionPreviousTab, func() {
+		m.selectTab(-1)
+	})
+	s.Handle(internal.ActionFocusInput, func() {
+		if io := m.SelectedChat(); io != nil {
+			io.FocusInput()
+		}
+	})
+	s.Handle(internal.ActionStopGeneration, func() {
+		if io := m.SelectedChat(); io != nil {
+			io.StopGeneration()
+		} else if m.Compare != nil && m.Sidebar.TabContainer.Selected() != nil && m.Sidebar.TabContainer.Selected().Content == m.Compare.GetContainer() {
+			m.Compare.Stop()
+		}
+	})
+	s.Handle(internal.ActionSearch, func() {
+		internal.ShowConversationSearch(m.Window, m.openChat)
+	})
+	s.Handle(internal.ActionOpenSettings, tapped(m.Sidebar.OptionsButton))
+	s.Handle(internal.ActionLastChat, tapped(m.Sidebar.LastChatButton))
+	s.Handle(internal.ActionHome, tapped(m.Sidebar.HomeButton))
+	s.Handle(internal.ActionCompare, tapped(m.Sidebar.CompareButton))
+	s.Handle(internal.ActionArena, tapped(m.Sidebar.ArenaButton))
+	s.Handle(internal.ActionBatch, tapped(m.Sidebar.BatchButton))
+	s.Handle(internal.ActionStatistics, tapped(m.Sidebar.StatsButton))
+	s.Handle(internal.ActionDashboard, tapped(m.Sidebar.DashboardButton))
+	s.Handle(internal.ActionDiagnostics, tapped(m.Sidebar.DiagnosticsButton))
 }
 
 func main() {
+	home := flag.String("home", "", "keep config, data and cache below this directory (or set "+internal.HomeEnv+")")
+	portable := flag.Bool("portable", false, "keep everything next to the executable (or set "+internal.PortableEnv+"=1)")
+	logLevel := flag.String("log-level", "info", "log messages of this level and above: debug, info, warn or error")
+	flag.Parse()
+
+	// Find the config and data directories before anything is loaded
+	if _, err := internal.InitPaths(*home, *portable); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to set up data directories:", err)
+		os.Exit(1)
+	}
+
+	// Log to the data directory, keeping a few rotated files
+	closeLog, err := internal.InitLogging(*logLevel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to set up logging:", err)
+	}
+	defer closeLog()
+
+	// Run prompt datasets from the command line without opening a window
+	if args := flag.Args(); len(args) > 0 && args[0] == "batch" {
+		os.Exit(internal.RunBatchCommand(args[1:], os.Stdout, os.Stderr))
+	}
+
 	a := app.New()
 	w := a.NewWindow("NeuraTalk")
 	w.Resize(fyne.NewSize(800, 600))
@@ -120,32 +485,111 @@ func main() {
 	// Create settings
 	settings := internal.NewSettings(w, a)
 
-	// Create chat manager with settings
-	manager := NewChatManager(w, settings)
-
-	// Get list of available models
-	names, err := internal.GetAvailableModels()
-	if err != nil {
-		dialog.ShowError(fmt.Errorf("Failed to get available models: %v", err), w)
-		os.Exit(1)
+	// Create the tools models may call
+	tools := internal.NewToolRegistry()
+	if err := internal.RegisterBuiltinTools(tools, settings); err != nil {
+		internal.ShowError(fmt.Errorf("Failed to register tools: %v", err), w)
 	}
 
-	fmt.Println("Available Models:", names)
+	// Start the MCP servers declared in the settings and follow changes
+	mcp := internal.NewMCPManager(tools)
+	mcp.OnStatusChanged = settings.RefreshMCPServers
+	settings.MCPStatus = mcp.Status
+	settings.OnMCPServersChanged = mcp.Apply
+	mcp.Apply(settings.GetMCPServers())
+	defer mcp.Close()
+
+	// Apply the retention policy to the history in the background
+	janitor := internal.NewHistoryJanitor(settings.GetRetention)
+	defer janitor.Close()
+
+	// Build the window once the conversation history can be read
+	start := func() {
+		janitor.Start()
+
+		// Create chat manager with settings
+		shortcuts := internal.NewShortcutManager(w, settings)
+		manager := NewChatManager(w, settings, tools, mcp, shortcuts)
+
+		// Get list of available models
+		names, err := internal.GetAvailableModels()
+		if err != nil {
+			internal.ShowError(fmt.Errorf("Failed to get available models: %v", err), w)
+			os.Exit(1)
+		}
+
+		slog.Info("Found models", "models", names)
+		settings.SetAvailableModels(names)
 
-	// Create initial UI
-	split := manager.Sidebar.Sidebar(nil, settings.GetContainer())
-	w.SetContent(split)
+		// Create initial UI
+		split := manager.Sidebar.Sidebar(nil, settings.GetContainer())
+		w.SetContent(split)
+
+		// Images dropped onto the window are attached to the selected chat
+		w.SetOnDropped(func(_ fyne.Position, uris []fyne.URI) {
+			io := manager.SelectedChat()
+			if io == nil {
+				return
+			}
+			for _, uri := range uris {
+				io.AttachImage(uri.Path())
+			}
+		})
+
+		// Restored chats scroll back once their tab is shown
+		manager.Sidebar.TabContainer.OnSelected = func(tab *container.TabItem) {
+			if io := manager.chatOf(tab); io != nil {
+				io.ApplyPendingScroll()
+			}
+		}
+
+		// Reopen the tabs of the last session, or start with the last chat
+		session, err := internal.LoadSession()
+		if err != nil {
+			internal.ShowError(fmt.Errorf("Failed to restore the last session: %v", err), w)
+		}
+		if session.Width > 0 && session.Height > 0 {
+			w.Resize(fyne.NewSize(session.Width, session.Height))
+		}
+		if !manager.RestoreSession(session) && manager.LastChat != nil {
+			// Create a new tab for the last chat and continue with the
+			// conversation of the saved model
+			manager.SetLastChat(manager.LastChat)
+			manager.LastChat.ModelSelect.SetSelected(settings.GetModel())
+		}
+
+		// Closing the tab of a chat saves and archives it
+		manager.handleClosedTabs()
+
+		// Register the keyboard shortcuts and follow changes in the settings
+		manager.handleShortcuts()
+		settings.OnShortcutsChanged = shortcuts.Apply
+		shortcuts.Apply()
+
+		// Remember the open tabs when the window closes or the app quits, but
+		// only while the window is still there to describe them
+		sessionSaved := false
+		saveSession := func() {
+			if sessionSaved {
+				return
+			}
+			sessionSaved = true
+			if err := internal.SaveSession(manager.Session()); err != nil {
+				slog.Error("Failed to save session", "err", err)
+			}
+		}
+		w.SetCloseIntercept(func() {
+			saveSession()
+			w.Close()
+		})
+		a.Lifecycle().SetOnStopped(saveSession)
+	}
 
-	// Start with the last chat if it exists
-	if manager.LastChat != nil {
-		// Create a new tab for the last chat
-		chatTab := container.NewTabItemWithIcon(
-			"Last Chat",
-			theme.DocumentIcon(),
-			manager.LastChat.GetContainer(),
-		)
-		manager.Sidebar.TabContainer.Append(chatTab)
-		manager.Sidebar.TabContainer.Select(chatTab)
+	// Encrypted history asks for its passphrase first
+	if internal.HistoryLocked() {
+		internal.ShowUnlock(w, start)
+	} else {
+		start()
 	}
 
 	// Center the window on screen
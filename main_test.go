@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/test"
+	"fyne.io/fyne/v2/widget"
+	"github.com/temidaradev/NeuraTalk/internal"
+)
+
+// newTestManager creates a chat manager with a sidebar, its files kept
+// below a temporary directory
+func newTestManager(t *testing.T) *ChatManager {
+	t.Helper()
+	if _, err := internal.InitPaths(t.TempDir(), false); err != nil {
+		t.Fatal(err)
+	}
+	a := test.NewApp()
+	t.Cleanup(a.Quit)
+	w := test.NewWindow(nil)
+	settings := internal.NewSettings(w, a)
+
+	m := &ChatManager{
+		Current:   -1,
+		Window:    w,
+		Settings:  settings,
+		models:    []string{"llama3", "mistral", "gemma"},
+		tabTitles: map[*internal.InputOutput]string{},
+	}
+	m.Sidebar = &internal.Sidebar{Settings: settings, Window: w}
+	m.Sidebar.NewChatButton = widget.NewButton("New Chat", func() {})
+	m.Sidebar.LastChatButton = widget.NewButton("Last Chat", func() {})
+	m.Sidebar.CompareButton = widget.NewButton("Compare Models", func() {})
+	m.Sidebar.ArenaButton = widget.NewButton("Arena", func() {})
+	m.Sidebar.BatchButton = widget.NewButton("Batch Run", func() {})
+	w.SetContent(m.Sidebar.Sidebar(nil, settings.GetContainer()))
+	m.handleClosedTabs()
+	return m
+}
+
+// closeTab closes a tab as its close button does
+func closeTab(m *ChatManager, tab *container.TabItem) {
+	tabs := m.Sidebar.TabContainer
+	tabs.Remove(tab)
+	tabs.OnClosed(tab)
+}
+
+// tabOf returns the tab showing a chat
+func tabOf(m *ChatManager, io *internal.InputOutput) *container.TabItem {
+	for _, tab := range m.Sidebar.TabContainer.Items {
+		if tab.Content == io.GetContainer() {
+			return tab
+		}
+	}
+	return nil
+}
+
+// storedArchived reads whether the saved chat of a model is archived
+func storedArchived(t *testing.T, model string) bool {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(internal.DataDir(), "chats", model+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored struct {
+		Archived bool `json:"archived"`
+	}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		t.Fatal(err)
+	}
+	return stored.Archived
+}
+
+func TestClosingTabArchivesChat(t *testing.T) {
+	m := newTestManager(t)
+	io := m.newChat("")
+	io.ModelSelect.SetSelected("llama3")
+	io.Messages = []internal.Message{
+		{Role: internal.RoleUser, Content: "Hello"},
+		{Role: internal.RoleAssistant, Content: "Hi there"},
+	}
+
+	closeTab(m, tabOf(m, io))
+
+	if !io.Info.Archived {
+		t.Error("closed chat is not archived")
+	}
+	if !storedArchived(t, "llama3") {
+		t.Error("archive of the closed chat was not saved")
+	}
+	if len(m.Instances) != 0 {
+		t.Errorf("manager still holds %d chats", len(m.Instances))
+	}
+	if io.OnConversationChanged != nil {
+		t.Error("closed chat still reports changes to the manager")
+	}
+
+	// Opening the chat again brings it back from the archive
+	m.openChat("llama3")
+	reopened := m.SelectedChat()
+	if reopened == nil || reopened.Info.Archived || storedArchived(t, "llama3") {
+		t.Error("reopened chat is still archived")
+	}
+}
+
+func TestClosingTabsUpdatesChats(t *testing.T) {
+	m := newTestManager(t)
+	first := m.newChat("")
+	second := m.newChat("")
+	third := m.newChat("")
+
+	closeTab(m, tabOf(m, second))
+
+	if len(m.Instances) != 2 || m.Instances[0] != first || m.Instances[1] != third {
+		t.Fatalf("chats after closing the middle one = %v", m.Instances)
+	}
+	if m.Current != len(m.Instances)-1 {
+		t.Errorf("current chat = %d, want %d", m.Current, len(m.Instances)-1)
+	}
+	if _, ok := m.tabTitles[second]; ok {
+		t.Error("title of the closed chat is still kept")
+	}
+	for _, io := range m.Instances {
+		if m.chatOf(tabOf(m, io)) != io {
+			t.Error("tab of an open chat no longer maps to it")
+		}
+	}
+
+	// Tabs without a chat close without touching the chats
+	m.Sidebar.HomeButton.OnTapped()
+	closeTab(m, m.Sidebar.TabContainer.Selected())
+	if len(m.Instances) != 2 {
+		t.Errorf("closing the home tab changed the chats to %d", len(m.Instances))
+	}
+
+	// Closing through the keyboard shortcut works the same way
+	m.Sidebar.TabContainer.Select(tabOf(m, first))
+	m.closeSelectedTab()
+	if len(m.Instances) != 1 || m.Instances[0] != third {
+		t.Errorf("chats after closing the selected tab = %v", m.Instances)
+	}
+	if tabOf(m, first) != nil {
+		t.Error("tab of the closed chat is still shown")
+	}
+}
+
+func TestClearingChatMakesItTheLastChat(t *testing.T) {
+	m := newTestManager(t)
+	first := m.newChat("")
+	first.ModelSelect.SetSelected("llama3")
+	second := m.newChat("")
+	second.ModelSelect.SetSelected("mistral")
+	if m.LastChat != second {
+		t.Fatal("newest chat is not the last chat")
+	}
+
+	first.ClearChatButton.OnTapped()
+	if m.LastChat != first {
+		t.Error("cleared chat did not become the last chat")
+	}
+	if m.SelectedChat() != first {
+		t.Error("cleared chat is not shown")
+	}
+
+	// A cleared chat without a tab gets one again
+	closeTab(m, tabOf(m, first))
+	first.ClearChatButton.OnTapped()
+	if m.LastChat != first {
+		t.Error("cleared chat without a tab did not become the last chat")
+	}
+	tab := tabOf(m, first)
+	if tab == nil || tab.Text != "Last Chat" {
+		t.Fatal("cleared chat without a tab was not opened again")
+	}
+	if len(m.Instances) != 2 {
+		t.Errorf("manager holds %d chats, want 2", len(m.Instances))
+	}
+}
@@ -79,16 +79,60 @@ remove_application() {
     fi
 }
 
-cleanup_tmp_files() {
-    echo -e "${YELLOW}Cleaning up temporary files...${NC}"
-    if [[ -d "./tmp" ]]; then
-        rm -rf "./tmp"
-        echo -e "${GREEN}Removed temporary directory${NC}"
+# xdg_dir prints an XDG base directory, falling back when it is unset or
+# relative, the same way NeuraTalk resolves it
+xdg_dir() {
+    if [[ -n "$1" && "$1" == /* ]]; then
+        echo "$1"
+    else
+        echo "$2"
+    fi
+}
+
+cleanup_user_data() {
+    # Settings and history live in the user's config, data and cache directories
+    local user_home="$HOME"
+    if [[ -n "$SUDO_USER" ]]; then
+        user_home=$(eval echo "~$SUDO_USER")
+    fi
+    local dirs
+    if [[ -n "$NEURATALK_HOME" ]]; then
+        dirs=("$NEURATALK_HOME/config" "$NEURATALK_HOME/data" "$NEURATALK_HOME/cache")
+    elif [[ "$OSTYPE" == "darwin"* ]]; then
+        dirs=("$user_home/Library/Application Support/$APP_NAME" "$user_home/Library/Caches/$APP_NAME")
+    else
+        dirs=(
+            "$(xdg_dir "$XDG_CONFIG_HOME" "$user_home/.config")/$APP_NAME"
+            "$(xdg_dir "$XDG_DATA_HOME" "$user_home/.local/share")/$APP_NAME"
+            "$(xdg_dir "$XDG_CACHE_HOME" "$user_home/.cache")/$APP_NAME"
+        )
+    fi
+
+    local found=()
+    for dir in "${dirs[@]}"; do
+        if [[ -d "$dir" ]]; then
+            found+=("$dir")
+        fi
+    done
+    if [[ ${#found[@]} -eq 0 ]]; then
+        return
+    fi
+
+    # Deleting the data can't be undone, so it is kept unless asked
+    echo -e "${YELLOW}Your settings and chat history are kept in:${NC}"
+    printf '  %s\n' "${found[@]}"
+    local answer=""
+    if [[ -t 0 ]]; then
+        read -r -p "Delete them too? This removes all chat history, encrypted or not. [y/N] " answer
     fi
-    if [[ -d "./conversations" ]]; then
-        rm -rf "./conversations"
-        echo -e "${GREEN}Removed conversations directory${NC}"
+    if [[ ! "$answer" =~ ^[Yy]([Ee][Ss])?$ ]]; then
+        echo -e "${GREEN}Kept your settings and chat history${NC}"
+        return
     fi
+    for dir in "${found[@]}"; do
+        rm -rf "$dir"
+        echo -e "${GREEN}Removed $dir${NC}"
+    done
 }
 
 main() {
@@ -101,7 +145,7 @@ main() {
 
     detect_pkg_manager
     remove_application
-    cleanup_tmp_files
+    cleanup_user_data
 
     echo -e "\n${GREEN}Uninstallation completed successfully!${NC}"
     if [[ "$OSTYPE" == "darwin"* ]]; then